@@ -10,7 +10,6 @@ import (
 	"reflect"
 	"strings"
 
-	"github.com/skip2/go-qrcode"
 	"golang.org/x/image/math/f32"
 	"seedhammer.com/bc/fountain"
 	"seedhammer.com/bc/ur"
@@ -18,7 +17,6 @@ import (
 	"seedhammer.com/bip39"
 	"seedhammer.com/engrave"
 	"seedhammer.com/font"
-	"seedhammer.com/seedqr"
 )
 
 type PlateSize int
@@ -63,37 +61,19 @@ type PlateDesc struct {
 	KeyIdx     int
 	Mnemonic   bip39.Mnemonic
 	Font       *font.Face
+	// Template is the plate layouts. If nil, DefaultTemplate is used.
+	Template *Template
 }
 
 type Plate struct {
 	Size  PlateSize
 	Sides []engrave.Command
-}
-
-type measureProgram struct {
-	Bounds image.Rectangle
-}
-
-func (m *measureProgram) Line(p f32.Vec2) {
-	bounds := image.Rectangle{
-		Min: image.Pt(int(math.Floor(float64(p[0]))), int(math.Floor(float64(p[1])))),
-		Max: image.Pt(int(math.Ceil(float64(p[0]))), int(math.Ceil(float64(p[1])))),
-	}
-	m.Bounds = m.Bounds.Union(bounds)
-}
-
-func (m *measureProgram) Move(p f32.Vec2) {}
-
-func measure(c engrave.Command) image.Rectangle {
-	var measure measureProgram
-	c.Engrave(&measure)
-	return measure.Bounds
+	// QRs lists the content of the QR codes engraved on each side.
+	QRs [][]string
 }
 
 func dims(c engrave.Command) (engrave.Command, f32.Vec2) {
-	b := measure(c)
-	sz := b.Size()
-	return c, f32.Vec2{float32(sz.X), float32(sz.Y)}
+	return c, engrave.Dims(c)
 }
 
 var ErrDescriptorTooLarge = errors.New("output descriptor is too large to backup")
@@ -101,21 +81,104 @@ var ErrDescriptorTooLarge = errors.New("output descriptor is too large to backup
 const outerMargin = 3
 const innerMargin = 10
 
+// Engrave lays out a share on a single plate.
 func Engrave(strokeWidth float32, plate PlateDesc) (Plate, error) {
-	for _, sz := range []PlateSize{SmallPlate, SquarePlate, LargePlate} {
-		p := Plate{Size: sz}
-		seedOnly := plate.Descriptor.Type == urtypes.UnknownScript
-		switch {
-		case seedOnly && len(plate.Mnemonic) > 12:
-			p.Sides = append(p.Sides, seedBackSide(plate.Title, plate.Font, plate.Mnemonic, sz.Bounds().Size()))
-		case !seedOnly:
-			urs := splitUR(plate.Descriptor, plate.KeyIdx)
-			p.Sides = append(p.Sides, descriptorSide(strokeWidth, plate.Font, urs, p.Size))
+	seedOnly := plate.Descriptor.Type == urtypes.UnknownScript
+	typ := DescriptorLayout
+	var urs []string
+	if seedOnly {
+		typ = SeedLayout
+	} else {
+		urs = splitUR(plate.Descriptor, plate.KeyIdx)
+	}
+	return engraveLayout(strokeWidth, plate, typ, urs, 1, 1)
+}
+
+// maxSheets bounds the number of plates of a share.
+const maxSheets = 9
+
+// EngraveShare is like Engrave, but spreads the descriptor fragments
+// of the share over continuation plates if they don't fit a single
+// plate.
+func EngraveShare(strokeWidth float32, plate PlateDesc) ([]Plate, error) {
+	p, err := Engrave(strokeWidth, plate)
+	if err == nil || !errors.Is(err, ErrDescriptorTooLarge) || plate.Descriptor.Type == urtypes.UnknownScript {
+		return []Plate{p}, err
+	}
+	// Fit as many fragments as possible on each plate. The plate
+	// numbers are not yet known, so lay out with the widest.
+	urs := splitUR(plate.Descriptor, plate.KeyIdx)
+	var sheets [][]string
+	for len(urs) > 0 && len(sheets) < maxSheets {
+		typ := ContinuationLayout
+		if len(sheets) == 0 {
+			typ = DescriptorLayout
+		}
+		n := 0
+		for n < len(urs) {
+			if _, err := engraveLayout(strokeWidth, plate, typ, urs[:n+1], len(sheets)+1, maxSheets); err != nil {
+				break
+			}
+			n++
+		}
+		if n == 0 {
+			return nil, err
+		}
+		sheets = append(sheets, urs[:n])
+		urs = urs[n:]
+	}
+	if len(urs) > 0 || len(sheets) == 1 {
+		return nil, err
+	}
+	var plates []Plate
+	for i, urs := range sheets {
+		typ := ContinuationLayout
+		if i == 0 {
+			typ = DescriptorLayout
 		}
-		p.Sides = append(p.Sides, frontSide(strokeWidth, plate, p.Size))
-		bounds := measure(engrave.Commands(p.Sides))
+		p, err := engraveLayout(strokeWidth, plate, typ, urs, i+1, len(sheets))
+		if err != nil {
+			return nil, err
+		}
+		plates = append(plates, p)
+	}
+	return plates, nil
+}
+
+// engraveLayout engraves the first layout of type typ that fits.
+func engraveLayout(strokeWidth float32, plate PlateDesc, typ LayoutType, urs []string, sheet, sheets int) (Plate, error) {
+	tmpl := plate.Template
+	if tmpl == nil {
+		tmpl = DefaultTemplate
+	}
+	var keepOutErr error
+	for i, l := range tmpl.Layouts {
+		if l.Type != typ {
+			continue
+		}
+		p := Plate{Size: l.Size}
 		dims := p.Size.Bounds().Size()
-		safetyMargin := image.Pt(outerMargin, outerMargin)
+		ctx := &sideContext{
+			strokeWidth: strokeWidth,
+			plate:       plate,
+			urs:         urs,
+			dims:        f32.Vec2{float32(dims.X), float32(dims.Y)},
+			sheet:       sheet,
+			sheets:      sheets,
+		}
+		for j, s := range l.Sides {
+			if len(plate.Mnemonic) < s.MinWords {
+				continue
+			}
+			cmd, qrs, err := s.engrave(ctx)
+			if err != nil {
+				return Plate{}, fmt.Errorf("backup: template %q: layout %d: side %d: %w", tmpl.Name, i, j, err)
+			}
+			p.Sides = append(p.Sides, cmd)
+			p.QRs = append(p.QRs, qrs)
+		}
+		bounds := engrave.Measure(engrave.Commands(p.Sides))
+		safetyMargin := image.Pt(int(l.Margin), int(l.Margin))
 		if !bounds.In(image.Rectangle{Min: safetyMargin, Max: dims.Sub(safetyMargin)}) {
 			continue
 		}
@@ -123,11 +186,25 @@ func Engrave(strokeWidth float32, plate PlateDesc) (Plate, error) {
 		for i, s := range p.Sides {
 			p.Sides[i] = engrave.Offset(float32(off.X), float32(off.Y), s)
 		}
+		if err := p.CheckKeepOuts(strokeWidth / 2); err != nil {
+			if keepOutErr == nil {
+				keepOutErr = err
+			}
+			continue
+		}
 		return p, nil
 	}
+	if keepOutErr != nil {
+		return Plate{}, errors.Join(ErrDescriptorTooLarge, keepOutErr)
+	}
 	return Plate{}, ErrDescriptorTooLarge
 }
 
+// maxFragmentLen is the maximum data size of fragments that contain
+// complete descriptors. It is the largest size that fits a plate along
+// with the rest of the share.
+const maxFragmentLen = 220
+
 // splitUR searches for the appropriate seqNum in the [UR] encoding
 // that makes m-of-n backups recoverable regardless of
 // which m-sized subset is used. To achieve that, we're exploiting the
@@ -197,9 +274,12 @@ func splitUR(desc urtypes.OutputDescriptor, keyIdx int) (urs []string) {
 		shares = [][]int{{keyIdx}, second}
 	default:
 		// Fallback: every share contains the complete data. It's only optimal
-		// for 1-of-n backups.
-		seqLen = 1
-		shares = [][]int{{0}}
+		// for 1-of-n backups. Split large data into several parts, to allow
+		// EngraveShare to spread them over several plates.
+		seqLen = (len(desc.Encode()) + maxFragmentLen - 1) / maxFragmentLen
+		for i := 0; i < seqLen; i++ {
+			shares = append(shares, []int{i})
+		}
 	}
 	data := desc.Encode()
 	check := fountain.Checksum(data)
@@ -250,140 +330,59 @@ func Recoverable(desc urtypes.OutputDescriptor) bool {
 	return true
 }
 
-const plateFontSize = 5.
-const plateFontSizeUR = 4.1
-const plateSmallFontSize = 3.5
-
-func frontSide(strokeWidth float32, plate PlateDesc, size PlateSize) engrave.Command {
-	var cmds engrave.Commands
-	cmd := func(c engrave.Command) {
-		cmds = append(cmds, c)
-	}
-	plateDimsI := size.Bounds().Size()
-	plateDims := f32.Vec2{float32(plateDimsI.X), float32(plateDimsI.Y)}
-
-	maxCol1 := 16
-	maxCol2 := 4
-	seedOnly := plate.Descriptor.Type == urtypes.UnknownScript
-	switch {
-	case seedOnly && size == SmallPlate:
-		// 12 words on this side, the rest on the other.
-		maxCol1 = 12
-		maxCol2 = 0
-	}
-	endCol1 := maxCol1
-	if endCol1 > len(plate.Mnemonic) {
-		endCol1 = len(plate.Mnemonic)
-	}
-	col1, col1b := dims(wordColumn(plate.Font, plate.Mnemonic, 0, endCol1))
-
-	// Engrave version, mfp and page.
-	const version = "v1"
-	const margin = outerMargin
-	const metaMargin = 4
-	page := fmt.Sprintf("%d/%d", plate.KeyIdx+1, len(plate.Descriptor.Keys))
-	switch size {
-	case SmallPlate:
-		pagec, _ := dims(engrave.String(plate.Font, plateSmallFontSize, page))
-		cmd(engrave.Offset(margin, plateDims[1]-innerMargin, engrave.Rotate(-math.Pi/2, pagec)))
-		mfp := fmt.Sprintf("%.8x", plate.Descriptor.Keys[plate.KeyIdx].MasterFingerprint)
-		mfpc, sz := dims(engrave.Rotate(-math.Pi/2, engrave.String(plate.Font, plateSmallFontSize, mfp)))
-		cmd(engrave.Offset(margin, (plateDims[1]+sz[1])/2, mfpc))
-		txt, sz := dims(engrave.Rotate(-math.Pi/2, engrave.String(plate.Font, plateSmallFontSize, version)))
-		cmd(engrave.Offset(margin, innerMargin+sz[1], txt))
-	default:
-		offy := (plateDims[1]-col1b[1])/2 - metaMargin
-		pagec, sz := dims(engrave.String(plate.Font, plateSmallFontSize, page))
-		cmd(engrave.Offset(innerMargin, offy-sz[1], pagec))
-		mfp := fmt.Sprintf("%.8x", plate.Descriptor.Keys[plate.KeyIdx].MasterFingerprint)
-		mfpc, sz := dims(engrave.String(plate.Font, plateSmallFontSize, mfp))
-		cmd(engrave.Offset((plateDims[0]-sz[0])/2, offy-sz[1], mfpc))
-		txt, sz := dims(engrave.String(plate.Font, plateSmallFontSize, version))
-		cmd(engrave.Offset(plateDims[0]-sz[0]-innerMargin, offy-sz[1], txt))
-	}
-
-	// Engrave column 1.
-	cmd(engrave.Offset(innerMargin, (plateDims[1]-col1b[1])/2, col1))
-
-	// Engrave (top of) column 2.
-	endCol2 := endCol1 + maxCol2
-	if endCol2 > len(plate.Mnemonic) {
-		endCol2 = len(plate.Mnemonic)
-	}
-	cmd(engrave.Offset(44, (plateDims[1]-col1b[1])/2, wordColumn(plate.Font, plate.Mnemonic, endCol1, endCol2)))
-
-	// Engrave seed QR.
-	qr, sz := dims(engrave.QR(strokeWidth, 3, qrcode.High, seedqr.CompactQR(plate.Mnemonic)))
-	cx, cy := float32(60), plateDims[1]/2
-	cmd(engrave.Offset(cx-sz[0]/2, cy-sz[1]/2, qr))
-
-	if size != SmallPlate {
-		// Engrave bottom of column 2.
-		col2, col2b := dims(wordColumn(plate.Font, plate.Mnemonic, endCol2, len(plate.Mnemonic)))
-		cmd(engrave.Offset(44, (plateDims[1]+col1b[1])/2-col2b[1], col2))
-	}
+const (
+	plateFontSize      = 5.
+	plateFontSizeUR    = 4.1
+	plateSmallFontSize = 3.5
+	plateLineHeight    = .8
+)
 
-	// Engrave title.
-	switch size {
-	case SmallPlate:
-		title, sz := dims(engrave.Rotate(-math.Pi/2, engrave.String(plate.Font, plateSmallFontSize, plate.Title)))
-		cmd(engrave.Offset(plateDims[0]-margin-sz[0], (plateDims[1]+sz[1])/2, title))
-	default:
-		offy := (plateDims[1]+col1b[1])/2 + metaMargin
-		title, sz := dims(engrave.String(plate.Font, plateSmallFontSize, plate.Title))
-		cmd(engrave.Offset((plateDims[0]-sz[0])/2, offy, title))
-	}
-	if size == LargePlate {
-		// Avoid the middle holes.
-		return engrave.Offset(0, 24.5, cmds)
-	}
-	return cmds
-}
+const version = "v1"
 
-func wordColumn(font *font.Face, mnemonic bip39.Mnemonic, start, end int) engrave.Command {
+func wordColumn(font *font.Face, fontSize, lineHeight float32, mnemonic bip39.Mnemonic, start, end int) engrave.Command {
 	var b strings.Builder
 	for i := start; i < end; i++ {
 		w := mnemonic[i]
 		word := strings.ToUpper(bip39.LabelFor(w))
 		fmt.Fprintf(&b, "%2d:%-8s\n", i+1, word)
 	}
-	cmd := engrave.String(font, plateFontSize, b.String())
-	cmd.LineHeight = .8
-	return cmd
+	txt := engrave.Text{
+		Face:       font,
+		Size:       fontSize,
+		LineHeight: lineHeight,
+		Monospace:  true,
+	}
+	return txt.Layout(strings.TrimSuffix(b.String(), "\n"))
 }
 
-func descriptorSide(strokeWidth float32, fnt *font.Face, urs []string, size PlateSize) engrave.Command {
+// descriptorSide lays out UR fragments in lines of text, each with its QR code
+// inset at the right. Lines near the corners are shortened to avoid the screw
+// holes.
+func descriptorSide(strokeWidth float32, fnt *font.Face, urs []string, plateDims f32.Vec2, e Element) engrave.Command {
 	var cmds engrave.Commands
 	cmd := func(c engrave.Command) {
 		cmds = append(cmds, c)
 	}
-	const fontSize = plateFontSizeUR
-	str := func(s string) engrave.Command {
-		return engrave.String(fnt, fontSize, s)
+	txt := engrave.Text{
+		Face:      fnt,
+		Size:      e.FontSize,
+		Monospace: true,
 	}
-
-	plateDimsI := size.Bounds().Size()
-	plateDims := f32.Vec2{float32(plateDimsI.X), float32(plateDimsI.Y)}
-	// Compute character width, assuming the font is fixed width.
-	charWidth, _, ok := fnt.Decode('W')
-	if !ok {
-		panic("W not in font")
-	}
-	charWidth *= fontSize
-	fontHeight := fnt.Metrics.Height * fontSize
-	margin := float32(outerMargin)
-	if size == LargePlate {
-		margin = innerMargin
-	}
-	holeChars := int(math.Ceil(float64(innerMargin-margin) / float64(charWidth)))
-	holeLines := int(math.Ceil(float64(innerMargin-margin) / float64(fontHeight)))
+	lvl, _ := e.level()
+
+	cell := txt.Cell()
+	charWidth, fontHeight := cell[0], cell[1]
+	margin := e.Margin
+	holeMargin := e.HoleMargin
+	holeChars := int(math.Ceil(float64(holeMargin-margin) / float64(charWidth)))
+	holeLines := int(math.Ceil(float64(holeMargin-margin) / float64(fontHeight)))
 	width := plateDims[0] - 2*margin
-	charPerLine := int(width / charWidth)
-	offy := float32(outerMargin)
+	charPerLine := txt.Columns(width)
+	offy := e.Y
 	for i, ur := range urs {
-		qr, qrsz := dims(engrave.QR(strokeWidth, 2, qrcode.Medium, []byte(ur)))
-		const qrBorder = 2
-		charPerQRLine := int((width - 2*qrBorder - qrsz[0]) / charWidth)
+		qr, qrsz := dims(engrave.QR(strokeWidth, e.Scale, lvl, []byte(ur)))
+		qrBorder := e.Border
+		charPerQRLine := txt.Columns(width - 2*qrBorder - qrsz[0])
 		qrLines := int(math.Ceil(float64((qrsz[1] + 2*qrBorder) / fontHeight)))
 		qrLineStart := holeLines
 		lineno := 0
@@ -395,8 +394,8 @@ func descriptorSide(strokeWidth float32, fnt *font.Face, urs []string, size Plat
 				n = charPerQRLine
 			}
 			// Avoid screw holes on the smaller plates on the first and last lines.
-			holeLine := offy+float32(lineno)*fontHeight < innerMargin ||
-				offy+float32(lineno+1)*fontHeight > plateDims[1]-innerMargin
+			holeLine := offy+float32(lineno)*fontHeight < holeMargin ||
+				offy+float32(lineno+1)*fontHeight > plateDims[1]-holeMargin
 			if holeLine {
 				if !isQRLine {
 					// End of line.
@@ -414,7 +413,7 @@ func descriptorSide(strokeWidth float32, fnt *font.Face, urs []string, size Plat
 			}
 			s := ur[:n]
 			ur = ur[n:]
-			cmd(engrave.Offset(offx+margin, offy+float32(lineno)*fontHeight, str(s)))
+			cmd(engrave.Offset(offx+margin, offy+float32(lineno)*fontHeight, txt.Layout(s)))
 			lineno++
 		}
 		qrx := plateDims[0] - qrsz[0] - margin - qrBorder
@@ -423,23 +422,9 @@ func descriptorSide(strokeWidth float32, fnt *font.Face, urs []string, size Plat
 		offy += float32(lineno) * fontHeight
 		if i != len(urs)-1 {
 			// Space UR sections.
-			offy += 1
+			offy += e.Spacing
 		}
 	}
 
 	return cmds
 }
-
-func seedBackSide(title string, font *font.Face, plate bip39.Mnemonic, size image.Point) engrave.Command {
-	var cmds engrave.Commands
-	cmd := func(c engrave.Command) {
-		cmds = append(cmds, c)
-	}
-	const col1Words = 18
-	col1, col1b := dims(wordColumn(font, plate, 12, col1Words))
-	y := (float32(size.Y) - col1b[1]) / 2
-	cmd(engrave.Offset(9, y, col1))
-	col2 := wordColumn(font, plate, col1Words, len(plate))
-	cmd(engrave.Offset(44, y, col2))
-	return cmds
-}
@@ -2,6 +2,8 @@ package backup
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
@@ -9,10 +11,13 @@ import (
 	"image/png"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/btcsuite/btcd/btcutil/hdkeychain"
 	"github.com/btcsuite/btcd/chaincfg"
+	"golang.org/x/image/math/f32"
 	"seedhammer.com/bc/urtypes"
 	"seedhammer.com/bip32"
 	"seedhammer.com/bip39"
@@ -171,6 +176,330 @@ func TestEngrave(t *testing.T) {
 	}
 }
 
+func TestSimulatedEngraving(t *testing.T) {
+	if testing.Short() {
+		t.Skip("simulated engraving is slow")
+	}
+	desc := urtypes.OutputDescriptor{
+		Type:      urtypes.P2WSH,
+		Threshold: 2,
+		Keys:      make([]urtypes.KeyDescriptor, 3),
+	}
+	plateDesc := genTestPlate(t, desc, desc.DerivationPath(), 12, 0)
+	plate, err := Engrave(mjolnir.StrokeWidth, plateDesc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range plate.Sides {
+		compareSimulated(t, plate, i)
+	}
+}
+
+// compareSimulated engraves a side on the simulator and compares the
+// rendering of the recorded commands with the rasterized side.
+func compareSimulated(t *testing.T, plate Plate, side int) {
+	t.Helper()
+	sim := mjolnir.NewSimulator()
+	defer sim.Close()
+	prog, err := mjolnir.NewProgram(plate.Sides[side])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mjolnir.Engrave(context.Background(), sim, prog, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	const ppmm = 4
+	bounds := plate.Size.Bounds()
+	got := mjolnir.Recording(sim.Cmds).Render(bounds, mjolnir.StrokeWidth, ppmm)
+	want := image.NewAlpha(got.Bounds())
+	r := engrave.NewRasterizer(want, want.Bounds(), mjolnir.StrokeWidth*ppmm)
+	engrave.Scale(ppmm, ppmm, plate.Sides[side]).Engrave(r)
+	r.Rasterize()
+	ink := func(img *image.Alpha, x, y int) bool {
+		return img.AlphaAt(x, y).A >= 128
+	}
+	// near reports whether img has ink within a pixel of (x, y), to allow
+	// for rounding to machine steps.
+	near := func(img *image.Alpha, x, y int, v bool) bool {
+		for dy := -1; dy <= 1; dy++ {
+			for dx := -1; dx <= 1; dx++ {
+				if ink(img, x+dx, y+dy) == v {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	mismatches, pixels := 0, 0
+	b := want.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			w, g := ink(want, x, y), ink(got, x, y)
+			if w {
+				pixels++
+			}
+			if w != g && (!near(got, x, y, w) || !near(want, x, y, g)) {
+				mismatches++
+			}
+		}
+	}
+	if max := pixels / 1000; mismatches > max {
+		t.Errorf("side %d: %d/%d pixels of the simulated engraving mismatch", side, mismatches, pixels)
+	}
+}
+
+func TestEngraveShare(t *testing.T) {
+	tests := []struct {
+		threshold int
+		keys      int
+		plates    int
+	}{
+		{2, 3, 1},
+		{1, 3, 2},
+		{1, 5, 3},
+	}
+	for _, test := range tests {
+		desc := urtypes.OutputDescriptor{
+			Type:      urtypes.P2WSH,
+			Threshold: test.threshold,
+			Keys:      make([]urtypes.KeyDescriptor, test.keys),
+		}
+		plateDesc := genTestPlate(t, desc, desc.DerivationPath(), 24, 0)
+		plates, err := EngraveShare(mjolnir.StrokeWidth, plateDesc)
+		if err != nil {
+			t.Fatalf("%d-of-%d: %v", test.threshold, test.keys, err)
+		}
+		if len(plates) != test.plates {
+			t.Errorf("%d-of-%d: got %d plates, want %d", test.threshold, test.keys, len(plates), test.plates)
+		}
+		if err := Verify(mjolnir.StrokeWidth, plateDesc, plates...); err != nil {
+			t.Errorf("%d-of-%d: %v", test.threshold, test.keys, err)
+		}
+		if !Recoverable(desc) {
+			t.Errorf("%d-of-%d: not recoverable", test.threshold, test.keys)
+		}
+	}
+}
+
+func TestTemplateRoundTrip(t *testing.T) {
+	enc, err := json.Marshal(DefaultTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl, err := ParseTemplate(enc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(tmpl, DefaultTemplate) {
+		t.Error("template changed by encoding roundtrip")
+	}
+}
+
+func TestParseTemplateErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		tmpl string
+	}{
+		{"empty", `{}`},
+		{"size", `{"layouts": [{"size": "huge", "type": "seed", "sides": [{}]}]}`},
+		{"type", `{"layouts": [{"size": "small", "type": "multi", "sides": [{}]}]}`},
+		{"no sides", `{"layouts": [{"size": "small", "type": "seed"}]}`},
+		{"element", `{"layouts": [{"size": "small", "type": "seed", "sides": [{"elements": [{"type": "logo"}]}]}]}`},
+		{"font size", `{"layouts": [{"size": "small", "type": "seed", "sides": [{"elements": [{"type": "title"}]}]}]}`},
+		{"words", `{"layouts": [{"size": "small", "type": "seed", "sides": [{"elements": [{"type": "words", "font_size": 5, "start": 4, "end": 2}]}]}]}`},
+		{"level", `{"layouts": [{"size": "small", "type": "seed", "sides": [{"elements": [{"type": "seedqr", "scale": 3, "level": "X"}]}]}]}`},
+		{"align", `{"layouts": [{"size": "small", "type": "seed", "sides": [{"elements": [{"type": "title", "font_size": 4, "align": "left"}]}]}]}`},
+		{"width", `{"layouts": [{"size": "small", "type": "seed", "sides": [{"elements": [{"type": "title", "font_size": 4, "width": -1}]}]}]}`},
+	}
+	for _, test := range tests {
+		if _, err := ParseTemplate([]byte(test.tmpl)); err == nil {
+			t.Errorf("%s: invalid template accepted", test.name)
+		}
+	}
+}
+
+func TestTemplateOverflow(t *testing.T) {
+	title := Element{Type: TitleElement, X: 42, Y: 10, Anchor: [2]float32{.5, 0}, FontSize: 4, Width: 60, Align: "center", MaxLines: 2}
+	tmpl := &Template{
+		Name: "overflow",
+		Layouts: []Layout{{
+			Size:   SmallPlate,
+			Type:   SeedLayout,
+			Margin: outerMargin,
+			Sides:  []Side{{Elements: []Element{title}}},
+		}},
+	}
+	desc := urtypes.OutputDescriptor{Type: urtypes.UnknownScript}
+	plateDesc := genTestPlate(t, desc, nil, 12, 0)
+	plateDesc.Template = tmpl
+	plateDesc.Title = "A TITLE WRAPPED TO TWO LINES OF THE PLATE"
+	if _, err := Engrave(mjolnir.StrokeWidth, plateDesc); err != nil {
+		t.Errorf("wrapped title failed: %v", err)
+	}
+	plateDesc.Title = strings.Repeat("A VERY LONG TITLE ", 5)
+	_, err := Engrave(mjolnir.StrokeWidth, plateDesc)
+	var oerr *engrave.OverflowError
+	if !errors.As(err, &oerr) {
+		t.Errorf("got error %v, want *engrave.OverflowError", err)
+	}
+}
+
+func TestKeepOuts(t *testing.T) {
+	line := func(x0, y0, x1, y1 float32) engrave.Command {
+		return lineCmd{f32.Vec2{x0, y0}, f32.Vec2{x1, y1}}
+	}
+	off := LargePlate.Bounds().Min
+	ox, oy := float32(off.X), float32(off.Y)
+	clear := line(ox+20, oy+20, ox+60, oy+20)
+	if err := CheckKeepOuts(LargePlate, clear, mjolnir.StrokeWidth); err != nil {
+		t.Errorf("stroke clear of holes reported: %v", err)
+	}
+	// A horizontal stroke across the middle holes of the large plate.
+	_, sh := SmallPlate.dims()
+	y := oy + float32(sh) - holeInset
+	var kerr *KeepOutError
+	err := CheckKeepOuts(LargePlate, line(ox, y, ox+40, y), mjolnir.StrokeWidth)
+	if !errors.As(err, &kerr) {
+		t.Fatalf("got error %v, want *KeepOutError", err)
+	}
+	if kerr.Zone.Kind != HoleKeepOut || kerr.Distance >= 0 {
+		t.Errorf("stroke through hole reported as %v", kerr)
+	}
+
+	// A template placing the title on top of a clamp.
+	tmpl := &Template{
+		Layouts: []Layout{{
+			Size: SmallPlate,
+			Type: SeedLayout,
+			Sides: []Side{{Elements: []Element{
+				{Type: TitleElement, X: 1, Y: 1, FontSize: 4},
+			}}},
+		}},
+	}
+	desc := urtypes.OutputDescriptor{Type: urtypes.UnknownScript}
+	plateDesc := genTestPlate(t, desc, nil, 12, 0)
+	plateDesc.Title = "TITLE"
+	plateDesc.Template = tmpl
+	_, err = Engrave(mjolnir.StrokeWidth, plateDesc)
+	if !errors.Is(err, ErrDescriptorTooLarge) || !errors.As(err, &kerr) {
+		t.Errorf("got error %v, want ErrDescriptorTooLarge and *KeepOutError", err)
+	}
+}
+
+func TestPreflight(t *testing.T) {
+	desc := urtypes.OutputDescriptor{
+		Type:      urtypes.P2WSH,
+		Threshold: 2,
+		Keys:      make([]urtypes.KeyDescriptor, 3),
+	}
+	plateDesc := genTestPlate(t, desc, desc.DerivationPath(), 24, 0)
+	plates, err := EngraveShare(mjolnir.StrokeWidth, plateDesc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	travel := engrave.RectOf(mjolnir.NewEngraver(nil).Capabilities().WorkArea)
+	for _, p := range plates {
+		if err := p.Preflight(mjolnir.StrokeWidth, travel); err != nil {
+			t.Errorf("engraved plate rejected: %v", err)
+		}
+	}
+
+	off := SmallPlate.Bounds().Min
+	ox, oy := float32(off.X), float32(off.Y)
+	edge := Plate{Size: SmallPlate, Sides: []engrave.Command{
+		lineCmd{f32.Vec2{ox + 10, oy + 10}, f32.Vec2{ox + 20, oy + 10}},
+		lineCmd{f32.Vec2{ox + 10, oy + 10}, f32.Vec2{ox + 10, oy + .5}},
+	}}
+	var perr *PreflightError
+	err = edge.Preflight(mjolnir.StrokeWidth, travel)
+	if !errors.As(err, &perr) {
+		t.Fatalf("got error %v, want *PreflightError", err)
+	}
+	if perr.Side != 1 || perr.Travel || perr.Excess() < plateMargin-.5 {
+		t.Errorf("stroke near the edge reported as %v", perr)
+	}
+
+	inside := Plate{Size: SmallPlate, Sides: edge.Sides[:1]}
+	err = inside.Preflight(mjolnir.StrokeWidth, travel.Inset(ox+15))
+	if !errors.As(err, &perr) || !perr.Travel {
+		t.Errorf("got error %v, want travel *PreflightError", err)
+	}
+
+	hole := Plate{Size: SmallPlate, Sides: []engrave.Command{
+		edge.Sides[0],
+		lineCmd{f32.Vec2{ox + 2, oy + 5}, f32.Vec2{ox + 10, oy + 5}},
+	}}
+	var kerr *KeepOutError
+	err = hole.Preflight(mjolnir.StrokeWidth, travel)
+	if !errors.As(err, &kerr) || kerr.Side != 1 {
+		t.Errorf("got error %v, want *KeepOutError for side 1", err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		threshold int
+		keys      int
+		script    urtypes.Script
+		seedLen   int
+	}{
+		{1, 1, urtypes.UnknownScript, 12},
+		{1, 1, urtypes.P2WSH, 24},
+		{2, 3, urtypes.P2WSH, 24},
+		{3, 5, urtypes.P2SH_P2WSH, 12},
+	}
+	for _, test := range tests {
+		desc := urtypes.OutputDescriptor{
+			Type:      test.script,
+			Threshold: test.threshold,
+			Keys:      make([]urtypes.KeyDescriptor, test.keys),
+		}
+		plateDesc := genTestPlate(t, desc, desc.DerivationPath(), test.seedLen, 0)
+		plate, err := Engrave(mjolnir.StrokeWidth, plateDesc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := Verify(mjolnir.StrokeWidth, plateDesc, plate); err != nil {
+			t.Errorf("%d-of-%d: %v", test.threshold, test.keys, err)
+		}
+		// Erase a side with QR codes.
+		blank := plate
+		blank.Sides = append([]engrave.Command{}, plate.Sides...)
+		for i, qrs := range plate.QRs {
+			if len(qrs) > 0 {
+				blank.Sides[i] = engrave.Commands{}
+				break
+			}
+		}
+		var verr *VerifyError
+		if err := Verify(mjolnir.StrokeWidth, plateDesc, blank); !errors.As(err, &verr) {
+			t.Errorf("%d-of-%d: blank side verified (err: %v)", test.threshold, test.keys, err)
+		}
+		other := plateDesc
+		other.Mnemonic = append(bip39.Mnemonic{}, plateDesc.Mnemonic...)
+		other.Mnemonic[0] = (other.Mnemonic[0] + 1) % bip39.Word(len(bip39.Wordlist))
+		other.Mnemonic = other.Mnemonic.FixChecksum()
+		if err := Verify(mjolnir.StrokeWidth, other, plate); !errors.As(err, &verr) {
+			t.Errorf("%d-of-%d: mismatched mnemonic verified (err: %v)", test.threshold, test.keys, err)
+		}
+		if test.keys > 1 {
+			// The fragments of another share.
+			other := plateDesc
+			other.KeyIdx = 1
+			if err := Verify(mjolnir.StrokeWidth, other, plate); !errors.As(err, &verr) {
+				t.Errorf("%d-of-%d: fragments of another share verified (err: %v)", test.threshold, test.keys, err)
+			}
+		}
+	}
+}
+
+type lineCmd [2]f32.Vec2
+
+func (l lineCmd) Engrave(p engrave.Program) {
+	p.Move(l[0])
+	p.Line(l[1])
+}
+
 func TestSplitUR(t *testing.T) {
 	maxShares := 15
 	if testing.Short() {
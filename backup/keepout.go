@@ -0,0 +1,160 @@
+package backup
+
+import (
+	"fmt"
+	"math"
+
+	"golang.org/x/image/math/f32"
+	"seedhammer.com/affine"
+	"seedhammer.com/engrave"
+)
+
+// KeepOut is a region of a plate that must not be engraved.
+type KeepOut struct {
+	Kind   KeepOutKind
+	Center f32.Vec2
+	Radius float32
+}
+
+type KeepOutKind int
+
+const (
+	// HoleKeepOut is a screw hole through the plate.
+	HoleKeepOut KeepOutKind = iota
+	// ClampKeepOut is the area covered by the nut and washer clamping
+	// the plate to the engraver.
+	ClampKeepOut
+)
+
+func (k KeepOutKind) String() string {
+	switch k {
+	case HoleKeepOut:
+		return "hole"
+	case ClampKeepOut:
+		return "clamp"
+	}
+	return fmt.Sprintf("KeepOutKind(%d)", int(k))
+}
+
+const (
+	// holeInset is the distance from the plate edges to the hole centers.
+	holeInset   = 3.5
+	holeRadius  = 1.75
+	clampRadius = 3.5
+)
+
+// KeepOuts returns the holes and clamp regions of the plate, in plate
+// coordinates.
+func (p PlateSize) KeepOuts() []KeepOut {
+	w, h := p.dims()
+	ys := []float32{holeInset, float32(h) - holeInset}
+	if p == LargePlate {
+		// The middle holes line up with the bottom holes of the small plate.
+		_, sh := SmallPlate.dims()
+		ys = append(ys, float32(sh)-holeInset)
+	}
+	var zones []KeepOut
+	for _, y := range ys {
+		for _, x := range []float32{holeInset, float32(w) - holeInset} {
+			c := f32.Vec2{x, y}
+			zones = append(zones,
+				KeepOut{Kind: HoleKeepOut, Center: c, Radius: holeRadius},
+				KeepOut{Kind: ClampKeepOut, Center: c, Radius: clampRadius},
+			)
+		}
+	}
+	return zones
+}
+
+// KeepOutError describes a stroke too close to a keep-out zone.
+type KeepOutError struct {
+	Size PlateSize
+	Side int
+	Zone KeepOut
+	// Start and End are the end points of the offending stroke, in
+	// plate coordinates.
+	Start, End f32.Vec2
+	// Distance is the distance from the stroke to the zone edge. It is
+	// negative when the stroke is inside the zone.
+	Distance float32
+}
+
+func (e *KeepOutError) Error() string {
+	return fmt.Sprintf("backup: %s plate side %d: stroke (%.2f,%.2f)-(%.2f,%.2f) is %.2f mm from the %s at (%.1f,%.1f)",
+		e.Size, e.Side, e.Start[0], e.Start[1], e.End[0], e.End[1], e.Distance, e.Zone.Kind, e.Zone.Center[0], e.Zone.Center[1])
+}
+
+// CheckKeepOuts reports a *KeepOutError if any stroke of the plate is closer
+// than clearance to a keep-out zone of the plate.
+func (p Plate) CheckKeepOuts(clearance float32) error {
+	for i, s := range p.Sides {
+		if err := CheckKeepOuts(p.Size, s, clearance); err != nil {
+			err.(*KeepOutError).Side = i
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckKeepOuts is like Plate.CheckKeepOuts for a single side in machine
+// coordinates.
+func CheckKeepOuts(size PlateSize, side engrave.Command, clearance float32) error {
+	off := size.Bounds().Min
+	c := &keepOutChecker{
+		zones:     size.KeepOuts(),
+		clearance: clearance,
+		offset:    f32.Vec2{float32(off.X), float32(off.Y)},
+	}
+	side.Engrave(c)
+	if c.err != nil {
+		c.err.Size = size
+		return c.err
+	}
+	return nil
+}
+
+type keepOutChecker struct {
+	zones     []KeepOut
+	clearance float32
+	offset    f32.Vec2
+	pen       f32.Vec2
+	err       *KeepOutError
+}
+
+func (k *keepOutChecker) Move(p f32.Vec2) {
+	k.pen = affine.Sub(p, k.offset)
+}
+
+func (k *keepOutChecker) Line(p f32.Vec2) {
+	p = affine.Sub(p, k.offset)
+	start := k.pen
+	k.pen = p
+	if k.err != nil {
+		return
+	}
+	for _, z := range k.zones {
+		d := segmentDist(z.Center, start, p) - z.Radius
+		if d < k.clearance {
+			k.err = &KeepOutError{
+				Zone:     z,
+				Start:    start,
+				End:      p,
+				Distance: d,
+			}
+			return
+		}
+	}
+}
+
+// segmentDist returns the distance from p to the line segment (a, b).
+func segmentDist(p, a, b f32.Vec2) float32 {
+	ab := affine.Sub(b, a)
+	ap := affine.Sub(p, a)
+	t := float32(0)
+	if l2 := affine.Dot(ab, ab); l2 > 0 {
+		t = affine.Dot(ap, ab) / l2
+	}
+	t = float32(math.Max(0, math.Min(1, float64(t))))
+	closest := affine.Add(a, affine.Scale(ab, t))
+	return affine.Length(affine.Sub(p, closest))
+}
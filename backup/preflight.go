@@ -0,0 +1,64 @@
+package backup
+
+import (
+	"fmt"
+
+	"seedhammer.com/engrave"
+)
+
+// plateMargin is the minimum distance from engraved strokes to the
+// plate edges accepted by Preflight.
+const plateMargin = 1
+
+// PreflightError describes a plate side that engraves outside its plate
+// or moves outside the reach of the engraver.
+type PreflightError struct {
+	Size PlateSize
+	Side int
+	// Travel is set when the side moves outside the travel limits, and
+	// clear when its strokes extend beyond the plate margins.
+	Travel bool
+	// Extents are the bounds of the side and Limit the bounds it must
+	// fit, in machine coordinates.
+	Extents, Limit engrave.Rect
+}
+
+// Excess returns the largest distance the side extends beyond the limit.
+func (e *PreflightError) Excess() float32 {
+	return e.Extents.Excess(e.Limit)
+}
+
+func (e *PreflightError) Error() string {
+	what := "strokes"
+	if e.Travel {
+		what = "moves"
+	}
+	return fmt.Sprintf("backup: %s plate side %d: %s %v exceed %v by %.2f mm",
+		e.Size, e.Side, what, e.Extents, e.Limit, e.Excess())
+}
+
+// Preflight reports a *PreflightError if a stroke of the plate, widened
+// by half the stroke width, comes closer than a margin to the plate edges,
+// or if any point is outside travel. Travel is in machine coordinates.
+// Preflight reports a *KeepOutError if a widened stroke touches a
+// keep-out zone.
+func (p Plate) Preflight(strokeWidth float32, travel engrave.Rect) error {
+	limit := engrave.RectOf(p.Size.Bounds()).Inset(plateMargin)
+	for i, s := range p.Sides {
+		if err := CheckKeepOuts(p.Size, s, strokeWidth/2); err != nil {
+			err.(*KeepOutError).Side = i
+			return err
+		}
+		lines, all := engrave.Extents(s)
+		if !lines.Empty() {
+			lines = lines.Inset(-strokeWidth / 2)
+		}
+		if !lines.In(limit) {
+			return &PreflightError{Size: p.Size, Side: i, Extents: lines, Limit: limit}
+		}
+		if !all.In(travel) {
+			return &PreflightError{Size: p.Size, Side: i, Travel: true, Extents: all, Limit: travel}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,591 @@
+package backup
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/skip2/go-qrcode"
+	"golang.org/x/image/math/f32"
+	"seedhammer.com/engrave"
+	"seedhammer.com/seedqr"
+)
+
+// Template describes the layouts of the plates of a backup. Layouts
+// are tried in order, and the first layout that fits the backup is used.
+type Template struct {
+	Name    string   `json:"name"`
+	Layouts []Layout `json:"layouts"`
+}
+
+// Layout describes the sides of a plate of a particular size.
+type Layout struct {
+	Size PlateSize  `json:"size"`
+	Type LayoutType `json:"type"`
+	// Margin is the minimum distance between the engraving and the
+	// plate edges.
+	Margin float32 `json:"margin"`
+	Sides  []Side  `json:"sides"`
+}
+
+// LayoutType selects the backups a Layout applies to.
+type LayoutType string
+
+const (
+	// SeedLayout is for seed-only backups.
+	SeedLayout LayoutType = "seed"
+	// DescriptorLayout is for backups that include an output descriptor.
+	DescriptorLayout LayoutType = "descriptor"
+	// ContinuationLayout is for the additional plates of shares whose
+	// descriptor fragments don't fit a single plate.
+	ContinuationLayout LayoutType = "continuation"
+)
+
+// Side is the content of one plate side.
+type Side struct {
+	// MinWords is the minimum number of mnemonic words for the side
+	// to be included.
+	MinWords int `json:"min_words,omitempty"`
+	// Offset moves every element of the side, for example to avoid
+	// holes in the plate.
+	Offset   [2]float32 `json:"offset,omitempty"`
+	Elements []Element  `json:"elements"`
+}
+
+// ElementType is the kind of content of an Element.
+type ElementType string
+
+const (
+	// WordsElement is a column of mnemonic words.
+	WordsElement ElementType = "words"
+	// SeedQRElement is the mnemonic in compact SeedQR format.
+	SeedQRElement ElementType = "seedqr"
+	// URElement is the UR fragments of the descriptor, each in text and QR form.
+	// A UR element fills its side from the top.
+	URElement ElementType = "ur"
+	// TitleElement is the backup title.
+	TitleElement ElementType = "title"
+	// FingerprintElement is the master fingerprint of the share.
+	FingerprintElement ElementType = "fingerprint"
+	// PageElement is the share number and share count.
+	PageElement ElementType = "page"
+	// VersionElement is the backup scheme version.
+	VersionElement ElementType = "version"
+)
+
+// Origin is the reference point for the vertical position of an Element.
+type Origin string
+
+const (
+	// OriginPlate measures from the top of the plate.
+	OriginPlate Origin = ""
+	// OriginWordsTop measures from the top of the first words element
+	// of the side.
+	OriginWordsTop Origin = "words_top"
+	// OriginWordsBottom measures from the bottom of the first words
+	// element of the side.
+	OriginWordsBottom Origin = "words_bottom"
+)
+
+// Element is a piece of content placed on a plate side. Units are in
+// millimeters.
+type Element struct {
+	Type ElementType `json:"type"`
+	X    float32     `json:"x"`
+	Y    float32     `json:"y"`
+	// YOrigin is the reference point for Y.
+	YOrigin Origin `json:"y_origin,omitempty"`
+	// Anchor is the fraction of the measured element size subtracted
+	// from its position. For example, {.5, .5} centers an element on
+	// its position.
+	Anchor [2]float32 `json:"anchor,omitempty"`
+	// Rotation is in degrees, in the direction of engrave.Rotate.
+	Rotation float32 `json:"rotation,omitempty"`
+	// FontSize is the size of an em of text elements.
+	FontSize   float32 `json:"font_size,omitempty"`
+	LineHeight float32 `json:"line_height,omitempty"`
+	// Width is the width text elements wrap to. Zero means no
+	// wrapping.
+	Width float32 `json:"width,omitempty"`
+	// Align is the alignment of the lines of text elements, one of
+	// "start", "center", "end". The default is "start".
+	Align string `json:"align,omitempty"`
+	// MaxLines is the maximum number of lines of text elements. Zero
+	// means no limit. Text that doesn't fit Width and MaxLines fails
+	// the engraving.
+	MaxLines int `json:"max_lines,omitempty"`
+	// Start and End delimit the mnemonic words of a words element. End
+	// is exclusive, and zero means the end of the mnemonic.
+	Start int `json:"start,omitempty"`
+	End   int `json:"end,omitempty"`
+	// Scale is the number of strokes per QR module.
+	Scale int `json:"scale,omitempty"`
+	// Level is the QR error correction level, one of "low", "medium",
+	// "high", "highest". The default is "medium".
+	Level string `json:"level,omitempty"`
+	// Margin is the horizontal margin of UR elements.
+	Margin float32 `json:"margin,omitempty"`
+	// HoleMargin is the distance from the plate corners UR text is
+	// kept clear of.
+	HoleMargin float32 `json:"hole_margin,omitempty"`
+	// Border is the space around the UR QR codes.
+	Border float32 `json:"border,omitempty"`
+	// Spacing is the vertical space between UR fragments.
+	Spacing float32 `json:"spacing,omitempty"`
+}
+
+// ParseTemplate decodes and validates a JSON encoded template.
+func ParseTemplate(data []byte) (*Template, error) {
+	t := new(Template)
+	if err := json.Unmarshal(data, t); err != nil {
+		return nil, fmt.Errorf("backup: template: %w", err)
+	}
+	if err := t.Validate(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Validate reports whether the template is well-formed.
+func (t *Template) Validate() error {
+	if len(t.Layouts) == 0 {
+		return errors.New("backup: template has no layouts")
+	}
+	for i, l := range t.Layouts {
+		if err := l.validate(); err != nil {
+			return fmt.Errorf("backup: template %q: layout %d: %w", t.Name, i, err)
+		}
+	}
+	return nil
+}
+
+func (l *Layout) validate() error {
+	switch l.Size {
+	case SmallPlate, SquarePlate, LargePlate:
+	default:
+		return fmt.Errorf("invalid plate size %d", l.Size)
+	}
+	switch l.Type {
+	case SeedLayout, DescriptorLayout, ContinuationLayout:
+	default:
+		return fmt.Errorf("invalid layout type %q", l.Type)
+	}
+	if len(l.Sides) == 0 {
+		return errors.New("no sides")
+	}
+	for i, s := range l.Sides {
+		for j, e := range s.Elements {
+			if err := e.validate(); err != nil {
+				return fmt.Errorf("side %d: element %d: %w", i, j, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (e *Element) validate() error {
+	switch e.Type {
+	case WordsElement, TitleElement, FingerprintElement, PageElement, VersionElement:
+		if e.FontSize <= 0 {
+			return fmt.Errorf("%s: font_size must be positive", e.Type)
+		}
+	case SeedQRElement:
+		if e.Scale <= 0 {
+			return fmt.Errorf("%s: scale must be positive", e.Type)
+		}
+	case URElement:
+		if e.FontSize <= 0 || e.Scale <= 0 {
+			return fmt.Errorf("%s: font_size and scale must be positive", e.Type)
+		}
+	default:
+		return fmt.Errorf("unknown element type %q", e.Type)
+	}
+	if e.Width < 0 || e.MaxLines < 0 {
+		return fmt.Errorf("%s: width and max_lines must not be negative", e.Type)
+	}
+	if e.Start < 0 || e.End < 0 || (e.End != 0 && e.End < e.Start) {
+		return fmt.Errorf("%s: invalid word range [%d,%d)", e.Type, e.Start, e.End)
+	}
+	switch e.YOrigin {
+	case OriginPlate, OriginWordsTop, OriginWordsBottom:
+	default:
+		return fmt.Errorf("%s: invalid y_origin %q", e.Type, e.YOrigin)
+	}
+	if _, err := e.level(); err != nil {
+		return err
+	}
+	if _, err := e.alignment(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (e *Element) alignment() (engrave.Alignment, error) {
+	switch e.Align {
+	case "", "start":
+		return engrave.AlignStart, nil
+	case "center":
+		return engrave.AlignCenter, nil
+	case "end":
+		return engrave.AlignEnd, nil
+	}
+	return 0, fmt.Errorf("%s: invalid alignment %q", e.Type, e.Align)
+}
+
+func (e *Element) level() (qrcode.RecoveryLevel, error) {
+	switch e.Level {
+	case "low":
+		return qrcode.Low, nil
+	case "", "medium":
+		return qrcode.Medium, nil
+	case "high":
+		return qrcode.High, nil
+	case "highest":
+		return qrcode.Highest, nil
+	}
+	return 0, fmt.Errorf("%s: invalid QR level %q", e.Type, e.Level)
+}
+
+func (p PlateSize) String() string {
+	switch p {
+	case SmallPlate:
+		return "small"
+	case SquarePlate:
+		return "square"
+	case LargePlate:
+		return "large"
+	}
+	return fmt.Sprintf("PlateSize(%d)", int(p))
+}
+
+func (p PlateSize) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+func (p *PlateSize) UnmarshalText(text []byte) error {
+	for _, sz := range []PlateSize{SmallPlate, SquarePlate, LargePlate} {
+		if sz.String() == string(text) {
+			*p = sz
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown plate size %q", text)
+}
+
+// sideContext is the state for engraving the elements of a side.
+type sideContext struct {
+	strokeWidth float32
+	plate       PlateDesc
+	urs         []string
+	dims        f32.Vec2
+	// sheet is the plate number of the share, starting at 1,
+	// and sheets the number of plates of the share.
+	sheet, sheets int
+	// wordsTop and wordsBottom are the vertical extents of the first
+	// words element.
+	wordsTop, wordsBottom float32
+}
+
+// engrave returns the side and the content of its QR codes. It
+// returns an *engrave.OverflowError if the content of a text element
+// doesn't fit.
+func (s Side) engrave(ctx *sideContext) (engrave.Command, []string, error) {
+	for _, e := range s.Elements {
+		if e.Type != WordsElement {
+			continue
+		}
+		c, _ := ctx.command(e)
+		_, sz := dims(c)
+		ctx.wordsTop = e.Y - e.Anchor[1]*sz[1]
+		ctx.wordsBottom = ctx.wordsTop + sz[1]
+		break
+	}
+	var cmds engrave.Commands
+	var qrs []string
+	for i, e := range s.Elements {
+		c, err := ctx.command(e)
+		if err != nil {
+			return nil, nil, fmt.Errorf("element %d: %w", i, err)
+		}
+		switch e.Type {
+		case SeedQRElement:
+			qrs = append(qrs, string(seedqr.CompactQR(ctx.plate.Mnemonic)))
+		case URElement:
+			qrs = append(qrs, ctx.urs...)
+			// UR elements are laid out in plate coordinates.
+			cmds = append(cmds, c)
+			continue
+		}
+		_, sz := dims(c)
+		y := e.Y
+		switch e.YOrigin {
+		case OriginWordsTop:
+			y += ctx.wordsTop
+		case OriginWordsBottom:
+			y += ctx.wordsBottom
+		}
+		x := e.X - e.Anchor[0]*sz[0]
+		y -= e.Anchor[1] * sz[1]
+		cmds = append(cmds, engrave.Offset(x, y, c))
+	}
+	if off := s.Offset; off != [2]float32{} {
+		return engrave.Offset(off[0], off[1], cmds), qrs, nil
+	}
+	return cmds, qrs, nil
+}
+
+// command returns the content of e, before placement.
+func (ctx *sideContext) command(e Element) (engrave.Command, error) {
+	plate := ctx.plate
+	var err error
+	text := func(s string) engrave.Command {
+		align, _ := e.alignment()
+		txt := engrave.Text{
+			Face:       plate.Font,
+			Size:       e.FontSize,
+			LineHeight: e.LineHeight,
+			Alignment:  align,
+			Width:      e.Width,
+			MaxLines:   e.MaxLines,
+		}
+		b := txt.Layout(s)
+		err = b.Fit(0)
+		return b
+	}
+	var c engrave.Command
+	switch e.Type {
+	case WordsElement:
+		end := e.End
+		if end == 0 || end > len(plate.Mnemonic) {
+			end = len(plate.Mnemonic)
+		}
+		start := e.Start
+		if start > end {
+			start = end
+		}
+		c = wordColumn(plate.Font, e.FontSize, e.LineHeight, plate.Mnemonic, start, end)
+	case SeedQRElement:
+		lvl, _ := e.level()
+		c = engrave.QR(ctx.strokeWidth, e.Scale, lvl, seedqr.CompactQR(plate.Mnemonic))
+	case URElement:
+		c = descriptorSide(ctx.strokeWidth, plate.Font, ctx.urs, ctx.dims, e)
+	case TitleElement:
+		c = text(plate.Title)
+	case FingerprintElement:
+		c = text(fmt.Sprintf("%.8x", plate.Descriptor.Keys[plate.KeyIdx].MasterFingerprint))
+	case PageElement:
+		page := fmt.Sprintf("%d/%d", plate.KeyIdx+1, len(plate.Descriptor.Keys))
+		if ctx.sheets > 1 {
+			page = fmt.Sprintf("%s (%d/%d)", page, ctx.sheet, ctx.sheets)
+		}
+		c = text(page)
+	case VersionElement:
+		c = text(version)
+	default:
+		panic("unknown element type")
+	}
+	if e.Rotation != 0 {
+		c = engrave.Rotate(float32(float64(e.Rotation)*math.Pi/180), c)
+	}
+	return c, err
+}
+
+// DefaultTemplate is the layout of the SeedHammer plates.
+var DefaultTemplate = &Template{
+	Name: "default",
+	Layouts: []Layout{
+		{
+			Size:   SmallPlate,
+			Type:   SeedLayout,
+			Margin: outerMargin,
+			Sides: []Side{
+				seedBackSide(SmallPlate),
+				{
+					Elements: append(smallMeta(),
+						wordsElement(SmallPlate, 0, 12),
+						seedQRElement(SmallPlate),
+						smallTitle(),
+					),
+				},
+			},
+		},
+		{
+			Size:   SmallPlate,
+			Type:   DescriptorLayout,
+			Margin: outerMargin,
+			Sides: []Side{
+				urSide(outerMargin),
+				{
+					Elements: append(smallMeta(),
+						wordsElement(SmallPlate, 0, 16),
+						Element{Type: WordsElement, X: 44, YOrigin: OriginWordsTop, Start: 16, End: 20, FontSize: plateFontSize, LineHeight: plateLineHeight},
+						seedQRElement(SmallPlate),
+						smallTitle(),
+					),
+				},
+			},
+		},
+		{
+			Size:   SquarePlate,
+			Type:   SeedLayout,
+			Margin: outerMargin,
+			Sides:  []Side{seedBackSide(SquarePlate), frontSide(SquarePlate)},
+		},
+		{
+			Size:   SquarePlate,
+			Type:   DescriptorLayout,
+			Margin: outerMargin,
+			Sides:  []Side{urSide(outerMargin), frontSide(SquarePlate)},
+		},
+		{
+			Size:   LargePlate,
+			Type:   SeedLayout,
+			Margin: outerMargin,
+			Sides:  []Side{seedBackSide(LargePlate), frontSide(LargePlate)},
+		},
+		{
+			Size:   LargePlate,
+			Type:   DescriptorLayout,
+			Margin: outerMargin,
+			Sides:  []Side{urSide(innerMargin), frontSide(LargePlate)},
+		},
+		{
+			Size:   SquarePlate,
+			Type:   ContinuationLayout,
+			Margin: outerMargin,
+			Sides:  []Side{urSide(outerMargin), labelSide(SquarePlate)},
+		},
+		{
+			Size:   LargePlate,
+			Type:   ContinuationLayout,
+			Margin: outerMargin,
+			Sides:  []Side{urSide(innerMargin), labelSide(LargePlate)},
+		},
+	},
+}
+
+func plateHeight(size PlateSize) float32 {
+	_, h := size.dims()
+	return float32(h)
+}
+
+// wordsElement is a words element in the first column, centered
+// vertically.
+func wordsElement(size PlateSize, start, end int) Element {
+	return Element{
+		Type:       WordsElement,
+		X:          innerMargin,
+		Y:          plateHeight(size) / 2,
+		Anchor:     [2]float32{0, .5},
+		Start:      start,
+		End:        end,
+		FontSize:   plateFontSize,
+		LineHeight: plateLineHeight,
+	}
+}
+
+func seedQRElement(size PlateSize) Element {
+	return Element{
+		Type:   SeedQRElement,
+		X:      60,
+		Y:      plateHeight(size) / 2,
+		Anchor: [2]float32{.5, .5},
+		Scale:  3,
+		Level:  "high",
+	}
+}
+
+// smallMeta is the page, fingerprint and version along the left
+// edge of the small plate.
+func smallMeta() []Element {
+	h := plateHeight(SmallPlate)
+	return []Element{
+		{Type: PageElement, X: outerMargin, Y: h - innerMargin, Rotation: -90, FontSize: plateSmallFontSize},
+		{Type: FingerprintElement, X: outerMargin, Y: h / 2, Anchor: [2]float32{0, -.5}, Rotation: -90, FontSize: plateSmallFontSize},
+		{Type: VersionElement, X: outerMargin, Y: innerMargin, Anchor: [2]float32{0, -1}, Rotation: -90, FontSize: plateSmallFontSize},
+	}
+}
+
+// smallTitle is the title along the right edge of the small plate.
+func smallTitle() Element {
+	w, h := SmallPlate.dims()
+	return Element{
+		Type:     TitleElement,
+		X:        float32(w) - outerMargin,
+		Y:        float32(h) / 2,
+		Anchor:   [2]float32{1, -.5},
+		Rotation: -90,
+		FontSize: plateSmallFontSize,
+	}
+}
+
+// frontSide is the seed side of the square and large plates, with
+// metadata above the words and the title below.
+func frontSide(size PlateSize) Side {
+	w, _ := size.dims()
+	const metaMargin = 4
+	s := Side{
+		Elements: []Element{
+			{Type: PageElement, X: innerMargin, Y: -metaMargin, YOrigin: OriginWordsTop, Anchor: [2]float32{0, 1}, FontSize: plateSmallFontSize},
+			{Type: FingerprintElement, X: float32(w) / 2, Y: -metaMargin, YOrigin: OriginWordsTop, Anchor: [2]float32{.5, 1}, FontSize: plateSmallFontSize},
+			{Type: VersionElement, X: float32(w) - innerMargin, Y: -metaMargin, YOrigin: OriginWordsTop, Anchor: [2]float32{1, 1}, FontSize: plateSmallFontSize},
+			wordsElement(size, 0, 16),
+			{Type: WordsElement, X: 44, YOrigin: OriginWordsTop, Start: 16, End: 20, FontSize: plateFontSize, LineHeight: plateLineHeight},
+			seedQRElement(size),
+			{Type: WordsElement, X: 44, YOrigin: OriginWordsBottom, Anchor: [2]float32{0, 1}, Start: 20, FontSize: plateFontSize, LineHeight: plateLineHeight},
+			{Type: TitleElement, X: float32(w) / 2, Y: metaMargin, YOrigin: OriginWordsBottom, Anchor: [2]float32{.5, 0}, FontSize: plateSmallFontSize},
+		},
+	}
+	if size == LargePlate {
+		// Avoid the middle holes.
+		s.Offset = [2]float32{0, 24.5}
+	}
+	return s
+}
+
+// seedBackSide is the side for the words that don't fit the front of
+// the small plate.
+func seedBackSide(size PlateSize) Side {
+	col1 := wordsElement(size, 12, 18)
+	col1.X = 9
+	return Side{
+		MinWords: 13,
+		Elements: []Element{
+			col1,
+			{Type: WordsElement, X: 44, YOrigin: OriginWordsTop, Start: 18, FontSize: plateFontSize, LineHeight: plateLineHeight},
+		},
+	}
+}
+
+// labelSide identifies a continuation plate.
+func labelSide(size PlateSize) Side {
+	w, h := size.dims()
+	const spacing = 3
+	x, y := float32(w)/2, float32(h)/2
+	return Side{
+		Elements: []Element{
+			{Type: TitleElement, X: x, Y: y - spacing, Anchor: [2]float32{.5, 1}, FontSize: plateFontSize},
+			{Type: FingerprintElement, X: x, Y: y, Anchor: [2]float32{.5, 0}, FontSize: plateFontSize},
+			{Type: PageElement, X: x, Y: y + plateFontSize + spacing, Anchor: [2]float32{.5, 0}, FontSize: plateFontSize},
+			{Type: VersionElement, X: x, Y: float32(h) - innerMargin, Anchor: [2]float32{.5, 1}, FontSize: plateSmallFontSize},
+		},
+	}
+}
+
+func urSide(margin float32) Side {
+	return Side{
+		Elements: []Element{
+			{
+				Type:       URElement,
+				Y:          outerMargin,
+				FontSize:   plateFontSizeUR,
+				Scale:      2,
+				Margin:     margin,
+				HoleMargin: innerMargin,
+				Border:     2,
+				Spacing:    1,
+			},
+		},
+	}
+}
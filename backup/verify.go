@@ -0,0 +1,162 @@
+package backup
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"reflect"
+	"strings"
+
+	"seedhammer.com/bc/ur"
+	"seedhammer.com/bc/urtypes"
+	"seedhammer.com/engrave"
+	"seedhammer.com/seedqr"
+	"seedhammer.com/zbar"
+)
+
+// verifyPPMM is the resolution, in pixels per millimeter, of the rasterized
+// sides scanned by Verify.
+const verifyPPMM = 8
+
+// VerifyError describes a side whose QR codes don't scan back to
+// the plate content.
+type VerifyError struct {
+	Plate  int
+	Side   int
+	Reason string
+}
+
+func (e *VerifyError) Error() string {
+	return fmt.Sprintf("backup: plate %d side %d failed verification: %s", e.Plate, e.Side, e.Reason)
+}
+
+// Verify rasterizes every side of the plates of a share at the given
+// stroke width and checks that their QR codes scan and decode to the
+// mnemonic and descriptor of desc. The scanned descriptor fragments are
+// compared with the fragments of the share, and the descriptor itself
+// is compared if the plates contain enough fragments to recover it.
+func Verify(strokeWidth float32, desc PlateDesc, plates ...Plate) error {
+	v := &verifier{strokeWidth: strokeWidth, desc: desc}
+	for i, p := range plates {
+		if err := v.plate(i, p); err != nil {
+			return err
+		}
+	}
+	if desc.Descriptor.Type == urtypes.UnknownScript {
+		return nil
+	}
+	if v.urSide == nil {
+		return &VerifyError{Reason: "no descriptor fragments"}
+	}
+	fail := func(reason string) error {
+		err := *v.urSide
+		err.Reason = reason
+		return &err
+	}
+	if err := matchQRs(splitUR(desc.Descriptor, desc.KeyIdx), v.urs); err != "" {
+		return fail(err)
+	}
+	typ, enc, err := v.dec.Result()
+	if err != nil {
+		return fail(err.Error())
+	}
+	if enc == nil {
+		// Not enough fragments for recovering the descriptor.
+		return nil
+	}
+	got, err := urtypes.Parse(typ, enc)
+	if err != nil {
+		return fail(err.Error())
+	}
+	if !reflect.DeepEqual(got, desc.Descriptor) {
+		return fail("decoded descriptor doesn't match")
+	}
+	return nil
+}
+
+type verifier struct {
+	strokeWidth float32
+	desc        PlateDesc
+	dec         ur.Decoder
+	// urs are the scanned UR fragments.
+	urs [][]byte
+	// urSide is the last side with UR fragments.
+	urSide *VerifyError
+}
+
+func (v *verifier) plate(idx int, p Plate) error {
+	for i, side := range p.Sides {
+		var want []string
+		if i < len(p.QRs) {
+			want = p.QRs[i]
+		}
+		if len(want) == 0 {
+			continue
+		}
+		fail := func(reason string) error {
+			return &VerifyError{Plate: idx, Side: i, Reason: reason}
+		}
+		scanned, err := zbar.ScanImage(rasterizeSide(v.strokeWidth, p.Size, side))
+		if err != nil {
+			return fail(err.Error())
+		}
+		if err := matchQRs(want, scanned); err != "" {
+			return fail(err)
+		}
+		for _, qr := range scanned {
+			if strings.HasPrefix(strings.ToUpper(string(qr)), "UR:") {
+				if err := v.dec.Add(string(qr)); err != nil {
+					return fail(err.Error())
+				}
+				v.urs = append(v.urs, qr)
+				v.urSide = &VerifyError{Plate: idx, Side: i}
+				continue
+			}
+			m, ok := seedqr.Parse(qr)
+			if !ok {
+				return fail("invalid SeedQR")
+			}
+			if !reflect.DeepEqual(m, v.desc.Mnemonic) {
+				return fail("SeedQR doesn't match the mnemonic")
+			}
+		}
+	}
+	return nil
+}
+
+// matchQRs describes the difference between the expected and
+// scanned QR contents, or returns the empty string if they match.
+func matchQRs(want []string, scanned [][]byte) string {
+	missing := make(map[string]int)
+	for _, w := range want {
+		missing[w]++
+	}
+	for _, s := range scanned {
+		if missing[string(s)] == 0 {
+			return fmt.Sprintf("unexpected QR code %.20q", s)
+		}
+		missing[string(s)]--
+	}
+	for _, w := range want {
+		if missing[w] > 0 {
+			return fmt.Sprintf("QR code %.20q didn't scan", w)
+		}
+	}
+	return ""
+}
+
+// rasterizeSide renders a side in machine coordinates to a
+// white image with black strokes.
+func rasterizeSide(strokeWidth float32, size PlateSize, side engrave.Command) *image.Gray {
+	bounds := size.Bounds()
+	bounds = image.Rectangle{
+		Min: bounds.Min.Mul(verifyPPMM),
+		Max: bounds.Max.Mul(verifyPPMM),
+	}
+	img := image.NewGray(bounds)
+	draw.Draw(img, bounds, image.White, image.Point{}, draw.Src)
+	r := engrave.NewRasterizer(img, bounds, strokeWidth*verifyPPMM)
+	engrave.Scale(verifyPPMM, verifyPPMM, side).Engrave(r)
+	r.Rasterize()
+	return img
+}
@@ -110,6 +110,22 @@ func (m Mnemonic) FixChecksum() Mnemonic {
 	return m2
 }
 
+// FinalWords returns every word that completes a mnemonic missing
+// only its final word, in increasing order. The partial mnemonic
+// must have 11, 14, 17, 20 or 23 words.
+func FinalWords(partial Mnemonic) []Word {
+	m := make(Mnemonic, len(partial)+1)
+	copy(m, partial)
+	const wordBits = 11
+	checkBits := len(m) / 3
+	var words []Word
+	for i := 0; i < 1<<(wordBits-checkBits); i++ {
+		m[len(m)-1] = Word(i << checkBits)
+		words = append(words, m.FixChecksum()[len(m)-1])
+	}
+	return words
+}
+
 // Entropy returns the entropy represented by the mnemonic. It
 // panics if the mnemonic is invalid.
 func (m Mnemonic) Entropy() []byte {
@@ -44,6 +44,27 @@ func TestChecksumWord(t *testing.T) {
 	}
 }
 
+func TestFinalWords(t *testing.T) {
+	for _, n := range []int{12, 24} {
+		partial := make(Mnemonic, n-1)
+		for i := range partial {
+			partial[i] = RandomWord()
+		}
+		words := FinalWords(partial)
+		if want := 1 << (11 - n/3); len(words) != want {
+			t.Errorf("%d words: got %d final words, want %d", n, len(words), want)
+		}
+		for i, w := range words {
+			if i > 0 && words[i-1] >= w {
+				t.Errorf("%d words: final words not increasing", n)
+			}
+			if m := append(append(Mnemonic(nil), partial...), w); !m.Valid() {
+				t.Errorf("%d words: final word %s is invalid", n, LabelFor(w))
+			}
+		}
+	}
+}
+
 var testVectors = []struct {
 	entropy  string
 	mnemonic string
@@ -3,6 +3,8 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"image"
@@ -28,21 +30,70 @@ var (
 	dryrun    = flag.Bool("n", false, "dry run")
 	output    = flag.String("o", "plates", "output plates to directory")
 	side      = flag.String("side", "front", "plate side, front or back")
+	sheet     = flag.Int("plate", 0, "plate number, for shares that span several plates")
 	threshold = flag.Int("threshold", 2, "threshold")
 	shares    = flag.Int("shares", 3, "number of shares in total")
 	seedonly  = flag.Bool("seedonly", false, "seed-only mode")
 	mnemonic  = flag.String("mnemonic", "flip begin artist fringe online release swift genre wool general transfer arm", "mnemonic")
+	template  = flag.String("template", "", "plate layout template (JSON)")
+	printTmpl = flag.Bool("print-template", false, "print the default template and exit")
+	listDevs  = flag.Bool("list-devices", false, "list serial devices and exit")
+	passes    = flag.Int("passes", 1, "number of times each line is engraved")
+	qrPasses  = flag.Int("qr-passes", 0, "number of times each QR code line is engraved (0 means -passes)")
+	penDown   = flag.Int("pen-down-delay", 0, "needle down delay, 1-255 (0 means default)")
+	penUp     = flag.Int("pen-up-delay", 0, "needle up delay, 1-255 (0 means default)")
+	record    = flag.String("record", "", "write the engraver program to file instead of engraving")
+	replay    = flag.String("replay", "", "engrave a program file written by -record")
 )
 
 func main() {
 	flag.Parse()
+	if *listDevs {
+		cands, err := mjolnir.Discover("/sys", mjolnir.KnownIDs)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		for _, c := range cands {
+			fmt.Println(c)
+		}
+		return
+	}
+	if *replay != "" {
+		if err := replayFile(*replay, *serialDev); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if *printTmpl {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "\t")
+		if err := enc.Encode(backup.DefaultTemplate); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
 	m, err := bip39.ParseMnemonic(*mnemonic)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "invalid mnemonic: %v\n", err)
 		os.Exit(1)
 	}
 	plateDesc := genPlate(m)
-	if *serialDev != "" {
+	if *template != "" {
+		data, err := os.ReadFile(*template)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		plateDesc.Template, err = backup.ParseTemplate(data)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+	}
+	if *serialDev != "" || *record != "" {
 		var s int
 		switch *side {
 		case "back":
@@ -53,7 +104,11 @@ func main() {
 			fmt.Fprintf(os.Stderr, "-side must be 'front' or 'back'\n")
 			os.Exit(1)
 		}
-		err = hammer(plateDesc, s, *serialDev)
+		if *record != "" {
+			err = recordFile(plateDesc, *sheet, s, *record)
+		} else {
+			err = hammer(plateDesc, *sheet, s, *serialDev)
+		}
 	} else {
 		if err := os.MkdirAll(*output, 0o755); err != nil {
 			fmt.Fprintf(os.Stderr, "%v\n", err)
@@ -72,28 +127,37 @@ func dump(plateDesc backup.PlateDesc, output string) error {
 	for i := range plateDesc.Descriptor.Keys {
 		desc := plateDesc
 		desc.KeyIdx = i
-		plate, err := backup.Engrave(mjolnir.StrokeWidth, desc)
+		plates, err := backup.EngraveShare(mjolnir.StrokeWidth, desc)
 		if err != nil {
 			return err
 		}
-		bounds := plate.Size.Bounds()
-		bounds = image.Rectangle{
-			Min: bounds.Min.Mul(ppmm),
-			Max: bounds.Max.Mul(ppmm),
-		}
-		for s := range plate.Sides {
-			img := image.NewNRGBA(bounds)
-			r := engrave.NewRasterizer(img, img.Bounds(), mjolnir.StrokeWidth*ppmm)
-			se := engrave.Scale(ppmm, ppmm, plate.Sides[s])
-			se.Engrave(r)
-			r.Rasterize()
-			buf := new(bytes.Buffer)
-			if err := png.Encode(buf, img); err != nil {
-				return err
+		if err := backup.Verify(mjolnir.StrokeWidth, desc, plates...); err != nil {
+			return err
+		}
+		for j, plate := range plates {
+			bounds := plate.Size.Bounds()
+			bounds = image.Rectangle{
+				Min: bounds.Min.Mul(ppmm),
+				Max: bounds.Max.Mul(ppmm),
 			}
-			file := filepath.Join(output, fmt.Sprintf("plate-%d-side-%d.png", i, s))
-			if err := os.WriteFile(file, buf.Bytes(), 0o644); err != nil {
-				return err
+			for s := range plate.Sides {
+				img := image.NewNRGBA(bounds)
+				r := engrave.NewRasterizer(img, img.Bounds(), mjolnir.StrokeWidth*ppmm)
+				se := engrave.Scale(ppmm, ppmm, plate.Sides[s])
+				se.Engrave(r)
+				r.Rasterize()
+				buf := new(bytes.Buffer)
+				if err := png.Encode(buf, img); err != nil {
+					return err
+				}
+				name := fmt.Sprintf("plate-%d-side-%d.png", i, s)
+				if len(plates) > 1 {
+					name = fmt.Sprintf("plate-%d-%d-side-%d.png", i, j, s)
+				}
+				file := filepath.Join(output, name)
+				if err := os.WriteFile(file, buf.Bytes(), 0o644); err != nil {
+					return err
+				}
 			}
 		}
 	}
@@ -155,39 +219,96 @@ func genPlate(m0 bip39.Mnemonic) backup.PlateDesc {
 	return plate
 }
 
-func hammer(plateDesc backup.PlateDesc, side int, dev string) error {
-	plate, err := backup.Engrave(mjolnir.StrokeWidth, plateDesc)
+func hammer(plateDesc backup.PlateDesc, sheet, side int, dev string) error {
+	prog, _, err := program(plateDesc, sheet, side)
+	if err != nil {
+		return err
+	}
+	return run(prog, dev)
+}
+
+// recordFile writes the program for a plate side to a program file.
+func recordFile(plateDesc backup.PlateDesc, sheet, side int, name string) error {
+	prog, plate, err := program(plateDesc, sheet, side)
+	if err != nil {
+		return err
+	}
+	f, err := mjolnir.NewFile(prog, plate.Size.Bounds())
+	if err != nil {
+		return err
+	}
+	buf := new(bytes.Buffer)
+	if err := f.Encode(buf); err != nil {
+		return err
+	}
+	return os.WriteFile(name, buf.Bytes(), 0o644)
+}
+
+// replayFile engraves a program file.
+func replayFile(name, dev string) error {
+	data, err := os.ReadFile(name)
 	if err != nil {
 		return err
 	}
+	f, err := mjolnir.DecodeFile(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	if f.StrokeWidth != mjolnir.StrokeWidth {
+		return fmt.Errorf("%s: stroke width %gmm doesn't match the engraver", name, f.StrokeWidth)
+	}
+	prog, err := f.Program()
+	if err != nil {
+		return err
+	}
+	prog.DryRun = *dryrun
+	return run(prog, dev)
+}
+
+// program returns the engraver program for a plate side.
+func program(plateDesc backup.PlateDesc, sheet, side int) (*mjolnir.Program, backup.Plate, error) {
+	plates, err := backup.EngraveShare(mjolnir.StrokeWidth, plateDesc)
+	if err != nil {
+		return nil, backup.Plate{}, err
+	}
+	if err := backup.Verify(mjolnir.StrokeWidth, plateDesc, plates...); err != nil {
+		return nil, backup.Plate{}, err
+	}
+	if sheet >= len(plates) {
+		return nil, backup.Plate{}, fmt.Errorf("no such plate: %d", sheet)
+	}
+	plate := plates[sheet]
 	if side >= len(plate.Sides) {
-		return fmt.Errorf("no such side: %d", side)
+		return nil, backup.Plate{}, fmt.Errorf("no such side: %d", side)
 	}
+	prog, err := mjolnir.NewProgram(plate.Sides[side])
+	if err != nil {
+		return nil, backup.Plate{}, err
+	}
+	prog.DryRun = *dryrun
+	prog.Passes = *passes
+	if *qrPasses > 0 {
+		prog.RegionPasses = map[engrave.Region]int{engrave.RegionQR: *qrPasses}
+	}
+	prog.PenDownDelay = *penDown
+	prog.PenUpDelay = *penUp
+	return prog, plate, nil
+}
+
+// run engraves a program on the engraver connected to dev.
+func run(prog *mjolnir.Program, dev string) error {
 	s, err := mjolnir.Open(dev)
 	if err != nil {
 		return err
 	}
 	defer s.Close()
 
-	prog := &mjolnir.Program{
-		DryRun: *dryrun,
-	}
-	plate.Sides[side].Engrave(prog)
-	prog.Prepare()
-	quit := make(chan os.Signal, 1)
-	cancel := make(chan struct{})
-	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
-	engraveErr := make(chan error)
-	go func() {
-		<-quit
-		signal.Reset(os.Interrupt)
-		close(cancel)
-		<-engraveErr
-		os.Exit(1)
-	}()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 	go func() {
-		engraveErr <- mjolnir.Engrave(s, prog, nil, cancel)
+		// Restore the default behaviour for a second interrupt.
+		<-ctx.Done()
+		stop()
 	}()
-	plate.Sides[side].Engrave(prog)
-	return <-engraveErr
+	return mjolnir.Engrave(ctx, s, prog, nil, nil)
 }
@@ -3,12 +3,12 @@
 package main
 
 import (
-	"io"
 	"log"
 	"os"
 	"runtime/pprof"
 	"strings"
 
+	"seedhammer.com/engrave"
 	"seedhammer.com/input"
 	"seedhammer.com/mjolnir"
 )
@@ -24,8 +24,13 @@ func (p *Platform) Input(ch chan<- input.Event) error {
 	return input.Open(ch)
 }
 
-func (p *Platform) Engraver() (io.ReadWriteCloser, error) {
-	return mjolnir.NewSimulator(), nil
+func (p *Platform) Engraver() (engrave.Engraver, error) {
+	return mjolnir.NewEngraver(mjolnir.NewSimulator()), nil
+}
+
+// FailedTrace returns nil, because the simulated engraver is not traced.
+func (p *Platform) FailedTrace() []byte {
+	return nil
 }
 
 func newPlatform() *Platform {
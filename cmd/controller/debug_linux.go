@@ -87,35 +87,25 @@ func writeReloader(s io.Reader, binFile string, size int64) (ferr error) {
 	return err
 }
 
-func (p *Platform) Dump(path string, r io.Reader) (ferr error) {
-	const mntDir = "/mnt"
-	if err := os.MkdirAll(mntDir, 0o644); err != nil {
-		return fmt.Errorf("mkdir %s: %w", mntDir, err)
-	}
-	if err := syscall.Mount("/dev/mmcblk0p1", mntDir, "vfat", 0, ""); err != nil {
-		return fmt.Errorf("mount /dev/mmcblk0p1: %w", err)
-	}
-	defer func() {
-		if err := syscall.Unmount(mntDir, 0); ferr == nil {
-			ferr = err
+func (p *Platform) Dump(path string, r io.Reader) error {
+	return withSDCard(func(dir string) (ferr error) {
+		path = filepath.Join(dir, path)
+		dir = filepath.Dir(path)
+		if err := os.MkdirAll(dir, 0o644); err != nil {
+			return fmt.Errorf("mkdir %s: %w", dir, err)
 		}
-	}()
-	path = filepath.Join(mntDir, path)
-	dir := filepath.Dir(path)
-	if err := os.MkdirAll(dir, 0o644); err != nil {
-		return fmt.Errorf("mkdir %s: %w", dir, err)
-	}
-	f, err := os.Create(path)
-	if err != nil {
-		return err
-	}
-	defer func() {
-		if err := f.Close(); ferr == nil {
-			ferr = err
+		f, err := os.Create(path)
+		if err != nil {
+			return err
 		}
-	}()
-	_, err = io.Copy(f, r)
-	return err
+		defer func() {
+			if err := f.Close(); ferr == nil {
+				ferr = err
+			}
+		}()
+		_, err = io.Copy(f, r)
+		return err
+	})
 }
 
 func openSerial(path string) (s *os.File, err error) {
@@ -2,6 +2,8 @@ package main
 
 import (
 	"image"
+	"os"
+	"path/filepath"
 	"time"
 
 	"seedhammer.com/camera"
@@ -22,3 +24,25 @@ func (p *Platform) Now() time.Time {
 func (p *Platform) SDCard() <-chan bool {
 	return sdcard
 }
+
+// LoadFile reads a file from the microSD card.
+func (p *Platform) LoadFile(path string) ([]byte, error) {
+	var data []byte
+	err := withSDCard(func(dir string) error {
+		var err error
+		data, err = os.ReadFile(filepath.Join(dir, path))
+		return err
+	})
+	return data, err
+}
+
+// StoreFile writes a file to the microSD card.
+func (p *Platform) StoreFile(path string, data []byte) error {
+	return withSDCard(func(dir string) error {
+		path := filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		return os.WriteFile(path, data, 0o644)
+	})
+}
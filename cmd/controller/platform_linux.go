@@ -80,6 +80,26 @@ func initSDCardNotifier() error {
 	return nil
 }
 
+// withSDCard mounts the SD card for the duration of f. Production
+// builds need it for the engraver calibration, which is loaded when a
+// card is inserted and stored only by the calibration screen, which
+// runs without a seed in memory.
+func withSDCard(f func(dir string) error) (ferr error) {
+	const mntDir = "/mnt"
+	if err := os.MkdirAll(mntDir, 0o644); err != nil {
+		return fmt.Errorf("mkdir %s: %w", mntDir, err)
+	}
+	if err := syscall.Mount("/dev/mmcblk0p1", mntDir, "vfat", 0, ""); err != nil {
+		return fmt.Errorf("mount /dev/mmcblk0p1: %w", err)
+	}
+	defer func() {
+		if err := syscall.Unmount(mntDir, 0); ferr == nil {
+			ferr = err
+		}
+	}()
+	return f(mntDir)
+}
+
 func mountFS() error {
 	devices := []struct {
 		path string
@@ -2,7 +2,10 @@
 
 package main
 
-import "log"
+import (
+	"errors"
+	"log"
+)
 
 func Init() error {
 	if err := dbgInit(); err != nil {
@@ -10,3 +13,7 @@ func Init() error {
 	}
 	return nil
 }
+
+func withSDCard(f func(dir string) error) error {
+	return errors.New("SD card not supported")
+}
@@ -3,9 +3,15 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"io"
+	"log"
+	"sync"
+	"time"
 
+	"seedhammer.com/engrave"
 	"seedhammer.com/input"
 	"seedhammer.com/mjolnir"
 )
@@ -16,14 +22,65 @@ func dbgInit() error {
 	return nil
 }
 
-type Platform struct{}
+type Platform struct {
+	mu sync.Mutex
+	// failedTrace is the encoded trace of the last failed engraving.
+	failedTrace []byte
+}
 
 func (p *Platform) Input(ch chan<- input.Event) error {
 	return input.Open(ch)
 }
 
-func (p *Platform) Engraver() (io.ReadWriteCloser, error) {
-	return mjolnir.Open("")
+func (p *Platform) Engraver() (engrave.Engraver, error) {
+	dev, err := mjolnir.Open("")
+	if err != nil {
+		return nil, err
+	}
+	// Traces are redacted to not reveal the engraved secrets, and
+	// bounded to not keep every reply of a job in memory.
+	rec := mjolnir.NewTraceRecorder(dev, true)
+	rec.Limit = traceEvents
+	return &tracedEngraver{Engraver: mjolnir.NewEngraver(rec), rec: rec, p: p}, nil
+}
+
+func (p *Platform) FailedTrace() []byte {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	t := p.failedTrace
+	p.failedTrace = nil
+	return t
+}
+
+// traceEvents is the number of events kept in the trace of a job.
+const traceEvents = 1000
+
+// tracedEngraver records the communication with an engraver, and keeps
+// the trace of a failed job in memory. The trace is only stored on the
+// microSD card if the user chooses to.
+type tracedEngraver struct {
+	*mjolnir.Engraver
+	rec *mjolnir.TraceRecorder
+	p   *Platform
+}
+
+func (t *tracedEngraver) Run(ctx context.Context, job *engrave.Job) error {
+	err := t.Engraver.Run(ctx, job)
+	// Drop the recording once the job is over; only the encoded trace
+	// of a failed job is kept.
+	defer t.rec.Reset()
+	if err == nil || ctx.Err() != nil {
+		return err
+	}
+	buf := new(bytes.Buffer)
+	if err := t.rec.Trace().Encode(buf); err != nil {
+		log.Printf("trace: %v", err)
+	} else {
+		t.p.mu.Lock()
+		t.p.failedTrace = buf.Bytes()
+		t.p.mu.Unlock()
+	}
+	return err
 }
 
 func (p *Platform) Dump(path string, r io.Reader) error {
@@ -31,5 +88,13 @@ func (p *Platform) Dump(path string, r io.Reader) error {
 }
 
 func newPlatform() *Platform {
+	go logDevices()
 	return new(Platform)
 }
+
+// logDevices logs the serial devices as they are plugged in and out.
+func logDevices() {
+	for cands := range mjolnir.Watch(context.Background(), "/sys", mjolnir.KnownIDs, 2*time.Second) {
+		log.Printf("serial devices: %v", cands)
+	}
+}
@@ -0,0 +1,82 @@
+// command trace prints and replays traces of the communication with the
+// SeedHammer engraver.
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"seedhammer.com/mjolnir"
+)
+
+var (
+	replay  = flag.Bool("replay", false, "replay the trace against the engraver driver")
+	program = flag.String("program", "", "program file of the traced engraving (default: reconstruct from the trace)")
+	timing  = flag.Bool("timing", false, "replay with the traced reply delays")
+	quiet   = flag.Bool("q", false, "don't print the decoded trace")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: trace [flags] file.trace\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := run(flag.Arg(0)); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	trace, err := mjolnir.DecodeTrace(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	if !*quiet {
+		if err := mjolnir.FormatTrace(os.Stdout, trace); err != nil {
+			return err
+		}
+	}
+	if !*replay {
+		return nil
+	}
+	var prog *mjolnir.Program
+	if *program != "" {
+		data, err := os.ReadFile(*program)
+		if err != nil {
+			return err
+		}
+		pf, err := mjolnir.DecodeFile(bytes.NewReader(data))
+		if err != nil {
+			return fmt.Errorf("%s: %w", *program, err)
+		}
+		prog, err = pf.Program()
+		if err != nil {
+			return err
+		}
+	} else {
+		prog, err = trace.Program()
+		if err != nil {
+			return err
+		}
+	}
+	dev := mjolnir.NewTraceDevice(trace)
+	dev.Timing = *timing
+	defer dev.Close()
+	err = mjolnir.Engrave(context.Background(), dev, prog, nil, nil)
+	fmt.Printf("replay: %d of %d trace events left, engraving returned: %v\n", dev.Remaining(), len(trace.Events), err)
+	return nil
+}
@@ -29,6 +29,13 @@ func (c Commands) Engrave(p Program) {
 	}
 }
 
+// CommandFunc adapts a function to a Command.
+type CommandFunc func(p Program)
+
+func (f CommandFunc) Engrave(p Program) {
+	f(p)
+}
+
 // Program is an interface to output an engraving.
 // Units are in millimeters.
 type Program interface {
@@ -36,6 +43,35 @@ type Program interface {
 	Line(p f32.Vec2)
 }
 
+// Region identifies the kind of shape being engraved, for engravers that
+// tune their settings per shape.
+type Region int
+
+const (
+	RegionNone Region = iota
+	RegionQR
+	RegionText
+)
+
+// RegionProgram is implemented by programs that distinguish regions.
+type RegionProgram interface {
+	Program
+	// SetRegion marks the following commands as part of region r, and
+	// returns the previous region.
+	SetRegion(r Region) Region
+}
+
+// enterRegion marks the following commands of p as part of region r
+// and returns a function for restoring the previous region.
+func enterRegion(p Program, r Region) func() {
+	rp, ok := p.(RegionProgram)
+	if !ok {
+		return func() {}
+	}
+	prev := rp.SetRegion(r)
+	return func() { rp.SetRegion(prev) }
+}
+
 type transformedProgram struct {
 	prog  Program
 	trans f32.Aff3
@@ -50,6 +86,13 @@ func (t *transformedProgram) Line(p f32.Vec2) {
 	t.prog.Line(affine.Transform(t.trans, p))
 }
 
+func (t *transformedProgram) SetRegion(r Region) Region {
+	if rp, ok := t.prog.(RegionProgram); ok {
+		return rp.SetRegion(r)
+	}
+	return RegionNone
+}
+
 func TransformedProgram(prog Program, transform f32.Aff3) Program {
 	return &transformedProgram{
 		prog:  prog,
@@ -105,6 +148,7 @@ type qrCmd struct {
 }
 
 func (q qrCmd) Engrave(p Program) {
+	defer enterRegion(p, RegionQR)()
 	qr, err := qrcode.New(string(q.content), q.level)
 	if err != nil {
 		panic(err)
@@ -165,9 +209,12 @@ type StringCmd struct {
 	face   *font.Face
 	mmPrEm float32
 	msg    string
+	// advance overrides the glyph advances, if non-zero.
+	advance float32
 }
 
 func (s *StringCmd) Engrave(p Program) {
+	defer enterRegion(p, RegionText)()
 	ppem := float32(s.mmPrEm)
 	pos := f32.Vec2{0, s.face.Metrics.Ascent * ppem}
 	for _, r := range s.msg {
@@ -209,7 +256,11 @@ func (s *StringCmd) Engrave(p Program) {
 				panic(errors.New("unsupported segment"))
 			}
 		}
-		pos[0] += adv * ppem
+		if s.advance != 0 {
+			pos[0] += s.advance
+		} else {
+			pos[0] += adv * ppem
+		}
 	}
 }
 
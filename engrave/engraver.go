@@ -0,0 +1,139 @@
+package engrave
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"io"
+	"time"
+
+	"golang.org/x/image/math/f32"
+)
+
+// Engraver is a connection to an engraving machine.
+type Engraver interface {
+	// Capabilities describes the machine.
+	Capabilities() Capabilities
+	// Home moves the needle to the machine origin.
+	Home(ctx context.Context) error
+	// Run engraves a job and returns when the job completes, fails, or
+	// ctx is cancelled.
+	Run(ctx context.Context, job *Job) error
+	// Close the connection.
+	Close() error
+}
+
+// Diagnoser is an Engraver that supports diagnosing the machine
+// manually.
+type Diagnoser interface {
+	Engraver
+	// Position returns the position of the needle, in millimeters.
+	Position(ctx context.Context) (f32.Vec2, error)
+	// Jog moves the needle to p without engraving, and returns the
+	// position reported by the machine. The machine is homed first if
+	// needed, and stays ready for the following jogs. Jog returns a
+	// *BoundsError if p is out of reach.
+	Jog(ctx context.Context, p f32.Vec2) (f32.Vec2, error)
+	// Record starts recording the communication with the machine. It
+	// must not be called concurrently with other methods.
+	Record()
+	// Trace writes the recorded communication in human readable form.
+	Trace(w io.Writer) error
+}
+
+// Capabilities describes an engraving machine.
+type Capabilities struct {
+	// WorkArea is the reachable area, in millimeters.
+	WorkArea image.Rectangle
+	// StrokeWidth is the width of engraved lines, in millimeters.
+	StrokeWidth float32
+	// Pause is set if jobs can be paused.
+	Pause bool
+	// Resume is set if jobs can skip completed commands.
+	Resume bool
+}
+
+// Job describes an engraving.
+type Job struct {
+	Design Command
+	// DryRun moves the needle without engraving.
+	DryRun bool
+	// Skip is the number of design commands to skip, for resuming an
+	// interrupted job.
+	Skip int
+	// Progress, if not nil, is called with the fraction of the job
+	// completed.
+	Progress func(float32)
+	// Pause receives true to pause the job and false to resume it.
+	Pause <-chan bool
+	// Paused, if not nil, is called with true when the job has stopped
+	// for a pause, and with false when it continues.
+	Paused func(paused bool)
+
+	// Completed is the number of design commands completed, including
+	// skipped commands. It is set when Run returns.
+	Completed int
+}
+
+// BoundsError describes a point outside the range or the work area of
+// the engraver.
+type BoundsError struct {
+	Point f32.Vec2
+}
+
+func (e *BoundsError) Error() string {
+	return fmt.Sprintf("engrave: (%.2f,%.2f) out of range", e.Point[0], e.Point[1])
+}
+
+// Phase is the part of an engraving waiting for the engraver.
+type Phase int
+
+const (
+	// PhaseInit is the initialization of the engraver.
+	PhaseInit Phase = iota
+	// PhaseOrigin is the move to the machine origin.
+	PhaseOrigin
+	// PhaseReply is the acknowledgement of a setting or query.
+	PhaseReply
+	// PhaseBatch is the request for the next batch of program commands.
+	PhaseBatch
+	// PhaseStep is the execution of a program command.
+	PhaseStep
+)
+
+func (p Phase) String() string {
+	switch p {
+	case PhaseInit:
+		return "initialization"
+	case PhaseOrigin:
+		return "homing"
+	case PhaseReply:
+		return "reply"
+	case PhaseBatch:
+		return "batch request"
+	case PhaseStep:
+		return "program step"
+	}
+	return fmt.Sprintf("Phase(%d)", int(p))
+}
+
+// TimeoutError reports an engraver that stopped replying.
+type TimeoutError struct {
+	Phase   Phase
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("engrave: no %s reply from engraver in %v", e.Phase, e.Timeout)
+}
+
+// ReplyError reports an unexpected reply from the engraver.
+type ReplyError struct {
+	Phase Phase
+	Want  []byte
+	Got   []byte
+}
+
+func (e *ReplyError) Error() string {
+	return fmt.Sprintf("engrave: unexpected %s reply\nexp: %#x\ngot: %#x", e.Phase, e.Want, e.Got)
+}
@@ -0,0 +1,124 @@
+package engrave
+
+import (
+	"fmt"
+	"image"
+
+	"golang.org/x/image/math/f32"
+	"seedhammer.com/affine"
+)
+
+// Rect is a rectangle in millimeters. A Rect with Min greater than Max
+// in either dimension is empty.
+type Rect struct {
+	Min, Max f32.Vec2
+}
+
+// RectOf converts r to a Rect.
+func RectOf(r image.Rectangle) Rect {
+	return Rect{
+		Min: f32.Vec2{float32(r.Min.X), float32(r.Min.Y)},
+		Max: f32.Vec2{float32(r.Max.X), float32(r.Max.Y)},
+	}
+}
+
+// Empty reports whether r contains no points.
+func (r Rect) Empty() bool {
+	return r.Min[0] > r.Max[0] || r.Min[1] > r.Max[1]
+}
+
+// In reports whether every point of r is inside s.
+func (r Rect) In(s Rect) bool {
+	return r.Empty() || r.Min[0] >= s.Min[0] && r.Min[1] >= s.Min[1] &&
+		r.Max[0] <= s.Max[0] && r.Max[1] <= s.Max[1]
+}
+
+// Inset shrinks r by d on every side. A negative d grows r.
+func (r Rect) Inset(d float32) Rect {
+	return Rect{
+		Min: f32.Vec2{r.Min[0] + d, r.Min[1] + d},
+		Max: f32.Vec2{r.Max[0] - d, r.Max[1] - d},
+	}
+}
+
+// Add translates r by p.
+func (r Rect) Add(p f32.Vec2) Rect {
+	return Rect{Min: affine.Add(r.Min, p), Max: affine.Add(r.Max, p)}
+}
+
+// Excess returns the largest distance from a point of r to s, or zero
+// if r is in s.
+func (r Rect) Excess(s Rect) float32 {
+	if r.In(s) {
+		return 0
+	}
+	d := float32(0)
+	for i := 0; i < 2; i++ {
+		d = max32(d, s.Min[i]-r.Min[i])
+		d = max32(d, r.Max[i]-s.Max[i])
+	}
+	return d
+}
+
+func (r Rect) String() string {
+	return fmt.Sprintf("(%.2f,%.2f)-(%.2f,%.2f)", r.Min[0], r.Min[1], r.Max[0], r.Max[1])
+}
+
+func (r *Rect) union(p f32.Vec2) {
+	if r.Empty() {
+		*r = Rect{Min: p, Max: p}
+		return
+	}
+	for i := 0; i < 2; i++ {
+		r.Min[i] = min32(r.Min[i], p[i])
+		r.Max[i] = max32(r.Max[i], p[i])
+	}
+}
+
+func min32(a, b float32) float32 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max32(a, b float32) float32 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// Extents returns the exact bounds of c. The lines bounds cover every
+// engraved line, including the starting points of lines; the all bounds
+// also cover moves. Unlike Measure, the bounds are not rounded.
+func Extents(c Command) (lines, all Rect) {
+	e := &extentsProgram{
+		lines: Rect{Min: f32.Vec2{1, 1}},
+		all:   Rect{Min: f32.Vec2{1, 1}},
+	}
+	c.Engrave(e)
+	return e.lines, e.all
+}
+
+type extentsProgram struct {
+	lines, all Rect
+	pen        f32.Vec2
+	penLine    bool
+}
+
+func (e *extentsProgram) Move(p f32.Vec2) {
+	e.all.union(p)
+	e.pen = p
+	e.penLine = false
+}
+
+func (e *extentsProgram) Line(p f32.Vec2) {
+	e.all.union(p)
+	if !e.penLine {
+		e.lines.union(e.pen)
+		e.penLine = true
+	}
+	e.lines.union(p)
+	e.pen = p
+}
@@ -0,0 +1,34 @@
+package engrave
+
+import (
+	"testing"
+
+	"golang.org/x/image/math/f32"
+)
+
+func TestExtents(t *testing.T) {
+	design := CommandFunc(func(p Program) {
+		p.Move(f32.Vec2{1, 20})
+		p.Move(f32.Vec2{5, 6})
+		p.Line(f32.Vec2{8, 3})
+		p.Line(f32.Vec2{7.5, 9})
+		p.Move(f32.Vec2{30, 2})
+	})
+	lines, all := Extents(design)
+	if want := (Rect{Min: f32.Vec2{5, 3}, Max: f32.Vec2{8, 9}}); lines != want {
+		t.Errorf("line extents are %v, want %v", lines, want)
+	}
+	if want := (Rect{Min: f32.Vec2{1, 2}, Max: f32.Vec2{30, 20}}); all != want {
+		t.Errorf("extents are %v, want %v", all, want)
+	}
+	plate := Rect{Max: f32.Vec2{10, 10}}
+	if !lines.In(plate) {
+		t.Errorf("%v not in %v", lines, plate)
+	}
+	if got, want := lines.Excess(plate.Inset(3)), float32(2); got != want {
+		t.Errorf("%v exceeds %v by %g, want %g", lines, plate.Inset(3), got, want)
+	}
+	if empty, _ := Extents(Commands{}); !empty.Empty() || !empty.In(plate.Inset(20)) {
+		t.Errorf("empty design has extents %v", empty)
+	}
+}
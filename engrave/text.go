@@ -0,0 +1,276 @@
+package engrave
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/image/math/f32"
+	"seedhammer.com/font"
+)
+
+// Alignment is the horizontal alignment of lines in a text block.
+type Alignment int
+
+const (
+	AlignStart Alignment = iota
+	AlignCenter
+	AlignEnd
+)
+
+// Text describes the style and constraints for laying out a
+// block of text. Units are in millimeters.
+type Text struct {
+	Face *font.Face
+	// Size is the size of an em.
+	Size float32
+	// LineHeight is the distance between lines relative to
+	// the font height. Zero means 1.
+	LineHeight float32
+	Alignment  Alignment
+	// Width is the maximum width of a line. Lines wider than
+	// Width are wrapped at spaces, or at any rune if a single word
+	// is too wide. Zero means no limit.
+	Width float32
+	// MaxLines is the maximum number of lines. Zero means no limit.
+	MaxLines int
+	// Monospace lays out runes in a grid of cells as wide as the
+	// widest rune, 'W'.
+	Monospace bool
+}
+
+// TextBlock is a laid out block of text.
+type TextBlock struct {
+	Lines []TextLine
+	// Size is the logical size of the block, measured by glyph advances
+	// and line heights.
+	Size f32.Vec2
+
+	style Text
+	// overflow records whether the text exceeded the style constraints.
+	overflow bool
+}
+
+// TextLine is a line of text positioned in its block.
+type TextLine struct {
+	Text string
+	// Offset is the position of the top-left corner of the line.
+	Offset f32.Vec2
+	Width  float32
+}
+
+// OverflowError is returned when a text block doesn't fit
+// its constraints.
+type OverflowError struct {
+	Text string
+	// Size is the size of the text block.
+	Size f32.Vec2
+	// Limit is the constraining width and height. Zero
+	// components are unconstrained.
+	Limit f32.Vec2
+}
+
+func (e *OverflowError) Error() string {
+	return fmt.Sprintf("engrave: text %q (%.1fx%.1f mm) overflows %.1fx%.1f mm", e.Text, e.Size[0], e.Size[1], e.Limit[0], e.Limit[1])
+}
+
+func (t Text) lineHeight() float32 {
+	lh := t.LineHeight
+	if lh == 0 {
+		lh = 1
+	}
+	return t.Face.Metrics.Height * t.Size * lh
+}
+
+// Cell returns the size of a grid cell for monospaced layout.
+func (t Text) Cell() f32.Vec2 {
+	adv, _, ok := t.Face.Decode('W')
+	if !ok {
+		panic("W not in font")
+	}
+	return f32.Vec2{adv * t.Size, t.lineHeight()}
+}
+
+// Columns returns the number of monospaced grid cells that fit in width.
+func (t Text) Columns(width float32) int {
+	return int(width / t.Cell()[0])
+}
+
+// Rows returns the number of lines that fit in height.
+func (t Text) Rows(height float32) int {
+	return int(height / t.lineHeight())
+}
+
+// Advance returns the width of a single line of text, measured by
+// glyph advances.
+func (t Text) Advance(s string) float32 {
+	if t.Monospace {
+		return float32(utf8.RuneCountInString(s)) * t.Cell()[0]
+	}
+	w := float32(0)
+	for _, r := range s {
+		adv, _, _ := t.Face.Decode(r)
+		w += adv * t.Size
+	}
+	return w
+}
+
+// Layout breaks msg into lines according to the style and positions
+// them relative to the top-left corner of the block.
+func (t Text) Layout(msg string) *TextBlock {
+	b := &TextBlock{style: t}
+	var lines []string
+	for _, para := range strings.Split(msg, "\n") {
+		lines = append(lines, t.wrap(para)...)
+	}
+	if t.MaxLines > 0 && len(lines) > t.MaxLines {
+		lines = lines[:t.MaxLines]
+		b.overflow = true
+	}
+	width := t.Width
+	for _, l := range lines {
+		w := t.Advance(l)
+		if t.Width > 0 && w > t.Width {
+			b.overflow = true
+		}
+		if t.Width == 0 && w > width {
+			width = w
+		}
+		b.Lines = append(b.Lines, TextLine{Text: l, Width: w})
+	}
+	lh := t.lineHeight()
+	for i := range b.Lines {
+		l := &b.Lines[i]
+		var x float32
+		switch t.Alignment {
+		case AlignCenter:
+			x = (width - l.Width) / 2
+		case AlignEnd:
+			x = width - l.Width
+		}
+		l.Offset = f32.Vec2{x, float32(i) * lh}
+	}
+	if n := len(b.Lines); n > 0 {
+		b.Size = f32.Vec2{width, t.Face.Metrics.Height*t.Size + float32(n-1)*lh}
+	}
+	return b
+}
+
+// wrap breaks a paragraph into lines no wider than t.Width.
+func (t Text) wrap(para string) []string {
+	if t.Width <= 0 || t.Advance(para) <= t.Width {
+		return []string{para}
+	}
+	var lines []string
+	line := ""
+	for _, word := range strings.Split(para, " ") {
+		cand := word
+		if line != "" {
+			cand = line + " " + word
+		}
+		if t.Advance(cand) <= t.Width {
+			line = cand
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+		// Break words too wide for a line of their own.
+		for t.Advance(word) > t.Width {
+			n := t.fit(word)
+			lines = append(lines, word[:n])
+			word = word[n:]
+		}
+		line = word
+	}
+	return append(lines, line)
+}
+
+// fit returns the length of the longest prefix of s that fits
+// t.Width, but at least one rune.
+func (t Text) fit(s string) int {
+	w := float32(0)
+	for i, r := range s {
+		var adv float32
+		if t.Monospace {
+			adv = t.Cell()[0]
+		} else {
+			a, _, _ := t.Face.Decode(r)
+			adv = a * t.Size
+		}
+		w += adv
+		if w > t.Width && i > 0 {
+			return i
+		}
+	}
+	return len(s)
+}
+
+// Overflow reports whether the text was truncated or a line exceeds
+// the style width.
+func (b *TextBlock) Overflow() bool {
+	return b.overflow
+}
+
+// Fit returns an *OverflowError if the block overflows its style
+// constraints or exceeds the given height. A zero height is unconstrained.
+func (b *TextBlock) Fit(height float32) error {
+	if !b.overflow && (height == 0 || b.Size[1] <= height) {
+		return nil
+	}
+	var txt []string
+	for _, l := range b.Lines {
+		txt = append(txt, l.Text)
+	}
+	return &OverflowError{
+		Text:  strings.Join(txt, "\n"),
+		Size:  b.Size,
+		Limit: f32.Vec2{b.style.Width, height},
+	}
+}
+
+func (b *TextBlock) Engrave(p Program) {
+	t := b.style
+	for _, l := range b.Lines {
+		s := &StringCmd{
+			LineHeight: 1,
+			face:       t.Face,
+			mmPrEm:     t.Size,
+			msg:        l.Text,
+		}
+		if t.Monospace {
+			s.advance = t.Cell()[0]
+		}
+		Offset(l.Offset[0], l.Offset[1], s).Engrave(p)
+	}
+}
+
+type measureProgram struct {
+	Bounds image.Rectangle
+}
+
+func (m *measureProgram) Line(p f32.Vec2) {
+	bounds := image.Rectangle{
+		Min: image.Pt(int(math.Floor(float64(p[0]))), int(math.Floor(float64(p[1])))),
+		Max: image.Pt(int(math.Ceil(float64(p[0]))), int(math.Ceil(float64(p[1])))),
+	}
+	m.Bounds = m.Bounds.Union(bounds)
+}
+
+func (m *measureProgram) Move(p f32.Vec2) {}
+
+// Measure returns the bounds of the lines engraved by c, rounded
+// outwards to whole units. Moves don't contribute to the bounds.
+func Measure(c Command) image.Rectangle {
+	var measure measureProgram
+	c.Engrave(&measure)
+	return measure.Bounds
+}
+
+// Dims returns the size of the bounds of c, as reported by Measure.
+func Dims(c Command) f32.Vec2 {
+	sz := Measure(c).Size()
+	return f32.Vec2{float32(sz.X), float32(sz.Y)}
+}
@@ -0,0 +1,102 @@
+package engrave
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"seedhammer.com/font/sh"
+)
+
+func TestTextWrap(t *testing.T) {
+	txt := Text{
+		Face:      &sh.Fontsh,
+		Size:      4,
+		Monospace: true,
+	}
+	txt.Width = txt.Cell()[0] * 10
+	tests := []struct {
+		msg  string
+		want []string
+	}{
+		{"SHORT", []string{"SHORT"}},
+		{"TWO WORDS HERE", []string{"TWO WORDS", "HERE"}},
+		{"ABCDEFGHIJKLMNOPQRSTUVWXYZ", []string{"ABCDEFGHIJ", "KLMNOPQRST", "UVWXYZ"}},
+		{"A\nB", []string{"A", "B"}},
+	}
+	for _, test := range tests {
+		b := txt.Layout(test.msg)
+		var got []string
+		for _, l := range b.Lines {
+			got = append(got, l.Text)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q wrapped to %q, want %q", test.msg, got, test.want)
+		}
+		if b.Overflow() {
+			t.Errorf("%q overflowed", test.msg)
+		}
+	}
+}
+
+func TestTextAlignment(t *testing.T) {
+	txt := Text{
+		Face:      &sh.Fontsh,
+		Size:      4,
+		Monospace: true,
+		Width:     40,
+	}
+	cw := txt.Cell()[0]
+	tests := []struct {
+		align Alignment
+		want  float32
+	}{
+		{AlignStart, 0},
+		{AlignCenter, (40 - 2*cw) / 2},
+		{AlignEnd, 40 - 2*cw},
+	}
+	for _, test := range tests {
+		txt.Alignment = test.align
+		b := txt.Layout("AB")
+		if got := b.Lines[0].Offset[0]; got != test.want {
+			t.Errorf("alignment %d: got offset %v, want %v", test.align, got, test.want)
+		}
+	}
+}
+
+func TestTextOverflow(t *testing.T) {
+	txt := Text{
+		Face:     &sh.Fontsh,
+		Size:     4,
+		MaxLines: 1,
+	}
+	txt.Width = txt.Advance("WORD")
+	b := txt.Layout("WORD WORD")
+	if !b.Overflow() {
+		t.Fatal("truncated text didn't overflow")
+	}
+	var oerr *OverflowError
+	if err := b.Fit(0); !errors.As(err, &oerr) {
+		t.Fatalf("got error %v, want *OverflowError", err)
+	}
+	b = (Text{Face: &sh.Fontsh, Size: 4}).Layout("A\nB\nC")
+	if err := b.Fit(b.Size[1]); err != nil {
+		t.Errorf("text doesn't fit its own height: %v", err)
+	}
+	if err := b.Fit(b.Size[1] / 2); err == nil {
+		t.Error("text fit half its height")
+	}
+}
+
+func TestMeasure(t *testing.T) {
+	txt := Text{
+		Face:      &sh.Fontsh,
+		Size:      5,
+		Monospace: true,
+	}
+	b := txt.Layout("HELLO")
+	got := Dims(b)
+	if got[0] <= 0 || got[0] > b.Size[0]+1 || got[1] <= 0 || got[1] > b.Size[1]+1 {
+		t.Errorf("measured %v outside logical size %v", got, b.Size)
+	}
+}
@@ -34,6 +34,8 @@ var (
 	IconRight     = mustLoad("icon-right.png")
 	IconInfo      = mustLoad("icon-info.png")
 	IconHammer    = mustLoad("icon-hammer.png")
+	IconPause     = mustLoad("icon-pause.png")
+	IconPlus      = mustLoad("icon-plus.png")
 
 	SH01 = mustLoad("sh01.png")
 	SH02 = mustLoad("sh02.png")
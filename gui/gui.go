@@ -3,25 +3,37 @@ package gui
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"hash"
 	"image"
 	"image/color"
 	"image/png"
 	"io"
+	"io/fs"
 	"log"
 	"math"
+	"math/rand"
 	"reflect"
+	"sort"
 	"strings"
+	"sync"
 	"text/template"
 	"time"
 	"unicode/utf8"
 
 	"github.com/btcsuite/btcd/btcutil/hdkeychain"
 	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/skip2/go-qrcode"
 	"golang.org/x/image/font"
 	"golang.org/x/image/font/opentype"
 	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/f32"
 	"golang.org/x/image/math/fixed"
 	"seedhammer.com/backup"
 	"seedhammer.com/bc/ur"
@@ -29,6 +41,7 @@ import (
 	"seedhammer.com/bip32"
 	"seedhammer.com/bip39"
 	"seedhammer.com/camera"
+	"seedhammer.com/engrave"
 	"seedhammer.com/font/sh"
 	"seedhammer.com/gui/assets"
 	"seedhammer.com/gui/layout"
@@ -51,13 +64,18 @@ type Context struct {
 	Repeats        [nbuttons]time.Time
 	Platform       Platform
 	Styles         Styles
-	Calibrated     bool
+	Calibration    Calibration
 	EnableSeedScan bool
 	NoSDCard       bool
 	Version        string
 
 	Wakeup chan struct{}
 	events []Event
+
+	interrupted interruptions
+	// strokeWidth is the stroke width of the last connected
+	// engraver, or zero.
+	strokeWidth float32
 }
 
 type Event struct {
@@ -166,11 +184,14 @@ func (c *Context) Next() (Event, bool) {
 const longestWord = "REMEMBER"
 const passphrase = ""
 
-type walletType int
+type mainPage int
 
 const (
-	singleKey walletType = iota
+	singleKey mainPage = iota
 	multiKey
+	buildMultisig
+	calibrateEngraver
+	diagnoseEngraver
 )
 
 type CosignersScreen struct {
@@ -313,6 +334,29 @@ type DescriptorScreen struct {
 	seed      *SeedScreen
 	warning   *ErrorScreen
 	engrave   *EngraveScreen
+	verify    *VerifyScreen
+	share     *ChoiceScreen
+	// session is the summary of a session engraving every share,
+	// if active.
+	session *SessionScreen
+
+	// validated is set when the descriptor has passed validation.
+	validated bool
+	// keyIdx is the index of the entered share. An entered share is
+	// kept after an incomplete engraving, for verifying it after
+	// engraving it again.
+	keyIdx int
+	// plates are the validated plates of the shares engraved so far,
+	// by share index, for engraving them again without entering their
+	// seeds.
+	plates map[int]sharePlates
+}
+
+// sharePlates are the validated plates of a share, laid out for a
+// stroke width.
+type sharePlates struct {
+	plates      []backup.Plate
+	strokeWidth float32
 }
 
 // singlesigDescriptor builds a single-sig descriptor from a seed and a passphrase. It uses
@@ -323,31 +367,37 @@ func singlesigDescriptor(m bip39.Mnemonic, pass string) (urtypes.OutputDescripto
 		return urtypes.OutputDescriptor{}, false
 	}
 
-	path := urtypes.Path{0}
-	mfp, xpub, err := bip32.Derive(mk, path)
-	if err != nil {
-		return urtypes.OutputDescriptor{}, false
-	}
-	pub, err := xpub.ECPubKey()
-	if err != nil {
+	k, ok := deriveKey(mk, urtypes.Path{0})
+	if !ok {
 		return urtypes.OutputDescriptor{}, false
 	}
 	desc := urtypes.OutputDescriptor{
 		Threshold: 1,
 		Type:      urtypes.UnknownScript,
-		Keys: []urtypes.KeyDescriptor{
-			{
-				DerivationPath:    path,
-				MasterFingerprint: mfp,
-				KeyData:           pub.SerializeCompressed(),
-				ChainCode:         xpub.ChainCode(),
-				ParentFingerprint: xpub.ParentFingerprint(),
-			},
-		},
+		Keys:      []urtypes.KeyDescriptor{k},
 	}
 	return desc, true
 }
 
+// deriveKey derives the key descriptor for a path from a master key.
+func deriveKey(mk *hdkeychain.ExtendedKey, path urtypes.Path) (urtypes.KeyDescriptor, bool) {
+	mfp, xpub, err := bip32.Derive(mk, path)
+	if err != nil {
+		return urtypes.KeyDescriptor{}, false
+	}
+	pub, err := xpub.ECPubKey()
+	if err != nil {
+		return urtypes.KeyDescriptor{}, false
+	}
+	return urtypes.KeyDescriptor{
+		DerivationPath:    path,
+		MasterFingerprint: mfp,
+		KeyData:           pub.SerializeCompressed(),
+		ChainCode:         xpub.ChainCode(),
+		ParentFingerprint: xpub.ParentFingerprint(),
+	}, true
+}
+
 func descriptorKeyIdx(desc urtypes.OutputDescriptor, m bip39.Mnemonic, pass string) (int, bool) {
 	seed := bip39.MnemonicSeed(m, pass)
 	mk, err := hdkeychain.NewMaster(seed, &chaincfg.MainNetParams)
@@ -403,16 +453,51 @@ func (s *DescriptorScreen) Layout(ctx *Context, ops op.Ctx, dims image.Point) bo
 			}
 			s.seed = nil
 			if m == nil {
-				break
+				continue
 			}
 			s.mnemonic = m
 			eng, err := NewEngraveScreen(ctx, s.Descriptor, s.mnemonic, passphrase)
 			if err != nil {
+				s.wipe()
 				s.warning = NewErrorScreen(err)
 				continue
 			}
+			if s.session != nil && s.session.done[eng.keyIdx] {
+				s.wipe()
+				s.warning = &ErrorScreen{
+					Title: "Already Engraved",
+					Body:  fmt.Sprintf("Share %d is already engraved in this session.", eng.keyIdx+1),
+				}
+				continue
+			}
+			s.keyIdx = eng.keyIdx
 			s.engrave = eng
 			continue
+		case s.share != nil:
+			choice, done := s.share.Layout(ctx, ops.Begin(), th, dims, true)
+			dialog := ops.End()
+			if !done {
+				dialog.Add(ops)
+				return false
+			}
+			n := len(s.share.Choices)
+			s.share = nil
+			switch {
+			case choice == -1:
+			case choice == 0:
+				s.wipe()
+				s.seed = NewEmptySeedScreen(ctx, "Input Share")
+			case choice >= n-len(s.plates):
+				s.engraveAgain(ctx, s.engraved()[choice-(n-len(s.plates))])
+			default:
+				s.wipe()
+				s.session = &SessionScreen{
+					Descriptor: s.Descriptor,
+					done:       make([]bool, len(s.Descriptor.Keys)),
+					verified:   make([]verifyResult, len(s.Descriptor.Keys)),
+				}
+			}
+			continue
 		case s.engrave != nil:
 			done := s.engrave.Layout(ctx, ops.Begin(), dims)
 			dialog := ops.End()
@@ -420,10 +505,60 @@ func (s *DescriptorScreen) Layout(ctx *Context, ops op.Ctx, dims image.Point) bo
 				dialog.Add(ops)
 				return false
 			}
-
-			s.seed = NewSeedScreen(ctx, s.mnemonic)
+			eng := s.engrave
 			s.engrave = nil
+			if s.plates == nil {
+				s.plates = make(map[int]sharePlates)
+			}
+			s.plates[eng.keyIdx] = sharePlates{plates: eng.plates, strokeWidth: eng.strokeWidth}
+			switch {
+			case eng.completed:
+				if s.session != nil {
+					s.session.done[s.keyIdx] = true
+				}
+				if s.mnemonic != nil {
+					s.verify = NewVerifyScreen(s.mnemonic)
+				}
+			case s.session != nil:
+				// Forget the share as soon as it is no longer needed.
+				s.wipe()
+			}
+			continue
+		case s.verify != nil:
+			res, done := s.verify.Layout(ctx, ops.Begin(), th, dims)
+			dialog := ops.End()
+			if !done {
+				dialog.Add(ops)
+				return false
+			}
+			s.verify = nil
+			if s.session != nil {
+				s.session.verified[s.keyIdx] = res
+			}
+			s.wipe()
 			continue
+		case s.session != nil:
+			if s.warning != nil {
+				dismissed := s.warning.Layout(ctx, ops.Begin(), th, dims)
+				warning := ops.End()
+				if dismissed {
+					s.warning = nil
+					continue
+				}
+				defer warning.Add(ops)
+			}
+			next, done := s.session.Layout(ctx, ops.Begin(), th, dims, s.warning == nil)
+			dialog := ops.End()
+			switch {
+			case done:
+				s.session = nil
+				continue
+			case next:
+				s.seed = NewEmptySeedScreen(ctx, "Input Share")
+				continue
+			}
+			dialog.Add(ops)
+			return false
 		case s.warning != nil:
 			dismissed := s.warning.Layout(ctx, ops.Begin(), th, dims)
 			warning := ops.End()
@@ -440,6 +575,8 @@ func (s *DescriptorScreen) Layout(ctx *Context, ops op.Ctx, dims image.Point) bo
 		switch e.Button {
 		case input.Button1:
 			if e.Click {
+				s.wipe()
+				s.plates = nil
 				return true
 			}
 		case input.Button2:
@@ -451,11 +588,30 @@ func (s *DescriptorScreen) Layout(ctx *Context, ops op.Ctx, dims image.Point) bo
 			if !e.Click {
 				break
 			}
-			if err := validateDescriptor(s.Descriptor); err != nil {
-				s.warning = NewErrorScreen(err)
+			if !s.validated {
+				if err := validateDescriptor(s.Descriptor); err != nil {
+					s.warning = NewErrorScreen(err)
+					continue
+				}
+				s.validated = true
+			}
+			multi := len(s.Descriptor.Keys) > 1
+			if !multi && len(s.plates) == 0 {
+				s.seed = NewEmptySeedScreen(ctx, "Input Share")
 				continue
 			}
-			s.seed = NewEmptySeedScreen(ctx, "Input Share")
+			s.share = &ChoiceScreen{
+				Title:   "Select Share",
+				Lead:    "Choose share to engrave",
+				Choices: []string{"INPUT SHARE"},
+			}
+			if multi {
+				s.share.Choices = append(s.share.Choices, "ALL SHARES")
+			}
+			for _, idx := range s.engraved() {
+				k := s.Descriptor.Keys[idx]
+				s.share.Choices = append(s.share.Choices, fmt.Sprintf("%d: %.8x", idx+1, k.MasterFingerprint))
+			}
 		}
 	}
 
@@ -504,663 +660,752 @@ func (s *DescriptorScreen) Layout(ctx *Context, ops op.Ctx, dims image.Point) bo
 	return false
 }
 
-func derivationPath(path urtypes.Path) string {
-	var b strings.Builder
-	b.WriteString("m")
-	for _, p := range path {
-		b.WriteString("/")
-		if p >= hdkeychain.HardenedKeyStart {
-			fmt.Fprintf(&b, "%d'", p-hdkeychain.HardenedKeyStart)
-		} else {
-			fmt.Fprintf(&b, "%d", p)
-		}
+// engraved returns the indices of the shares with validated plates, in
+// order.
+func (s *DescriptorScreen) engraved() []int {
+	var idxs []int
+	for idx := range s.plates {
+		idxs = append(idxs, idx)
 	}
-	return b.String()
+	sort.Ints(idxs)
+	return idxs
 }
 
-type ScanScreen struct {
-	Title   string
-	Lead    string
-	decoder ur.Decoder
-	feed    *image.Gray
-	camera  struct {
-		out  chan<- camera.Frame
-		in   <-chan camera.Frame
-		quit chan struct{}
-		err  error
+// engraveAgain engraves the validated plates of a share again, without
+// entering its seed.
+func (s *DescriptorScreen) engraveAgain(ctx *Context, keyIdx int) {
+	if keyIdx != s.keyIdx {
+		// The entered share is no longer needed.
+		s.wipe()
 	}
+	s.keyIdx = keyIdx
+	s.engrave = newPlatesEngraveScreen(ctx, s.Descriptor, keyIdx, s.plates[keyIdx], nil)
+	s.engrave.chooseSides("Engrave Share", nil)
 }
 
-func (s *ScanScreen) close() {
-	if s.camera.quit != nil {
-		s.camera.quit <- struct{}{}
-		<-s.camera.quit
+// wipe forgets the entered share.
+func (s *DescriptorScreen) wipe() {
+	for i := range s.mnemonic {
+		s.mnemonic[i] = -1
 	}
+	s.mnemonic = nil
 }
 
-func (s *ScanScreen) Layout(ctx *Context, ops op.Ctx, dims image.Point) (any, bool) {
-	const cameraFrameScale = 4
-	if s.camera.quit == nil && s.camera.err == nil {
-		frames := make(chan camera.Frame, 1)
-		out := make(chan camera.Frame)
-		quit := make(chan struct{})
-		go func() {
-			defer close(quit)
-			defer close(frames)
-			closer, err := ctx.Platform.Camera(dims.Mul(cameraFrameScale), frames, out)
-			if err != nil {
-				log.Println(err)
-				select {
-				case frames <- camera.Frame{Err: err}:
-					<-quit
-				case <-quit:
-				}
-				return
-			}
-			defer closer()
-			<-quit
-		}()
-		s.camera.quit = quit
-		s.camera.in = WakeupChan(ctx, frames)
-		s.camera.out = out
+// SessionScreen summarizes a session for engraving every share of a
+// descriptor, one share at a time.
+type SessionScreen struct {
+	Descriptor urtypes.OutputDescriptor
+
+	done []bool
+	// verified records the read back of the engraved shares.
+	verified []verifyResult
+	scroll   int
+}
+
+// remaining returns the number of shares not yet engraved.
+func (s *SessionScreen) remaining() int {
+	n := 0
+	for _, d := range s.done {
+		if !d {
+			n++
+		}
 	}
-	for {
+	return n
+}
+
+// Layout reports whether the user asked to engrave the next share, or to
+// end the session.
+func (s *SessionScreen) Layout(ctx *Context, ops op.Ctx, th *Colors, dims image.Point, active bool) (bool, bool) {
+	remaining := s.remaining()
+	for active {
 		e, ok := ctx.Next()
 		if !ok {
 			break
 		}
 		switch e.Button {
 		case input.Button1:
+			if e.Click {
+				return false, true
+			}
+		case input.Button3:
 			if !e.Click {
-				continue
+				break
+			}
+			if remaining == 0 {
+				return false, true
+			}
+			return true, false
+		case input.Up:
+			if e.Pressed && s.scroll > 0 {
+				s.scroll--
+			}
+		case input.Down:
+			if e.Pressed && s.scroll < len(s.done)-1 {
+				s.scroll++
 			}
-			s.close()
-			return nil, true
 		}
 	}
 
-	if s.feed == nil || dims != s.feed.Bounds().Size() {
-		s.feed = image.NewGray(image.Rectangle{Max: dims})
-	}
-	select {
-	case frame := <-s.camera.in:
-		if frame.Err != nil {
-			s.camera.quit <- struct{}{}
-			<-s.camera.quit
-			s.camera.err = frame.Err
-			s.camera.quit = nil
-			s.camera.in = nil
-			s.camera.out = nil
-			break
-		}
-		ycbcr := frame.Image.(*image.YCbCr)
-		gray := &image.Gray{Pix: ycbcr.Y, Stride: ycbcr.YStride, Rect: ycbcr.Bounds()}
+	op.ColorOp(ops, th.Background)
+	layoutTitle(ctx, ops, dims.X, th.Text, "Engrave Shares")
 
-		scaleRot(s.feed, gray)
-		// Re-create image (but not backing store) to ensure redraw.
-		copy := *s.feed
-		s.feed = &copy
-		results, err := zbar.Scan(gray)
-		s.camera.out <- frame
-		if err != nil {
-			break
+	r := layout.Rectangle{Max: dims}
+	btnw := assets.NavBtnPrimary.Bounds().Dx()
+	content, lead := r.Shrink(leadingSize, 0, 0, 0).CutBottom(leadingSize)
+	body := content.Shrink(0, btnw, 0, btnw)
+	inner := body.Shrink(scrollFadeDist, 0, scrollFadeDist, 0)
+
+	bodyst := ctx.Styles.body
+	rows := ops.Begin()
+	y := inner.Min.Y
+	for i, k := range s.Descriptor.Keys {
+		status := "remaining"
+		switch {
+		case s.done[i] && s.verified[i] == verifyPassed:
+			status = "verified"
+		case s.done[i] && s.verified[i] == verifyFailed:
+			status = "failed"
+		case s.done[i]:
+			status = "engraved"
 		}
-		for _, res := range results {
-			v, ok := s.parseQR(res)
-			if ok {
-				s.close()
-				return v, true
-			}
+		sz := widget.Label(rows.Begin(), bodyst, th.Text, fmt.Sprintf("%d: %.8x", i+1, k.MasterFingerprint))
+		op.Position(rows, rows.End(), image.Pt(inner.Min.X, y))
+		stsz := widget.Label(rows.Begin(), bodyst, th.Text, status)
+		op.Position(rows, rows.End(), image.Pt(inner.Max.X-stsz.X, y))
+		if i < s.scroll {
+			y -= sz.Y
 		}
-	default:
+		y += sz.Y
 	}
-	th := &cameraTheme
-	r := layout.Rectangle{Max: dims}
-
-	op.ImageOp(ops, s.feed)
+	clipScroll(ops, ops.End(), image.Rectangle(body))
 
-	op.ImageOp(ops.Begin(), assets.CameraCorners)
-	op.Position(ops, ops.End(), r.Center(assets.CameraCorners.Bounds().Size()))
+	var leadTxt string
+	switch remaining {
+	case 0:
+		leadTxt = "All shares engraved"
+	case 1:
+		leadTxt = "1 share remaining"
+	default:
+		leadTxt = fmt.Sprintf("%d shares remaining", remaining)
+	}
+	sz := widget.LabelW(ops.Begin(), ctx.Styles.lead, dims.X-2*8, th.Text, leadTxt)
+	op.Position(ops, ops.End(), lead.Center(sz))
 
-	underlay := assets.ButtonFocused
-	background := func(ops op.Ctx, w op.CallOp, dst image.Rectangle, pos image.Point) {
-		op.MaskOp(ops.Begin(), underlay.For(dst))
-		op.ColorOp(ops, color.NRGBA{A: theme.overlayMask})
-		op.Position(ops, ops.End(), image.Point{})
-		op.Position(ops, w, pos)
+	if active {
+		icn := assets.IconRight
+		if remaining == 0 {
+			icn = assets.IconCheckmark
+		}
+		layoutNavigation(ctx, ops, th, dims,
+			NavButton{Button: input.Button1, Style: StyleSecondary, Icon: assets.IconBack},
+			NavButton{Button: input.Button3, Style: StylePrimary, Icon: icn},
+		)
 	}
+	return false, false
+}
 
-	title := layoutTitle(ctx, ops.Begin(), dims.X, th.Text, s.Title)
-	title.Min.Y += 4
-	title.Max.Y -= 4
-	background(ops, ops.End(), title, image.Point{})
+// verifyResult is the outcome of reading back the words of an
+// engraved plate.
+type verifyResult int
 
-	// Camera error, if any.
-	if err := s.camera.err; err != nil {
-		sz := widget.LabelW(ops.Begin(), ctx.Styles.body, dims.X-2*16, th.Text, err.Error())
-		op.Position(ops, ops.End(), r.Center(sz))
-	}
+const (
+	verifySkipped verifyResult = iota
+	verifyPassed
+	verifyFailed
+)
 
-	width := dims.X - 2*8
-	// Lead text.
-	sz := widget.LabelW(ops.Begin(), ctx.Styles.lead, width, th.Text, s.Lead)
-	top, footer := r.CutBottom(sz.Y + 2*12)
-	pos := footer.Center(sz)
-	background(ops, ops.End(), image.Rectangle{Min: pos, Max: pos.Add(sz)}, pos)
+// verifyWords is the number of words asked for by VerifyScreen.
+const verifyWords = 3
 
-	// Progress
-	progress := int(100 * s.decoder.Progress())
-	if progress > 0 {
-		sz = widget.LabelW(ops.Begin(), ctx.Styles.lead, width, th.Text, fmt.Sprintf("%d%%", progress))
-		_, percent := top.CutBottom(sz.Y)
-		pos := percent.Center(sz)
-		background(ops, ops.End(), image.Rectangle{Min: pos, Max: pos.Add(sz)}, pos)
-	}
+// VerifyScreen asks the user to read back a random selection of words
+// from an engraved plate.
+type VerifyScreen struct {
+	Mnemonic bip39.Mnemonic
 
-	nav := layoutNavigation(ctx, ops.Begin(), th, dims, NavButton{Button: input.Button1, Style: StyleSecondary, Icon: assets.IconBack})
-	nav = image.Rectangle(layout.Rectangle(nav).Shrink(underlay.Padding()).Shrink(-2, -4, -2, -2))
-	background(ops, ops.End(), nav, image.Point{})
-	return nil, false
+	confirm   *ChoiceScreen
+	input     *WordKeyboardScreen
+	warning   *ErrorScreen
+	positions []int
+	answers   bip39.Mnemonic
 }
 
-// scaleRot is a specialized function for fast scaling and rotation of
-// the camera frames.
-func scaleRot(dst, src *image.Gray) {
-	db := dst.Bounds()
-	sb := src.Bounds()
-	if db.Empty() {
-		return
-	}
-	scale := sb.Dx() / db.Dx()
-	for y := 0; y < db.Dy(); y++ {
-		for x := 0; x < db.Dx(); x++ {
-			// Rotate and scale.
-			c := src.GrayAt(sb.Max.Y-1-y*scale, x*scale+sb.Min.X)
-			dst.SetGray(x+db.Min.X, y+db.Min.Y, c)
-		}
+func NewVerifyScreen(m bip39.Mnemonic) *VerifyScreen {
+	s := &VerifyScreen{
+		Mnemonic: m,
+		confirm: &ChoiceScreen{
+			Title:   "Verify Plate",
+			Lead:    "Read back words from the plate",
+			Choices: []string{"VERIFY", "SKIP"},
+		},
+		positions: rand.Perm(len(m))[:verifyWords],
 	}
+	sort.Ints(s.positions)
+	return s
 }
 
-func (s *ScanScreen) parseQR(qr []byte) (any, bool) {
-	uqr := strings.ToUpper(string(qr))
-	if !strings.HasPrefix(uqr, "UR:") {
-		s.decoder = ur.Decoder{}
-		return qr, true
+// check compares the answers with the mnemonic, and returns the
+// position of the first wrong word or -1.
+func (s *VerifyScreen) check() int {
+	wrong := -1
+	for _, p := range s.positions {
+		if s.answers[p] != s.Mnemonic[p] && wrong == -1 {
+			wrong = p
+		}
 	}
-	if err := s.decoder.Add(uqr); err != nil {
-		// Incompatible fragment. Reset decoder and try again.
-		s.decoder = ur.Decoder{}
-		s.decoder.Add(uqr)
+	for i := range s.answers {
+		s.answers[i] = -1
 	}
-	typ, enc, err := s.decoder.Result()
-	if err != nil {
-		s.decoder = ur.Decoder{}
-		return nil, false
-	}
-	if enc == nil {
-		return nil, false
-	}
-	s.decoder = ur.Decoder{}
-	v, err := urtypes.Parse(typ, enc)
-	if err != nil {
-		return nil, false
-	}
-	return v, true
-}
-
-type ErrorScreen struct {
-	Title string
-	Body  string
+	return wrong
 }
 
-func (s *ErrorScreen) Layout(ctx *Context, ops op.Ctx, th *Colors, dims image.Point) bool {
+func (s *VerifyScreen) Layout(ctx *Context, ops op.Ctx, th *Colors, dims image.Point) (verifyResult, bool) {
 	for {
-		e, ok := ctx.Next()
-		if !ok {
-			break
-		}
-		switch e.Button {
-		case input.Button3:
-			if e.Click {
-				return true
+		switch {
+		case s.confirm != nil:
+			choice, done := s.confirm.Layout(ctx, ops.Begin(), th, dims, true)
+			dialog := ops.End()
+			if !done {
+				dialog.Add(ops)
+				return verifySkipped, false
+			}
+			s.confirm = nil
+			if choice != 0 {
+				return verifySkipped, true
+			}
+			// The keyboard skips the words not asked for.
+			s.answers = make(bip39.Mnemonic, len(s.Mnemonic))
+			for _, p := range s.positions {
+				s.answers[p] = -1
 			}
+			s.input = &WordKeyboardScreen{
+				Mnemonic: s.answers,
+				selected: s.positions[0],
+			}
+			continue
+		case s.input != nil:
+			done := s.input.Layout(ctx, ops.Begin(), th, dims)
+			dialog := ops.End()
+			if !done {
+				dialog.Add(ops)
+				return verifySkipped, false
+			}
+			s.input = nil
+			for _, p := range s.positions {
+				if s.answers[p] == -1 {
+					return verifySkipped, true
+				}
+			}
+			wrong := s.check()
+			if wrong == -1 {
+				return verifyPassed, true
+			}
+			s.warning = &ErrorScreen{
+				Title: "Wrong Word",
+				Body:  fmt.Sprintf("Word %d doesn't match the seed.\nCheck the plate.", wrong+1),
+			}
+			continue
+		case s.warning != nil:
+			dismissed := s.warning.Layout(ctx, ops.Begin(), th, dims)
+			warning := ops.End()
+			if dismissed {
+				return verifyFailed, true
+			}
+			op.ColorOp(ops, th.Background)
+			warning.Add(ops)
 		}
+		return verifySkipped, false
 	}
-	r := layout.Rectangle{Max: dims}
-	sz := layoutWarning(ctx, ops.Begin(), th, dims.X, s.Title, s.Body)
-	op.Position(ops, ops.End(), r.Center(sz))
-	layoutNavigation(ctx, ops, th, dims, NavButton{Button: input.Button3, Style: StylePrimary, Icon: assets.IconCheckmark})
-	return false
 }
 
-type ConfirmWarningScreen struct {
-	Title string
-	Body  string
-	Icon  image.RGBA64Image
-
-	confirm ConfirmDelay
+// multisigScripts are the script types for building multisig
+// descriptors.
+var multisigScripts = []struct {
+	Label string
+	Type  urtypes.Script
+}{
+	{"SEGWIT", urtypes.P2WSH},
+	{"NESTED SEGWIT", urtypes.P2SH_P2WSH},
+	{"LEGACY", urtypes.P2SH},
 }
 
-type ConfirmResult int
+// BuildScreen composes a multisig descriptor from scanned cosigner keys
+// and keys derived from seeds entered on the device.
+type BuildScreen struct {
+	desc urtypes.OutputDescriptor
 
-const (
-	ConfirmNone ConfirmResult = iota
-	ConfirmNo
-	ConfirmYes
-)
+	script    *ChoiceScreen
+	add       *ChoiceScreen
+	remove    *ChoiceScreen
+	scanner   *ScanScreen
+	seed      *SeedScreen
+	threshold *ChoiceScreen
+	export    *ExportScreen
+	warning   *ErrorScreen
+}
 
-type ConfirmDelay struct {
-	timeout time.Time
+func NewBuildScreen() *BuildScreen {
+	s := &BuildScreen{
+		script: &ChoiceScreen{
+			Title: "Build Wallet",
+			Lead:  "Choose script type",
+		},
+	}
+	for _, sc := range multisigScripts {
+		s.script.Choices = append(s.script.Choices, sc.Label)
+	}
+	return s
 }
 
-func (c *ConfirmDelay) Start(ctx *Context, delay time.Duration) {
-	c.timeout = ctx.Platform.Now().Add(delay)
+// path returns the standard derivation path of the cosigner keys.
+func (s *BuildScreen) path() urtypes.Path {
+	desc := urtypes.OutputDescriptor{
+		Type: s.desc.Type,
+		Keys: make([]urtypes.KeyDescriptor, 2),
+	}
+	return desc.DerivationPath()
 }
 
-func (c *ConfirmDelay) Progress(ctx *Context) float32 {
-	if !c.Running() {
-		return 0.
+// addKey adds a cosigner key, unless it is already present or uses a
+// non-standard derivation.
+func (s *BuildScreen) addKey(k urtypes.KeyDescriptor) error {
+	if path := s.path(); !reflect.DeepEqual(k.DerivationPath, path) {
+		return &errNonstandardDerivation{Path: k.DerivationPath}
 	}
-	now := ctx.Platform.Now()
-	d := c.timeout.Sub(now)
-	if d <= 0 {
-		return 1.
+	for _, k2 := range s.desc.Keys {
+		if k2.String() == k.String() {
+			return &errDuplicateKey{Fingerprint: k.MasterFingerprint}
+		}
 	}
-	ctx.WakeupAfter(0)
-	return 1. - float32(d.Seconds()/confirmDelay.Seconds())
+	s.desc.Keys = append(s.desc.Keys, k)
+	return nil
 }
 
-func (c *ConfirmDelay) Running() bool {
-	return !c.timeout.IsZero()
+// compose returns the sorted multisig descriptor for the keys and a
+// threshold.
+func (s *BuildScreen) compose(threshold int) urtypes.OutputDescriptor {
+	desc := s.desc
+	desc.Threshold = threshold
+	desc.Sorted = true
+	desc.Keys = append([]urtypes.KeyDescriptor(nil), s.desc.Keys...)
+	sort.Slice(desc.Keys, func(i, j int) bool {
+		return bytes.Compare(desc.Keys[i].KeyData, desc.Keys[j].KeyData) < 0
+	})
+	return desc
 }
 
-const confirmDelay = 1 * time.Second
-
-func (s *ConfirmWarningScreen) Layout(ctx *Context, ops op.Ctx, th *Colors, dims image.Point) ConfirmResult {
-	var progress float32
+// Layout returns the composed descriptor, or nil if the user
+// cancelled.
+func (s *BuildScreen) Layout(ctx *Context, ops op.Ctx, dims image.Point) (*urtypes.OutputDescriptor, bool) {
+	th := &descriptorTheme
 	for {
-		progress = s.confirm.Progress(ctx)
-		if progress == 1 {
-			return ConfirmYes
+		switch {
+		case s.script != nil:
+			choice, done := s.script.Layout(ctx, ops.Begin(), th, dims, true)
+			dialog := ops.End()
+			if !done {
+				dialog.Add(ops)
+				return nil, false
+			}
+			s.script = nil
+			if choice == -1 {
+				return nil, true
+			}
+			s.desc.Type = multisigScripts[choice].Type
+			continue
+		case s.add != nil:
+			choice, done := s.add.Layout(ctx, ops.Begin(), th, dims, true)
+			dialog := ops.End()
+			if !done {
+				dialog.Add(ops)
+				return nil, false
+			}
+			s.add = nil
+			switch choice {
+			case 0:
+				s.scanner = &ScanScreen{
+					Title: "Scan",
+					Lead:  "Cosigner Key",
+				}
+			case 1:
+				s.seed = NewEmptySeedScreen(ctx, "Input Seed")
+			case 2:
+				s.remove = &ChoiceScreen{
+					Title: "Remove Key",
+					Lead:  "Choose key to remove",
+				}
+				for i, k := range s.desc.Keys {
+					s.remove.Choices = append(s.remove.Choices, fmt.Sprintf("%d: %.8x", i+1, k.MasterFingerprint))
+				}
+			}
+			continue
+		case s.remove != nil:
+			choice, done := s.remove.Layout(ctx, ops.Begin(), th, dims, true)
+			dialog := ops.End()
+			if !done {
+				dialog.Add(ops)
+				return nil, false
+			}
+			s.remove = nil
+			if choice != -1 {
+				s.desc.Keys = append(s.desc.Keys[:choice], s.desc.Keys[choice+1:]...)
+			}
+			continue
+		case s.scanner != nil:
+			res, done := s.scanner.Layout(ctx, ops.Begin(), dims)
+			dialog := ops.End()
+			if !done {
+				dialog.Add(ops)
+				return nil, false
+			}
+			s.scanner = nil
+			if res == nil {
+				continue
+			}
+			k, ok := res.(urtypes.KeyDescriptor)
+			if !ok {
+				s.warning = &ErrorScreen{
+					Title: "Error",
+					Body:  "The scanned data does not represent a cosigner key.",
+				}
+				continue
+			}
+			if err := s.addKey(k); err != nil {
+				s.warning = NewErrorScreen(err)
+			}
+			continue
+		case s.seed != nil:
+			m, done := s.seed.Layout(ctx, ops.Begin(), th, dims)
+			dialog := ops.End()
+			if !done {
+				dialog.Add(ops)
+				return nil, false
+			}
+			s.seed = nil
+			if m == nil {
+				continue
+			}
+			mk, ok := deriveMasterKey(m, passphrase)
+			var k urtypes.KeyDescriptor
+			if ok {
+				k, ok = deriveKey(mk, s.path())
+				mk.Zero()
+			}
+			// Only the public key is needed; forget the seed.
+			for i := range m {
+				m[i] = -1
+			}
+			if !ok {
+				s.warning = &ErrorScreen{
+					Title: "Invalid Seed",
+					Body:  "The seed is invalid.",
+				}
+				continue
+			}
+			if err := s.addKey(k); err != nil {
+				s.warning = NewErrorScreen(err)
+			}
+			continue
+		case s.threshold != nil:
+			choice, done := s.threshold.Layout(ctx, ops.Begin(), th, dims, true)
+			dialog := ops.End()
+			if !done {
+				dialog.Add(ops)
+				return nil, false
+			}
+			s.threshold = nil
+			if choice == -1 {
+				continue
+			}
+			desc := s.compose(choice + 1)
+			if err := validateDescriptor(desc); err != nil {
+				s.warning = NewErrorScreen(err)
+				continue
+			}
+			s.export = NewExportScreen(desc)
+			continue
+		case s.export != nil:
+			accept, done := s.export.Layout(ctx, ops.Begin(), th, dims)
+			dialog := ops.End()
+			if accept {
+				desc := s.export.Descriptor
+				return &desc, true
+			}
+			if !done {
+				dialog.Add(ops)
+				return nil, false
+			}
+			s.export = nil
+			continue
+		case s.warning != nil:
+			dismissed := s.warning.Layout(ctx, ops.Begin(), th, dims)
+			warning := ops.End()
+			if dismissed {
+				s.warning = nil
+				continue
+			}
+			defer warning.Add(ops)
 		}
 		e, ok := ctx.Next()
 		if !ok {
 			break
 		}
+		if !e.Click {
+			continue
+		}
 		switch e.Button {
 		case input.Button1:
-			if e.Click {
-				return ConfirmNo
+			return nil, true
+		case input.Button2:
+			s.add = &ChoiceScreen{
+				Title:   "Add Key",
+				Lead:    "Choose key source",
+				Choices: []string{"SCAN KEY", "INPUT SEED"},
+			}
+			if len(s.desc.Keys) > 0 {
+				s.add.Choices = append(s.add.Choices, "REMOVE KEY")
 			}
 		case input.Button3:
-			if e.Pressed {
-				ctx.Buttons[input.Button3] = false
-				s.confirm.Start(ctx, confirmDelay)
-			} else {
-				s.confirm = ConfirmDelay{}
+			n := len(s.desc.Keys)
+			if n < 2 {
+				s.warning = &ErrorScreen{
+					Title: "Too Few Keys",
+					Body:  "Add at least 2 cosigner keys to build a multisig wallet.",
+				}
+				continue
+			}
+			s.threshold = &ChoiceScreen{
+				Title: "Threshold",
+				Lead:  "Choose required signers",
+				// Default to a majority.
+				choice: n / 2,
+			}
+			for i := 1; i <= n; i++ {
+				s.threshold.Choices = append(s.threshold.Choices, fmt.Sprintf("%d OF %d", i, n))
 			}
 		}
 	}
-	r := layout.Rectangle{Max: dims}
-	sz := layoutWarning(ctx, ops.Begin(), th, dims.X, s.Title, s.Body)
-	op.Position(ops, ops.End(), r.Center(sz))
-	icn := s.Icon
-	if s.confirm.Running() {
-		icn = ProgressImage{
-			Progress: progress,
-			Src:      assets.IconProgress,
-		}
-	}
-	layoutNavigation(ctx, ops, th, dims,
-		NavButton{Button: input.Button1, Style: StyleSecondary, Icon: assets.IconBack},
-		NavButton{Button: input.Button3, Style: StylePrimary, Icon: icn},
-	)
-	return ConfirmNone
-}
-
-type ProgressImage struct {
-	Progress float32
-	Src      image.RGBA64Image
-}
 
-func (p ProgressImage) ColorModel() color.Model {
-	return color.AlphaModel
-}
+	op.ColorOp(ops, th.Background)
+	layoutTitle(ctx, ops, dims.X, th.Text, "Build Wallet")
 
-func (p ProgressImage) Bounds() image.Rectangle {
-	return p.Src.Bounds()
-}
+	r := layout.Rectangle{Max: dims}
+	btnw := assets.NavBtnPrimary.Bounds().Dx()
+	body := r.Shrink(leadingSize, btnw, 0, btnw)
 
-func (p ProgressImage) At(x, y int) color.Color {
-	return p.RGBA64At(x, y)
-}
-
-func (p ProgressImage) RGBA64At(x, y int) color.RGBA64 {
-	c := p.Bounds().Max.Add(p.Bounds().Min).Div(2)
-	d := image.Pt(x, y).Sub(c)
-	angle := float32(math.Atan2(float64(d.X), float64(d.Y)))
-	angle = math.Pi - angle
-	if angle > 2*math.Pi*p.Progress {
-		return color.RGBA64{}
+	bodyst := ctx.Styles.body
+	subst := ctx.Styles.subtitle
+	var bodytxt richText
+	bodytxt.Add(ops, subst, body.Dx(), th.Text, "Script")
+	bodytxt.Add(ops, bodyst, body.Dx(), th.Text, s.desc.Type.String())
+	bodytxt.Y += infoSpacing
+	bodytxt.Add(ops, subst, body.Dx(), th.Text, "Keys")
+	if len(s.desc.Keys) == 0 {
+		bodytxt.Add(ops, bodyst, body.Dx(), th.Text, "None")
 	}
-	return p.Src.RGBA64At(x, y)
-}
-
-type EngraveScreen struct {
-	Key          urtypes.KeyDescriptor
-	instructions []Instruction
-	plate        backup.Plate
-
-	cancel *ConfirmWarningScreen
-	step   int
-	dryRun struct {
-		timeout time.Time
-		enabled bool
+	for i, k := range s.desc.Keys {
+		bodytxt.Add(ops, bodyst, body.Dx(), th.Text, fmt.Sprintf("%d: %.8x", i+1, k.MasterFingerprint))
 	}
-	engrave engraveState
-	confirm ConfirmDelay
-}
-
-var errKeyNotInDescriptor = errors.New("share not part of descriptor")
-
-type errDuplicateKey struct {
-	Fingerprint uint32
-}
+	ops.Begin()
+	for _, l := range bodytxt.Lines {
+		l.W.Add(ops)
+	}
+	op.Position(ops, ops.End(), body.Min.Add(image.Pt(0, scrollFadeDist)))
 
-func (e *errDuplicateKey) Error() string {
-	return fmt.Sprintf("descriptor contains a duplicate share: %.8x", e.Fingerprint)
+	if s.warning == nil {
+		layoutNavigation(ctx, ops, th, dims,
+			NavButton{Button: input.Button1, Style: StyleSecondary, Icon: assets.IconBack},
+			NavButton{Button: input.Button2, Style: StyleSecondary, Icon: assets.IconPlus},
+			NavButton{Button: input.Button3, Style: StylePrimary, Icon: assets.IconCheckmark},
+		)
+	}
+	return nil, false
 }
 
-func (e *errDuplicateKey) Is(target error) bool {
-	_, ok := target.(*errDuplicateKey)
-	return ok
-}
+const (
+	// exportFragmentLen is the maximum length of the descriptor
+	// fragments in each exported QR code.
+	exportFragmentLen = 100
+	// exportFrameDelay is the time each exported QR code is shown.
+	exportFrameDelay = 500 * time.Millisecond
+)
 
-type errNonstandardDerivation struct {
-	Path urtypes.Path
-}
+// ExportScreen shows a descriptor as an animated sequence of
+// crypto-output QR codes.
+type ExportScreen struct {
+	Descriptor urtypes.OutputDescriptor
 
-func (e *errNonstandardDerivation) Error() string {
-	return fmt.Sprintf("non-standard derivation path: %v", e.Path)
+	qrs   []*qrcode.QRCode
+	start time.Time
+	// images are the rendered QR codes, and scale their module size.
+	images []*image.Gray
+	scale  int
 }
 
-func (e *errNonstandardDerivation) Is(target error) bool {
-	_, ok := target.(*errNonstandardDerivation)
-	return ok
+func NewExportScreen(desc urtypes.OutputDescriptor) *ExportScreen {
+	s := &ExportScreen{Descriptor: desc}
+	data := desc.Encode()
+	seqLen := (len(data) + exportFragmentLen - 1) / exportFragmentLen
+	for i := 0; i < seqLen; i++ {
+		content := strings.ToUpper(ur.Encode("crypto-output", data, i+1, seqLen))
+		qr, err := qrcode.New(content, qrcode.Low)
+		if err != nil {
+			// The content is bounded by exportFragmentLen.
+			panic(err)
+		}
+		s.qrs = append(s.qrs, qr)
+	}
+	return s
 }
 
-func NewErrorScreen(err error) *ErrorScreen {
-	var errDup *errDuplicateKey
-	var errNonStandard *errNonstandardDerivation
-	switch {
-	case errors.As(err, &errNonStandard):
-		return &ErrorScreen{
-			Title: "Non-standard Derivation",
-			Body:  fmt.Sprintf("The derivation path %v is not supported.", errNonStandard.Path),
-		}
-	case errors.As(err, &errDup):
-		return &ErrorScreen{
-			Title: "Duplicated Share",
-			Body:  fmt.Sprintf("The share %.8x is listed more than once in the wallet.", errDup.Fingerprint),
-		}
-	case errors.Is(err, backup.ErrDescriptorTooLarge):
-		return &ErrorScreen{
-			Title: "Too Large",
-			Body:  "The descriptor cannot fit any plate size.",
-		}
-	case errors.Is(err, errKeyNotInDescriptor):
-		return &ErrorScreen{
-			Title: "Unknown Share",
-			Body:  "The share is not part of the wallet.",
-		}
-	default:
-		return &ErrorScreen{
-			Title: "Error",
-			Body:  err.Error(),
+// image returns the rendered QR code with index idx, with the largest
+// module size that fits size.
+func (s *ExportScreen) image(idx int, size image.Point) *image.Gray {
+	bm := s.qrs[idx].Bitmap()
+	n := len(bm)
+	scale := size.X / n
+	if sy := size.Y / n; sy < scale {
+		scale = sy
+	}
+	if scale < 1 {
+		scale = 1
+	}
+	if scale != s.scale {
+		s.scale = scale
+		s.images = make([]*image.Gray, len(s.qrs))
+	}
+	if img := s.images[idx]; img != nil {
+		return img
+	}
+	img := image.NewGray(image.Rect(0, 0, n*scale, n*scale))
+	for y, row := range bm {
+		for x, dark := range row {
+			c := color.Gray{Y: 0xff}
+			if dark {
+				c = color.Gray{}
+			}
+			for i := 0; i < scale*scale; i++ {
+				img.SetGray(x*scale+i%scale, y*scale+i/scale, c)
+			}
 		}
 	}
-
+	s.images[idx] = img
+	return img
 }
 
-func validateDescriptor(desc urtypes.OutputDescriptor) error {
-	expPath := desc.DerivationPath()
-	keys := make(map[string]bool)
-	for _, k := range desc.Keys {
-		xpub := k.String()
-		if keys[xpub] {
-			return &errDuplicateKey{
-				Fingerprint: k.MasterFingerprint,
-			}
+// Layout reports whether the user accepted the descriptor, or
+// went back.
+func (s *ExportScreen) Layout(ctx *Context, ops op.Ctx, th *Colors, dims image.Point) (bool, bool) {
+	for {
+		e, ok := ctx.Next()
+		if !ok {
+			break
 		}
-		keys[xpub] = true
-		if len(expPath) == 0 || !reflect.DeepEqual(k.DerivationPath, expPath) {
-			return &errNonstandardDerivation{
-				Path: k.DerivationPath,
-			}
+		if !e.Click {
+			continue
+		}
+		switch e.Button {
+		case input.Button1:
+			return false, true
+		case input.Button3:
+			return true, true
 		}
 	}
-	// Do a dummy engrave to see whether the backup fits any plate.
-	m := make(bip39.Mnemonic, 24)
-	m = m.FixChecksum()
-	if _, err := engravePlate(desc, 0, m); err != nil {
-		return err
+	now := ctx.Platform.Now()
+	if s.start.IsZero() {
+		s.start = now
 	}
-	// Verify that every permutation of desc.Threshold shares can recover the
-	// descriptor. Note that this is impossible by construction and by exhaustive
-	// tests, but it's good to be paranoid.
-	if !backup.Recoverable(desc) {
-		return errors.New("Descriptor is not recoverable. That is a bug in the program; please report it.")
+	idx := 0
+	if len(s.qrs) > 1 {
+		frame := now.Sub(s.start) / exportFrameDelay
+		idx = int(frame) % len(s.qrs)
+		ctx.WakeupAfter(s.start.Add((frame + 1) * exportFrameDelay).Sub(now))
 	}
-	return nil
-}
 
-func engravePlate(desc urtypes.OutputDescriptor, keyIdx int, m bip39.Mnemonic) (backup.Plate, error) {
-	plateDesc := backup.PlateDesc{
-		Descriptor: desc,
-		Mnemonic:   m,
-		KeyIdx:     keyIdx,
-		Font:       &sh.Fontsh,
+	op.ColorOp(ops, th.Background)
+	layoutTitle(ctx, ops, dims.X, th.Text, "Export Wallet")
+
+	r := layout.Rectangle{Max: dims}
+	btnw := assets.NavBtnPrimary.Bounds().Dx()
+	content, lead := r.Shrink(leadingSize, btnw, 0, btnw).CutBottom(leadingSize)
+	img := s.image(idx, content.Size())
+	op.ImageOp(ops.Begin(), img)
+	op.Position(ops, ops.End(), content.Center(img.Bounds().Size()))
+
+	leadTxt := "Scan with your wallet"
+	if len(s.qrs) > 1 {
+		leadTxt = fmt.Sprintf("Scan with wallet (%d/%d)", idx+1, len(s.qrs))
 	}
-	return backup.Engrave(mjolnir.StrokeWidth, plateDesc)
+	sz := widget.LabelW(ops.Begin(), ctx.Styles.lead, dims.X-2*8, th.Text, leadTxt)
+	op.Position(ops, ops.End(), lead.Center(sz))
+
+	layoutNavigation(ctx, ops, th, dims,
+		NavButton{Button: input.Button1, Style: StyleSecondary, Icon: assets.IconBack},
+		NavButton{Button: input.Button3, Style: StylePrimary, Icon: assets.IconCheckmark},
+	)
+	return false, false
 }
 
-func NewEngraveScreen(ctx *Context, desc urtypes.OutputDescriptor, m bip39.Mnemonic, passphrase string) (*EngraveScreen, error) {
-	keyIdx, ok := descriptorKeyIdx(desc, m, passphrase)
-	if !ok {
-		return nil, errKeyNotInDescriptor
-	}
-	plate, err := engravePlate(desc, keyIdx, m)
-	if err != nil {
-		return nil, err
-	}
-	s := &EngraveScreen{
-		Key:   desc.Keys[keyIdx],
-		plate: plate,
-	}
-	if !ctx.Calibrated {
-		s.instructions = append(s.instructions, EngraveFirstSideA...)
-	} else {
-		s.instructions = append(s.instructions, EngraveSideA...)
-	}
-	if len(plate.Sides) > 1 {
-		s.instructions = append(s.instructions, EngraveSideB...)
-	}
-	s.instructions = append(s.instructions, EngraveSuccess...)
-	args := struct {
-		Name  string
-		Idx   int
-		Total int
-	}{
-		Name:  plateName(s.plate.Size),
-		Total: len(desc.Keys),
-		Idx:   keyIdx + 1,
-	}
-	for i, ins := range s.instructions {
-		tmpl := template.Must(template.New("instruction").Parse(ins.Body))
-		buf := new(bytes.Buffer)
-		tmpl.Execute(buf, args)
-		s.instructions[i].resolvedBody = buf.String()
-		// As a special case, the SH01 image is a placeholder for the plate-specific image.
-		if ins.Image == assets.SH01 {
-			s.instructions[i].Image = plateImage(s.plate.Size)
+func derivationPath(path urtypes.Path) string {
+	var b strings.Builder
+	b.WriteString("m")
+	for _, p := range path {
+		b.WriteString("/")
+		if p >= hdkeychain.HardenedKeyStart {
+			fmt.Fprintf(&b, "%d'", p-hdkeychain.HardenedKeyStart)
+		} else {
+			fmt.Fprintf(&b, "%d", p)
 		}
 	}
-	return s, nil
+	return b.String()
 }
 
-type engraveState struct {
-	dev          io.ReadWriteCloser
-	cancel       chan struct{}
-	progress     <-chan float32
-	errs         <-chan error
-	lastProgress float32
-	warning      *ErrorScreen
-	fatal        bool
+type ScanScreen struct {
+	Title   string
+	Lead    string
+	decoder ur.Decoder
+	feed    *image.Gray
+	camera  struct {
+		out  chan<- camera.Frame
+		in   <-chan camera.Frame
+		quit chan struct{}
+		err  error
+	}
 }
 
-func (s *EngraveScreen) close() {
-	e := s.engrave
-	s.engrave = engraveState{}
-	go func() {
-		if e.cancel != nil {
-			close(e.cancel)
-		}
-		// Wait a bit for cancellation.
-		if e.errs != nil {
-			select {
-			case <-e.errs:
-			case <-time.After(5 * time.Second):
-			}
-		}
-	}()
+func (s *ScanScreen) close() {
+	if s.camera.quit != nil {
+		s.camera.quit <- struct{}{}
+		<-s.camera.quit
+	}
 }
 
-func (s *EngraveScreen) moveStep(ctx *Context) bool {
-	ins := s.instructions[s.step]
-	if ins.Type == ConnectInstruction {
-		if s.engrave.dev != nil {
-			return false
-		}
-		s.engrave = engraveState{}
-		dev, err := ctx.Platform.Engraver()
-		if err != nil {
-			log.Printf("gui: failed to connect to engraver: %v", err)
-			s.engrave.warning = &ErrorScreen{
-				Title: "Connection Error",
-				Body:  "Failed to establish a connection to the engraver.",
+func (s *ScanScreen) Layout(ctx *Context, ops op.Ctx, dims image.Point) (any, bool) {
+	const cameraFrameScale = 4
+	if s.camera.quit == nil && s.camera.err == nil {
+		frames := make(chan camera.Frame, 1)
+		out := make(chan camera.Frame)
+		quit := make(chan struct{})
+		go func() {
+			defer close(quit)
+			defer close(frames)
+			closer, err := ctx.Platform.Camera(dims.Mul(cameraFrameScale), frames, out)
+			if err != nil {
+				log.Println(err)
+				select {
+				case frames <- camera.Frame{Err: err}:
+					<-quit
+				case <-quit:
+				}
+				return
 			}
-			return false
-		}
-		s.engrave.dev = dev
-	}
-	s.step++
-	if s.step == len(s.instructions) {
-		s.close()
-		return true
+			defer closer()
+			<-quit
+		}()
+		s.camera.quit = quit
+		s.camera.in = WakeupChan(ctx, frames)
+		s.camera.out = out
 	}
-	ins = s.instructions[s.step]
-	if ins.Type == EngraveInstruction {
-		prog := &mjolnir.Program{
-			DryRun: s.dryRun.enabled,
-		}
-		s.plate.Sides[ins.Side].Engrave(prog)
-		prog.Prepare()
-		cancel := make(chan struct{})
-		errs := make(chan error, 1)
-		progress := make(chan float32, 1)
-		s.engrave.cancel = cancel
-		s.engrave.errs = WakeupChan(ctx, errs)
-		s.engrave.progress = WakeupChan(ctx, progress)
-		dev := s.engrave.dev
-		go func() {
-			defer close(errs)
-			defer close(progress)
-			err := mjolnir.Engrave(dev, prog, progress, cancel)
-			dev.Close()
-			errs <- err
-		}()
-		go s.plate.Sides[ins.Side].Engrave(prog)
-	}
-	return false
-}
-
-func (s *EngraveScreen) Layout(ctx *Context, ops op.Ctx, dims image.Point) bool {
-loop:
-	for {
-		select {
-		case p := <-s.engrave.progress:
-			s.engrave.lastProgress = p
-		case err := <-s.engrave.errs:
-			s.engrave = engraveState{}
-			if err != nil {
-				log.Printf("gui: connection lost to engraver: %v", err)
-				s.engrave = engraveState{
-					warning: &ErrorScreen{
-						Title: "Connection Error",
-						Body:  "Connection to the engraver failed.",
-					},
-					fatal: true,
-				}
-				break
-			}
-			ctx.Calibrated = true
-			s.step++
-			if s.step == len(s.instructions) {
-				return true
-			}
-		default:
-			break loop
-		}
-	}
-
-	var progress float32
-	th := &engraveTheme
-	var ins Instruction
-	canPrev := false
 	for {
-		ins = s.instructions[s.step]
-		canPrev = s.step > 0 && s.instructions[s.step-1].Type == PrepareInstruction
-		progress = s.confirm.Progress(ctx)
-		if progress == 1. {
-			s.moveStep(ctx)
-			s.confirm = ConfirmDelay{}
-			continue
-		}
-		if !s.dryRun.timeout.IsZero() {
-			now := ctx.Platform.Now()
-			d := s.dryRun.timeout.Sub(now)
-			if d <= 0 {
-				ctx.Buttons[input.Button2] = false
-				s.dryRun.timeout = time.Time{}
-				s.dryRun.enabled = !s.dryRun.enabled
-			}
-		}
-		switch {
-		case s.cancel != nil:
-			result := s.cancel.Layout(ctx, ops.Begin(), th, dims)
-			dialog := ops.End()
-			switch result {
-			case ConfirmYes:
-				s.close()
-				return true
-			case ConfirmNo:
-				s.cancel = nil
-				continue
-			}
-			defer dialog.Add(ops)
-		case s.engrave.warning != nil:
-			dismissed := s.engrave.warning.Layout(ctx, ops.Begin(), th, dims)
-			dialog := ops.End()
-			if dismissed {
-				s.engrave.warning = nil
-				if s.engrave.fatal {
-					s.close()
-					return true
-				}
-				continue
-			}
-			defer dialog.Add(ops)
-		}
 		e, ok := ctx.Next()
 		if !ok {
 			break
@@ -1168,363 +1413,3003 @@ loop:
 		switch e.Button {
 		case input.Button1:
 			if !e.Click {
-				break
-			}
-			if canPrev {
-				s.step--
-			} else {
-				s.cancel = &ConfirmWarningScreen{
-					Title: "Cancel?",
-					Body:  "This will cancel the engraving process\n\nHold button to confirm.",
-					Icon:  assets.IconDiscard,
-				}
-			}
-		case input.Button2:
-			if e.Pressed {
-				s.dryRun.timeout = ctx.Platform.Now().Add(confirmDelay)
-				ctx.WakeupAfter(confirmDelay)
-			} else {
-				s.dryRun.timeout = time.Time{}
-			}
-		case input.Button3:
-			if ins.Type == ConnectInstruction {
-				if e.Pressed {
-					ctx.Buttons[input.Button3] = false
-					s.confirm.Start(ctx, confirmDelay)
-				} else {
-					s.confirm = ConfirmDelay{}
-				}
-				break
-			} else if !e.Click || ins.Type == EngraveInstruction {
-				break
-			}
-			if s.moveStep(ctx) {
-				return true
+				continue
 			}
+			s.close()
+			return nil, true
 		}
 	}
 
-	op.ColorOp(ops, th.Background)
-	layoutTitle(ctx, ops, dims.X, th.Text, fmt.Sprintf("Engrave Plate"))
-
-	r := layout.Rectangle{Max: dims}
-	_, subt := r.CutTop(leadingSize)
-	subtsz := widget.Label(ops.Begin(), ctx.Styles.body, th.Text, fmt.Sprintf("%.8x", s.Key.MasterFingerprint))
-	op.Position(ops, ops.End(), subt.N(subtsz).Sub(image.Pt(0, 4)))
-
-	const margin = 8
-	_, content := r.CutTop(leadingSize)
-	if ins.Type == EngraveInstruction {
-		progress := fmt.Sprintf("%d%%", int(s.engrave.lastProgress*100))
-		_, content = subt.CutTop(subtsz.Y)
-		middle, _ := content.CutBottom(leadingSize)
-		op.Offset(ops, middle.Center(assets.ProgressCircle.Bounds().Size()))
-		op.MaskOp(ops, ProgressImage{
-			Progress: s.engrave.lastProgress,
-			Src:      assets.ProgressCircle,
-		})
-		op.ColorOp(ops, th.Text)
-		sz := widget.Label(ops.Begin(), ctx.Styles.progress, th.Text, progress)
-		op.Position(ops, ops.End(), middle.Center(sz))
+	if s.feed == nil || dims != s.feed.Bounds().Size() {
+		s.feed = image.NewGray(image.Rectangle{Max: dims})
 	}
-	content = content.Shrink(0, margin, 0, margin)
-	content, lead := content.CutBottom(leadingSize)
-	bodysz := widget.LabelW(ops.Begin(), ctx.Styles.lead, content.Dx(), th.Text, ins.resolvedBody)
-	if img := ins.Image; img != nil {
-		sz := img.Bounds().Size()
-		op.Offset(ops, image.Pt((bodysz.X-sz.X)/2, bodysz.Y))
-		op.ImageOp(ops, img)
-		if sz.X > bodysz.X {
-			bodysz.X = sz.X
+	select {
+	case frame := <-s.camera.in:
+		if frame.Err != nil {
+			s.camera.quit <- struct{}{}
+			<-s.camera.quit
+			s.camera.err = frame.Err
+			s.camera.quit = nil
+			s.camera.in = nil
+			s.camera.out = nil
+			break
 		}
-		bodysz.Y += sz.Y
-	}
-	op.Position(ops, ops.End(), content.Center(bodysz))
-	leadsz := widget.LabelW(ops.Begin(), ctx.Styles.lead, dims.X-2*margin, th.Text, ins.Lead)
-	op.Position(ops, ops.End(), lead.Center(leadsz))
-
-	progressw := dims.X * (s.step + 1) / len(s.instructions)
-	op.ClipOp(image.Rectangle{Max: image.Pt(progressw, 2)}).Add(ops)
-	op.ColorOp(ops, th.Text)
-
-	if s.dryRun.enabled {
-		sz := widget.Label(ops.Begin(), ctx.Styles.debug, th.Text, "dry-run")
-		op.Position(ops, ops.End(), r.SE(sz).Sub(image.Pt(4, 0)))
-	}
+		ycbcr := frame.Image.(*image.YCbCr)
+		gray := &image.Gray{Pix: ycbcr.Y, Stride: ycbcr.YStride, Rect: ycbcr.Bounds()}
 
-	if s.cancel == nil && s.engrave.warning == nil {
-		icnBack := assets.IconBack
-		if canPrev {
-			icnBack = assets.IconLeft
+		scaleRot(s.feed, gray)
+		// Re-create image (but not backing store) to ensure redraw.
+		copy := *s.feed
+		s.feed = &copy
+		results, err := zbar.Scan(gray)
+		s.camera.out <- frame
+		if err != nil {
+			break
 		}
-		layoutNavigation(ctx, ops, th, dims, NavButton{Button: input.Button1, Style: StyleSecondary, Icon: icnBack})
-		switch ins.Type {
-		case EngraveInstruction:
-		case ConnectInstruction:
-			icn := assets.IconHammer
-			if s.confirm.Running() {
-				icn = ProgressImage{
-					Progress: progress,
-					Src:      assets.IconProgress,
-				}
+		for _, res := range results {
+			v, ok := s.parseQR(res)
+			if ok {
+				s.close()
+				return v, true
 			}
-			layoutNavigation(ctx, ops, th, dims, NavButton{Button: input.Button3, Style: StylePrimary, Icon: icn})
-		default:
-			layoutNavigation(ctx, ops, th, dims, NavButton{Button: input.Button3, Style: StylePrimary, Icon: assets.IconRight})
 		}
-	}
-	return false
-}
-
-func plateImage(p backup.PlateSize) image.RGBA64Image {
-	switch p {
-	case backup.SmallPlate:
-		return assets.SH01
-	case backup.SquarePlate:
-		return assets.SH02
-	case backup.LargePlate:
-		return assets.SH03
 	default:
-		panic("unsupported plate")
 	}
-}
+	th := &cameraTheme
+	r := layout.Rectangle{Max: dims}
 
-func plateName(p backup.PlateSize) string {
-	switch p {
-	case backup.SmallPlate:
-		return "SH01"
-	case backup.SquarePlate:
-		return "SH02"
-	case backup.LargePlate:
-		return "SH03"
-	default:
-		panic("unsupported plate")
-	}
-}
+	op.ImageOp(ops, s.feed)
 
-type InstructionType int
+	op.ImageOp(ops.Begin(), assets.CameraCorners)
+	op.Position(ops, ops.End(), r.Center(assets.CameraCorners.Bounds().Size()))
 
-const (
-	PrepareInstruction InstructionType = iota
-	ConnectInstruction
-	EngraveInstruction
-)
+	underlay := assets.ButtonFocused
+	background := func(ops op.Ctx, w op.CallOp, dst image.Rectangle, pos image.Point) {
+		op.MaskOp(ops.Begin(), underlay.For(dst))
+		op.ColorOp(ops, color.NRGBA{A: theme.overlayMask})
+		op.Position(ops, ops.End(), image.Point{})
+		op.Position(ops, w, pos)
+	}
 
-type Instruction struct {
-	Body  string
-	Lead  string
+	title := layoutTitle(ctx, ops.Begin(), dims.X, th.Text, s.Title)
+	title.Min.Y += 4
+	title.Max.Y -= 4
+	background(ops, ops.End(), title, image.Point{})
+
+	// Camera error, if any.
+	if err := s.camera.err; err != nil {
+		sz := widget.LabelW(ops.Begin(), ctx.Styles.body, dims.X-2*16, th.Text, err.Error())
+		op.Position(ops, ops.End(), r.Center(sz))
+	}
+
+	width := dims.X - 2*8
+	// Lead text.
+	sz := widget.LabelW(ops.Begin(), ctx.Styles.lead, width, th.Text, s.Lead)
+	top, footer := r.CutBottom(sz.Y + 2*12)
+	pos := footer.Center(sz)
+	background(ops, ops.End(), image.Rectangle{Min: pos, Max: pos.Add(sz)}, pos)
+
+	// Progress
+	progress := int(100 * s.decoder.Progress())
+	if progress > 0 {
+		sz = widget.LabelW(ops.Begin(), ctx.Styles.lead, width, th.Text, fmt.Sprintf("%d%%", progress))
+		_, percent := top.CutBottom(sz.Y)
+		pos := percent.Center(sz)
+		background(ops, ops.End(), image.Rectangle{Min: pos, Max: pos.Add(sz)}, pos)
+	}
+
+	nav := layoutNavigation(ctx, ops.Begin(), th, dims, NavButton{Button: input.Button1, Style: StyleSecondary, Icon: assets.IconBack})
+	nav = image.Rectangle(layout.Rectangle(nav).Shrink(underlay.Padding()).Shrink(-2, -4, -2, -2))
+	background(ops, ops.End(), nav, image.Point{})
+	return nil, false
+}
+
+// scaleRot is a specialized function for fast scaling and rotation of
+// the camera frames.
+func scaleRot(dst, src *image.Gray) {
+	db := dst.Bounds()
+	sb := src.Bounds()
+	if db.Empty() {
+		return
+	}
+	scale := sb.Dx() / db.Dx()
+	for y := 0; y < db.Dy(); y++ {
+		for x := 0; x < db.Dx(); x++ {
+			// Rotate and scale.
+			c := src.GrayAt(sb.Max.Y-1-y*scale, x*scale+sb.Min.X)
+			dst.SetGray(x+db.Min.X, y+db.Min.Y, c)
+		}
+	}
+}
+
+func (s *ScanScreen) parseQR(qr []byte) (any, bool) {
+	uqr := strings.ToUpper(string(qr))
+	if !strings.HasPrefix(uqr, "UR:") {
+		s.decoder = ur.Decoder{}
+		return qr, true
+	}
+	if err := s.decoder.Add(uqr); err != nil {
+		// Incompatible fragment. Reset decoder and try again.
+		s.decoder = ur.Decoder{}
+		s.decoder.Add(uqr)
+	}
+	typ, enc, err := s.decoder.Result()
+	if err != nil {
+		s.decoder = ur.Decoder{}
+		return nil, false
+	}
+	if enc == nil {
+		return nil, false
+	}
+	s.decoder = ur.Decoder{}
+	v, err := urtypes.Parse(typ, enc)
+	if err != nil {
+		return nil, false
+	}
+	return v, true
+}
+
+type ErrorScreen struct {
+	Title string
+	Body  string
+}
+
+func (s *ErrorScreen) Layout(ctx *Context, ops op.Ctx, th *Colors, dims image.Point) bool {
+	for {
+		e, ok := ctx.Next()
+		if !ok {
+			break
+		}
+		switch e.Button {
+		case input.Button3:
+			if e.Click {
+				return true
+			}
+		}
+	}
+	r := layout.Rectangle{Max: dims}
+	sz := layoutWarning(ctx, ops.Begin(), th, dims.X, s.Title, s.Body)
+	op.Position(ops, ops.End(), r.Center(sz))
+	layoutNavigation(ctx, ops, th, dims, NavButton{Button: input.Button3, Style: StylePrimary, Icon: assets.IconCheckmark})
+	return false
+}
+
+type ConfirmWarningScreen struct {
+	Title string
+	Body  string
+	Icon  image.RGBA64Image
+
+	confirm ConfirmDelay
+}
+
+type ConfirmResult int
+
+const (
+	ConfirmNone ConfirmResult = iota
+	ConfirmNo
+	ConfirmYes
+)
+
+type ConfirmDelay struct {
+	timeout time.Time
+}
+
+func (c *ConfirmDelay) Start(ctx *Context, delay time.Duration) {
+	c.timeout = ctx.Platform.Now().Add(delay)
+}
+
+func (c *ConfirmDelay) Progress(ctx *Context) float32 {
+	if !c.Running() {
+		return 0.
+	}
+	now := ctx.Platform.Now()
+	d := c.timeout.Sub(now)
+	if d <= 0 {
+		return 1.
+	}
+	ctx.WakeupAfter(0)
+	return 1. - float32(d.Seconds()/confirmDelay.Seconds())
+}
+
+func (c *ConfirmDelay) Running() bool {
+	return !c.timeout.IsZero()
+}
+
+const confirmDelay = 1 * time.Second
+
+func (s *ConfirmWarningScreen) Layout(ctx *Context, ops op.Ctx, th *Colors, dims image.Point) ConfirmResult {
+	var progress float32
+	for {
+		progress = s.confirm.Progress(ctx)
+		if progress == 1 {
+			return ConfirmYes
+		}
+		e, ok := ctx.Next()
+		if !ok {
+			break
+		}
+		switch e.Button {
+		case input.Button1:
+			if e.Click {
+				return ConfirmNo
+			}
+		case input.Button3:
+			if e.Pressed {
+				ctx.Buttons[input.Button3] = false
+				s.confirm.Start(ctx, confirmDelay)
+			} else {
+				s.confirm = ConfirmDelay{}
+			}
+		}
+	}
+	r := layout.Rectangle{Max: dims}
+	sz := layoutWarning(ctx, ops.Begin(), th, dims.X, s.Title, s.Body)
+	op.Position(ops, ops.End(), r.Center(sz))
+	icn := s.Icon
+	if s.confirm.Running() {
+		icn = ProgressImage{
+			Progress: progress,
+			Src:      assets.IconProgress,
+		}
+	}
+	layoutNavigation(ctx, ops, th, dims,
+		NavButton{Button: input.Button1, Style: StyleSecondary, Icon: assets.IconBack},
+		NavButton{Button: input.Button3, Style: StylePrimary, Icon: icn},
+	)
+	return ConfirmNone
+}
+
+type ProgressImage struct {
+	Progress float32
+	Src      image.RGBA64Image
+}
+
+func (p ProgressImage) ColorModel() color.Model {
+	return color.AlphaModel
+}
+
+func (p ProgressImage) Bounds() image.Rectangle {
+	return p.Src.Bounds()
+}
+
+func (p ProgressImage) At(x, y int) color.Color {
+	return p.RGBA64At(x, y)
+}
+
+func (p ProgressImage) RGBA64At(x, y int) color.RGBA64 {
+	c := p.Bounds().Max.Add(p.Bounds().Min).Div(2)
+	d := image.Pt(x, y).Sub(c)
+	angle := float32(math.Atan2(float64(d.X), float64(d.Y)))
+	angle = math.Pi - angle
+	if angle > 2*math.Pi*p.Progress {
+		return color.RGBA64{}
+	}
+	return p.Src.RGBA64At(x, y)
+}
+
+type EngraveScreen struct {
+	Key          urtypes.KeyDescriptor
+	instructions []Instruction
+	plates       []backup.Plate
+	// strokeWidth is the stroke width of the plates, and layout, if
+	// not nil, lays out the plates for another stroke width.
+	strokeWidth float32
+	layout      func(strokeWidth float32) ([]backup.Plate, error)
+	// keyIdx is the index of the share among shares.
+	keyIdx int
+	shares int
+
+	cancel *ConfirmWarningScreen
+	step   int
+	dryRun struct {
+		timeout time.Time
+		enabled bool
+	}
+	engrave engraveState
+	confirm ConfirmDelay
+	// resume is the number of commands to skip when engraving
+	// the next side.
+	resume int
+	// interruption is a recorded interruption of a side, which
+	// resumeChoice offers to resume.
+	interruption struct {
+		step      int
+		completed int
+		side      sideID
+	}
+	resumeChoice *ChoiceScreen
+	// choose selects the plate sides to engrave, and choices
+	// lists the sides of each choice.
+	choose  *ChoiceScreen
+	choices [][]plateSide
+	// completed is set when every instruction is completed.
+	completed bool
+}
+
+var errKeyNotInDescriptor = errors.New("share not part of descriptor")
+
+type errDuplicateKey struct {
+	Fingerprint uint32
+}
+
+func (e *errDuplicateKey) Error() string {
+	return fmt.Sprintf("descriptor contains a duplicate share: %.8x", e.Fingerprint)
+}
+
+func (e *errDuplicateKey) Is(target error) bool {
+	_, ok := target.(*errDuplicateKey)
+	return ok
+}
+
+type errNonstandardDerivation struct {
+	Path urtypes.Path
+}
+
+func (e *errNonstandardDerivation) Error() string {
+	return fmt.Sprintf("non-standard derivation path: %v", e.Path)
+}
+
+func (e *errNonstandardDerivation) Is(target error) bool {
+	_, ok := target.(*errNonstandardDerivation)
+	return ok
+}
+
+func NewErrorScreen(err error) *ErrorScreen {
+	var errDup *errDuplicateKey
+	var errNonStandard *errNonstandardDerivation
+	var errKeepOut *backup.KeepOutError
+	var errVerify *backup.VerifyError
+	switch {
+	case errors.As(err, &errNonStandard):
+		return &ErrorScreen{
+			Title: "Non-standard Derivation",
+			Body:  fmt.Sprintf("The derivation path %v is not supported.", errNonStandard.Path),
+		}
+	case errors.As(err, &errDup):
+		return &ErrorScreen{
+			Title: "Duplicated Share",
+			Body:  fmt.Sprintf("The share %.8x is listed more than once in the wallet.", errDup.Fingerprint),
+		}
+	case errors.As(err, &errKeepOut):
+		return &ErrorScreen{
+			Title: "Too Large",
+			Body:  fmt.Sprintf("The descriptor cannot fit any plate without covering its mounting %ss.", errKeepOut.Zone.Kind),
+		}
+	case errors.As(err, &errVerify):
+		return &ErrorScreen{
+			Title: "Verification Failed",
+			Body:  fmt.Sprintf("Side %d of plate %d doesn't scan correctly: %s.", errVerify.Side+1, errVerify.Plate+1, errVerify.Reason),
+		}
+	case errors.Is(err, backup.ErrDescriptorTooLarge):
+		return &ErrorScreen{
+			Title: "Too Large",
+			Body:  "The descriptor cannot fit any plate size.",
+		}
+	case errors.Is(err, errKeyNotInDescriptor):
+		return &ErrorScreen{
+			Title: "Unknown Share",
+			Body:  "The share is not part of the wallet.",
+		}
+	default:
+		return &ErrorScreen{
+			Title: "Error",
+			Body:  err.Error(),
+		}
+	}
+
+}
+
+func validateDescriptor(desc urtypes.OutputDescriptor) error {
+	expPath := desc.DerivationPath()
+	keys := make(map[string]bool)
+	for _, k := range desc.Keys {
+		xpub := k.String()
+		if keys[xpub] {
+			return &errDuplicateKey{
+				Fingerprint: k.MasterFingerprint,
+			}
+		}
+		keys[xpub] = true
+		if len(expPath) == 0 || !reflect.DeepEqual(k.DerivationPath, expPath) {
+			return &errNonstandardDerivation{
+				Path: k.DerivationPath,
+			}
+		}
+	}
+	// Do a dummy engrave to see whether the backup fits any plate.
+	m := make(bip39.Mnemonic, 24)
+	m = m.FixChecksum()
+	if _, err := layoutShare(defaultStrokeWidth, desc, 0, m); err != nil {
+		return err
+	}
+	// Verify that every permutation of desc.Threshold shares can recover the
+	// descriptor. Note that this is impossible by construction and by exhaustive
+	// tests, but it's good to be paranoid.
+	if !backup.Recoverable(desc) {
+		return errors.New("Descriptor is not recoverable. That is a bug in the program; please report it.")
+	}
+	return nil
+}
+
+func plateDesc(desc urtypes.OutputDescriptor, keyIdx int, m bip39.Mnemonic) backup.PlateDesc {
+	return backup.PlateDesc{
+		Descriptor: desc,
+		Mnemonic:   m,
+		KeyIdx:     keyIdx,
+		Font:       &sh.Fontsh,
+	}
+}
+
+// defaultStrokeWidth is the stroke width plates are laid out for until
+// an engraver is connected.
+const defaultStrokeWidth = mjolnir.StrokeWidth
+
+// layoutShare lays out the plates of a share for an engraver with the
+// stroke width, and verifies that the engraved QR codes scan back to
+// their content.
+func layoutShare(strokeWidth float32, desc urtypes.OutputDescriptor, keyIdx int, m bip39.Mnemonic) ([]backup.Plate, error) {
+	pdesc := plateDesc(desc, keyIdx, m)
+	plates, err := backup.EngraveShare(strokeWidth, pdesc)
+	if err != nil {
+		return nil, err
+	}
+	if err := backup.Verify(strokeWidth, pdesc, plates...); err != nil {
+		return nil, err
+	}
+	return plates, nil
+}
+
+func NewEngraveScreen(ctx *Context, desc urtypes.OutputDescriptor, m bip39.Mnemonic, passphrase string) (*EngraveScreen, error) {
+	keyIdx, ok := descriptorKeyIdx(desc, m, passphrase)
+	if !ok {
+		return nil, errKeyNotInDescriptor
+	}
+	return newEngraveScreen(ctx, desc, keyIdx, m)
+}
+
+// newEngraveScreen creates a screen for engraving every side of
+// the plates of a share.
+func newEngraveScreen(ctx *Context, desc urtypes.OutputDescriptor, keyIdx int, m bip39.Mnemonic) (*EngraveScreen, error) {
+	layout := func(strokeWidth float32) ([]backup.Plate, error) {
+		return layoutShare(strokeWidth, desc, keyIdx, m)
+	}
+	strokeWidth := ctx.strokeWidth
+	if strokeWidth == 0 {
+		strokeWidth = defaultStrokeWidth
+	}
+	plates, err := layout(strokeWidth)
+	if err != nil {
+		return nil, err
+	}
+	return newPlatesEngraveScreen(ctx, desc, keyIdx, sharePlates{plates: plates, strokeWidth: strokeWidth}, layout), nil
+}
+
+// newPlatesEngraveScreen is like newEngraveScreen for validated plates.
+// If layout is nil, the plates can't be laid out for an engraver with
+// another stroke width.
+func newPlatesEngraveScreen(ctx *Context, desc urtypes.OutputDescriptor, keyIdx int, plates sharePlates, layout func(strokeWidth float32) ([]backup.Plate, error)) *EngraveScreen {
+	s := &EngraveScreen{
+		Key:         desc.Keys[keyIdx],
+		keyIdx:      keyIdx,
+		shares:      len(desc.Keys),
+		plates:      plates.plates,
+		strokeWidth: plates.strokeWidth,
+		layout:      layout,
+	}
+	s.instruct(ctx, s.sides())
+	return s
+}
+
+// plateSide identifies a side of a plate.
+type plateSide struct {
+	Plate, Side int
+}
+
+// sides lists every side of the plates in engraving order.
+func (s *EngraveScreen) sides() []plateSide {
+	var sides []plateSide
+	for i, p := range s.plates {
+		for j := range p.Sides {
+			sides = append(sides, plateSide{Plate: i, Side: j})
+		}
+	}
+	return sides
+}
+
+// instruct replaces the instructions with the instructions for
+// engraving sides.
+func (s *EngraveScreen) instruct(ctx *Context, sides []plateSide) {
+	s.instructions = nil
+	s.step = 0
+	s.resume = 0
+	s.resumeChoice = nil
+	for i, ps := range sides {
+		p := s.plates[ps.Plate]
+		var instructions []Instruction
+		switch {
+		case ps.Side > 0 && i > 0 && sides[i-1] == plateSide{Plate: ps.Plate, Side: ps.Side - 1}:
+			instructions = append(instructions, EngraveSideB...)
+		case ps.Side > 0:
+			instructions = append(instructions, EngraveOnlySideB...)
+		case i == 0 && !ctx.Calibration.Calibrated:
+			instructions = append(instructions, EngraveFirstSideA...)
+		case ps.Plate > 0:
+			instructions = append(instructions, EngraveNextPlate...)
+		default:
+			instructions = append(instructions, EngraveSideA...)
+		}
+		args := struct {
+			Name   string
+			Idx    int
+			Total  int
+			Plate  int
+			Plates int
+		}{
+			Name:   plateName(p.Size),
+			Total:  s.shares,
+			Idx:    s.keyIdx + 1,
+			Plate:  ps.Plate + 1,
+			Plates: len(s.plates),
+		}
+		for j, ins := range instructions {
+			instructions[j].Plate = ps.Plate
+			instructions[j].resolvedBody = resolveBody(ins.Body, args)
+			// As a special case, the SH01 image is a placeholder for the plate-specific image.
+			if ins.Image == assets.SH01 {
+				instructions[j].Image = plateImage(p.Size)
+			}
+		}
+		s.instructions = append(s.instructions, instructions...)
+	}
+	s.instructions = append(s.instructions, EngraveSuccess...)
+	for i := len(s.instructions) - len(EngraveSuccess); i < len(s.instructions); i++ {
+		s.instructions[i].resolvedBody = s.instructions[i].Body
+	}
+	// Offer to resume an interrupted side.
+	for i, ins := range s.instructions {
+		if ins.Type != EngraveInstruction {
+			continue
+		}
+		id := newSideID(s.plates[ins.Plate].Sides[ins.Side])
+		if n, ok := ctx.interrupted.Get(id); ok {
+			s.interruption.step = i - 1
+			s.interruption.completed = n
+			s.interruption.side = id
+			s.resumeChoice = &ChoiceScreen{
+				Title:   "Resume Engraving",
+				Lead:    fmt.Sprintf("Side %c of plate %d was interrupted", 'A'+ins.Side, ins.Plate+1),
+				Choices: []string{"RESUME", "START OVER"},
+			}
+			break
+		}
+	}
+}
+
+// chooseSides offers to engrave every side or a single side. The
+// side focus is pre-selected if not nil.
+func (s *EngraveScreen) chooseSides(title string, focus *plateSide) {
+	s.choose = &ChoiceScreen{
+		Title:   title,
+		Lead:    "Choose sides to engrave",
+		Choices: []string{"ALL SIDES"},
+	}
+	s.choices = [][]plateSide{s.sides()}
+	for _, ps := range s.sides() {
+		name := fmt.Sprintf("SIDE %c", 'A'+ps.Side)
+		if len(s.plates) > 1 {
+			name = fmt.Sprintf("PLATE %d %s", ps.Plate+1, name)
+		}
+		if focus != nil && ps == *focus {
+			s.choose.choice = len(s.choices)
+		}
+		s.choose.Choices = append(s.choose.Choices, name)
+		s.choices = append(s.choices, []plateSide{ps})
+	}
+}
+
+// resolveBody expands the template of an instruction body.
+func resolveBody(body string, args any) string {
+	tmpl := template.Must(template.New("instruction").Parse(body))
+	buf := new(bytes.Buffer)
+	tmpl.Execute(buf, args)
+	return buf.String()
+}
+
+// sideID identifies an engraving by a fingerprint of its commands.
+// The fingerprint is a truncated hash, short enough that it reveals
+// nothing useful about the engraving when stored on the SD card.
+type sideID [8]byte
+
+func newSideID(side engrave.Command) sideID {
+	h := &hashProgram{h: sha256.New()}
+	side.Engrave(h)
+	var id sideID
+	copy(id[:], h.h.Sum(nil))
+	return id
+}
+
+func (id sideID) MarshalText() ([]byte, error) {
+	return []byte(hex.EncodeToString(id[:])), nil
+}
+
+func (id *sideID) UnmarshalText(text []byte) error {
+	b, err := hex.DecodeString(string(text))
+	if err != nil {
+		return err
+	}
+	if len(b) != len(id) {
+		return fmt.Errorf("invalid side id %q", text)
+	}
+	copy(id[:], b)
+	return nil
+}
+
+type hashProgram struct {
+	h hash.Hash
+}
+
+func (p *hashProgram) Move(to f32.Vec2) {
+	p.write('M', to)
+}
+
+func (p *hashProgram) Line(to f32.Vec2) {
+	p.write('L', to)
+}
+
+func (p *hashProgram) write(op byte, to f32.Vec2) {
+	var buf [9]byte
+	buf[0] = op
+	binary.LittleEndian.PutUint32(buf[1:], math.Float32bits(to[0]))
+	binary.LittleEndian.PutUint32(buf[5:], math.Float32bits(to[1]))
+	p.h.Write(buf[:])
+}
+
+// interruptions records the progress of interrupted engravings, for
+// resuming them. Only the side fingerprints and their number of
+// completed commands are recorded, which makes them safe to store on
+// the SD card.
+type interruptions struct {
+	mu    sync.Mutex
+	sides map[sideID]int
+}
+
+// interruptionsFile is the path of the interruptions on the SD card.
+const interruptionsFile = "seedhammer/interruptions.json"
+
+// LoadInterruptions reads the recorded interruptions from the SD card.
+func (c *Context) LoadInterruptions() error {
+	data, err := c.Platform.LoadFile(interruptionsFile)
+	if err != nil {
+		return err
+	}
+	var sides map[sideID]int
+	if err := json.Unmarshal(data, &sides); err != nil {
+		return fmt.Errorf("%s: %w", interruptionsFile, err)
+	}
+	i := &c.interrupted
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.sides = sides
+	return nil
+}
+
+// setInterrupted records the progress of an interrupted side and
+// writes the interruptions to the SD card. The interruptions are kept
+// in memory even if they can't be written.
+func (c *Context) setInterrupted(id sideID, completed int) {
+	i := &c.interrupted
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.sides == nil {
+		i.sides = make(map[sideID]int)
+	}
+	i.sides[id] = completed
+	c.storeInterruptions()
+}
+
+// clearInterrupted forgets the progress of a side.
+func (c *Context) clearInterrupted(id sideID) {
+	i := &c.interrupted
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if _, ok := i.sides[id]; !ok {
+		return
+	}
+	delete(i.sides, id)
+	c.storeInterruptions()
+}
+
+// storeInterruptions writes the interruptions to the SD card. It must
+// be called with the interruptions locked.
+func (c *Context) storeInterruptions() {
+	data, err := json.Marshal(c.interrupted.sides)
+	if err == nil {
+		err = c.Platform.StoreFile(interruptionsFile, data)
+	}
+	if err != nil {
+		log.Printf("gui: failed to store interruptions: %v", err)
+	}
+}
+
+func (i *interruptions) Get(id sideID) (int, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	n, ok := i.sides[id]
+	return n, ok
+}
+
+type engraveState struct {
+	dev    engrave.Engraver
+	caps   engrave.Capabilities
+	job    *engrave.Job
+	side   sideID
+	pause  chan bool
+	paused bool
+	// parked reports whether the engraver has stopped for the pause.
+	parked       bool
+	parks        <-chan bool
+	cancel       context.CancelFunc
+	progress     <-chan float32
+	errs         <-chan error
+	lastProgress float32
+	warning      *ErrorScreen
+	fatal        bool
+	// retry offers to engrave again when the fatal warning
+	// is dismissed.
+	retry bool
+}
+
+// close cancels the engraving, if any.
+func (s *EngraveScreen) close() {
+	e := s.engrave
+	s.engrave = engraveState{}
+	go e.stop()
+}
+
+// cancelled is like close, but records the progress of the cancelled
+// engraving for resuming it.
+func (s *EngraveScreen) cancelled(ctx *Context) {
+	e := s.engrave
+	s.engrave = engraveState{}
+	go func() {
+		if e.stop() {
+			s.interrupted(ctx, e)
+		}
+	}()
+}
+
+// stop cancels the engraving and reports whether it ended.
+func (e engraveState) stop() bool {
+	if e.cancel != nil {
+		e.cancel()
+	}
+	if e.errs == nil {
+		return false
+	}
+	// Wait a bit for cancellation.
+	select {
+	case <-e.errs:
+		return true
+	case <-time.After(5 * time.Second):
+		return false
+	}
+}
+
+// interrupted records the progress of an interrupted engraving
+// and reports whether it can be resumed.
+func (s *EngraveScreen) interrupted(ctx *Context, e engraveState) bool {
+	if e.job == nil || e.job.DryRun || !e.caps.Resume {
+		return false
+	}
+	n := e.job.Completed
+	if n == 0 {
+		return false
+	}
+	ctx.setInterrupted(e.side, n)
+	return true
+}
+
+// relayout lays out the plates for an engraver with a different
+// stroke width, and reports whether the plates changed size.
+func (s *EngraveScreen) relayout(strokeWidth float32) (bool, error) {
+	if strokeWidth == s.strokeWidth {
+		return false, nil
+	}
+	if s.layout == nil {
+		return false, fmt.Errorf("plates laid out for stroke width %v", s.strokeWidth)
+	}
+	plates, err := s.layout(strokeWidth)
+	if err != nil {
+		return false, err
+	}
+	resized := len(plates) != len(s.plates)
+	for i := 0; i < len(plates) && !resized; i++ {
+		resized = plates[i].Size != s.plates[i].Size
+	}
+	s.plates = plates
+	s.strokeWidth = strokeWidth
+	return resized, nil
+}
+
+func (s *EngraveScreen) moveStep(ctx *Context) bool {
+	ins := s.instructions[s.step]
+	if ins.Type == ConnectInstruction {
+		if s.engrave.dev != nil {
+			return false
+		}
+		s.engrave = engraveState{}
+		dev, err := ctx.Platform.Engraver()
+		if err != nil {
+			log.Printf("gui: failed to connect to engraver: %v", err)
+			s.engrave.warning = &ErrorScreen{
+				Title: "Connection Error",
+				Body:  "Failed to establish a connection to the engraver.",
+			}
+			return false
+		}
+		caps := dev.Capabilities()
+		ctx.strokeWidth = caps.StrokeWidth
+		resized, err := s.relayout(caps.StrokeWidth)
+		if err != nil {
+			log.Printf("gui: layout for stroke width %v: %v", caps.StrokeWidth, err)
+		}
+		if resized {
+			// The prepared plates no longer match; start over with
+			// the instructions for the new plates.
+			dev.Close()
+			s.instruct(ctx, s.sides())
+			s.engrave.warning = &ErrorScreen{
+				Title: "Plates Changed",
+				Body:  "The engraver needs different plates. Follow the instructions again.",
+			}
+			return false
+		}
+		if err != nil || !s.platesFit(caps.WorkArea, ctx.Calibration.Offset) {
+			dev.Close()
+			s.engrave.warning = &ErrorScreen{
+				Title: "Unsupported Engraver",
+				Body:  "The plates don't fit the engraver.",
+			}
+			return false
+		}
+		// Refuse designs that would scratch the clamps or run into
+		// the travel limits, as engraved with the calibration offset.
+		travel := engrave.RectOf(caps.WorkArea)
+		for _, p := range s.plates {
+			p = backup.Plate{Size: p.Size, Sides: append([]engrave.Command(nil), p.Sides...)}
+			for i, side := range p.Sides {
+				p.Sides[i] = ctx.calibrated(side)
+			}
+			if err := p.Preflight(caps.StrokeWidth, travel); err != nil {
+				dev.Close()
+				log.Printf("gui: preflight: %v", err)
+				s.engrave.warning = preflightErrorScreen(err)
+				s.engrave.fatal = true
+				return false
+			}
+		}
+		s.engrave.dev = dev
+		s.engrave.caps = caps
+	}
+	s.step++
+	if s.step == len(s.instructions) {
+		s.completed = true
+		s.close()
+		return true
+	}
+	ins = s.instructions[s.step]
+	if ins.Type == EngraveInstruction {
+		side := s.plates[ins.Plate].Sides[ins.Side]
+		pause := make(chan bool, 1)
+		engraveCtx, cancel := context.WithCancel(context.Background())
+		errs := make(chan error, 1)
+		progress := make(chan float32, 1)
+		parks := make(chan bool, 1)
+		job := &engrave.Job{
+			Design: ctx.calibrated(side),
+			DryRun: s.dryRun.enabled,
+			Skip:   s.resume,
+			Pause:  pause,
+			Progress: func(p float32) {
+				select {
+				case <-progress:
+				default:
+				}
+				progress <- p
+			},
+			Paused: func(paused bool) {
+				select {
+				case <-parks:
+				default:
+				}
+				parks <- paused
+			},
+		}
+		s.resume = 0
+		s.engrave.job = job
+		s.engrave.pause = pause
+		s.engrave.side = newSideID(side)
+		s.engrave.cancel = cancel
+		s.engrave.errs = WakeupChan(ctx, errs)
+		s.engrave.progress = WakeupChan(ctx, progress)
+		s.engrave.parks = WakeupChan(ctx, parks)
+		dev := s.engrave.dev
+		go func() {
+			defer cancel()
+			defer close(errs)
+			defer close(progress)
+			defer close(parks)
+			err := dev.Run(engraveCtx, job)
+			dev.Close()
+			errs <- err
+		}()
+	}
+	return false
+}
+
+// preflightErrorScreen explains why a plate was refused before
+// engraving.
+func preflightErrorScreen(err error) *ErrorScreen {
+	var kerr *backup.KeepOutError
+	if errors.As(err, &kerr) {
+		return &ErrorScreen{
+			Title: "Mounting Hole",
+			Body: fmt.Sprintf("Side %d of the plate comes too close to a mounting %s. "+
+				"Calibrate the engraver and try again.\n\nThe plate was not engraved.", kerr.Side+1, kerr.Zone.Kind),
+		}
+	}
+	var perr *backup.PreflightError
+	if !errors.As(err, &perr) {
+		return NewErrorScreen(err)
+	}
+	if perr.Travel {
+		return &ErrorScreen{
+			Title: "Out of Reach",
+			Body: fmt.Sprintf("Side %d of the plate extends %.1f mm beyond the reach of the engraver. "+
+				"Calibrate the engraver and try again.\n\nThe plate was not engraved.", perr.Side+1, perr.Excess()),
+		}
+	}
+	return &ErrorScreen{
+		Title: "Outside Plate",
+		Body: fmt.Sprintf("Side %d of the plate extends %.1f mm beyond the %s plate margins.\n\nThe plate was not engraved.",
+			perr.Side+1, perr.Excess(), perr.Size),
+	}
+}
+
+// engraveErrorScreen explains an engraving failure and whether the
+// plate can be salvaged.
+func engraveErrorScreen(err error, job *engrave.Job, resumable bool) *ErrorScreen {
+	var plate string
+	switch {
+	case resumable:
+		plate = "Leave the plate in place to resume engraving."
+	case job.DryRun || job.Completed == 0:
+		plate = "The plate was not engraved."
+	default:
+		plate = "The plate is partially engraved and must be replaced."
+	}
+	var terr *engrave.TimeoutError
+	var rerr *engrave.ReplyError
+	var berr *engrave.BoundsError
+	switch {
+	case errors.As(err, &berr):
+		return &ErrorScreen{
+			Title: "Out of Reach",
+			Body:  "The engraving extends beyond the reach of the engraver.\n\n" + plate,
+		}
+	case errors.As(err, &terr):
+		var what string
+		switch terr.Phase {
+		case engrave.PhaseInit, engrave.PhaseReply:
+			what = "The engraver stopped responding while starting up."
+		case engrave.PhaseOrigin:
+			what = "The engraver stopped responding while moving to its origin."
+		default:
+			what = "The engraver stopped responding while engraving."
+		}
+		return &ErrorScreen{
+			Title: "Engraver Not Responding",
+			Body:  what + " Check its power and cable.\n\n" + plate,
+		}
+	case errors.As(err, &rerr):
+		return &ErrorScreen{
+			Title: "Engraver Error",
+			Body:  "The engraver sent an unexpected reply.\n\n" + plate,
+		}
+	default:
+		return &ErrorScreen{
+			Title: "Connection Error",
+			Body:  "Connection to the engraver failed.\n\n" + plate,
+		}
+	}
+}
+
+// togglePause pauses or resumes the running engraving.
+func (s *EngraveScreen) togglePause() {
+	// Replace a request not yet seen by the engraver.
+	select {
+	case <-s.engrave.pause:
+	default:
+	}
+	s.engrave.paused = !s.engrave.paused
+	s.engrave.pause <- s.engrave.paused
+}
+
+// platesFit reports whether the plates, moved by offset, fit in an
+// engraver work area.
+func (s *EngraveScreen) platesFit(area image.Rectangle, offset f32.Vec2) bool {
+	for _, p := range s.plates {
+		b := p.Size.Bounds()
+		if float32(b.Min.X)+offset[0] < float32(area.Min.X) || float32(b.Max.X)+offset[0] > float32(area.Max.X) ||
+			float32(b.Min.Y)+offset[1] < float32(area.Min.Y) || float32(b.Max.Y)+offset[1] > float32(area.Max.Y) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *EngraveScreen) Layout(ctx *Context, ops op.Ctx, dims image.Point) bool {
+loop:
+	for {
+		select {
+		case p := <-s.engrave.progress:
+			s.engrave.lastProgress = p
+		case p := <-s.engrave.parks:
+			s.engrave.parked = p
+		case err := <-s.engrave.errs:
+			e := s.engrave
+			s.engrave = engraveState{}
+			if err != nil {
+				log.Printf("gui: connection lost to engraver: %v", err)
+				resumable := s.interrupted(ctx, e)
+				s.engrave.warning = engraveErrorScreen(err, e.job, resumable)
+				if resumable {
+					// Go back to the connect instruction for resuming.
+					s.resume = e.job.Completed
+					s.step--
+				} else {
+					s.engrave.fatal = true
+					s.engrave.retry = true
+				}
+				break
+			}
+			// The needle height is adjusted for the first side. Remember
+			// that for the session, but leave storing the calibration to
+			// CalibrateScreen, which doesn't run with a seed in memory.
+			ctx.Calibration.Calibrated = true
+			ctx.clearInterrupted(e.side)
+			s.step++
+			if s.step == len(s.instructions) {
+				return true
+			}
+		default:
+			break loop
+		}
+	}
+
+	var progress float32
+	th := &engraveTheme
+	var ins Instruction
+	canPrev := false
+	for {
+		if s.choose != nil {
+			choice, done := s.choose.Layout(ctx, ops.Begin(), th, dims, true)
+			dialog := ops.End()
+			if !done {
+				dialog.Add(ops)
+				return false
+			}
+			s.choose = nil
+			if choice == -1 {
+				return true
+			}
+			s.instruct(ctx, s.choices[choice])
+			continue
+		}
+		if s.resumeChoice != nil {
+			choice, done := s.resumeChoice.Layout(ctx, ops.Begin(), th, dims, true)
+			dialog := ops.End()
+			if !done {
+				dialog.Add(ops)
+				return false
+			}
+			s.resumeChoice = nil
+			switch choice {
+			case -1:
+				return true
+			case 0:
+				s.step = s.interruption.step
+				s.resume = s.interruption.completed
+			default:
+				ctx.clearInterrupted(s.interruption.side)
+			}
+			continue
+		}
+		ins = s.instructions[s.step]
+		canPrev = s.step > 0 && s.instructions[s.step-1].Type == PrepareInstruction
+		progress = s.confirm.Progress(ctx)
+		if progress == 1. {
+			s.moveStep(ctx)
+			s.confirm = ConfirmDelay{}
+			continue
+		}
+		if !s.dryRun.timeout.IsZero() {
+			now := ctx.Platform.Now()
+			d := s.dryRun.timeout.Sub(now)
+			if d <= 0 {
+				ctx.Buttons[input.Button2] = false
+				s.dryRun.timeout = time.Time{}
+				s.dryRun.enabled = !s.dryRun.enabled
+			}
+		}
+		switch {
+		case s.cancel != nil:
+			result := s.cancel.Layout(ctx, ops.Begin(), th, dims)
+			dialog := ops.End()
+			switch result {
+			case ConfirmYes:
+				s.cancelled(ctx)
+				return true
+			case ConfirmNo:
+				s.cancel = nil
+				continue
+			}
+			defer dialog.Add(ops)
+		case s.engrave.warning != nil:
+			dismissed := s.engrave.warning.Layout(ctx, ops.Begin(), th, dims)
+			dialog := ops.End()
+			if dismissed {
+				retry := s.engrave.retry
+				s.engrave.warning = nil
+				if s.engrave.fatal {
+					s.close()
+					if !retry {
+						return true
+					}
+					// Offer to engrave the failed side again, or
+					// any other side.
+					failed := plateSide{Plate: ins.Plate, Side: ins.Side}
+					s.chooseSides("Engrave Again", &failed)
+				}
+				continue
+			}
+			defer dialog.Add(ops)
+		}
+		e, ok := ctx.Next()
+		if !ok {
+			break
+		}
+		switch e.Button {
+		case input.Button1:
+			if !e.Click {
+				break
+			}
+			if canPrev {
+				s.step--
+			} else {
+				body := "This will cancel the engraving process"
+				if s.engrave.caps.Resume && !s.dryRun.enabled {
+					body += "\nLeave the plate in place to resume later."
+				}
+				s.cancel = &ConfirmWarningScreen{
+					Title: "Cancel?",
+					Body:  body + "\n\nHold button to confirm.",
+					Icon:  assets.IconDiscard,
+				}
+			}
+		case input.Button2:
+			if e.Pressed {
+				s.dryRun.timeout = ctx.Platform.Now().Add(confirmDelay)
+				ctx.WakeupAfter(confirmDelay)
+			} else {
+				s.dryRun.timeout = time.Time{}
+			}
+		case input.Button3:
+			if ins.Type == ConnectInstruction {
+				if e.Pressed {
+					ctx.Buttons[input.Button3] = false
+					s.confirm.Start(ctx, confirmDelay)
+				} else {
+					s.confirm = ConfirmDelay{}
+				}
+				break
+			} else if !e.Click {
+				break
+			}
+			if ins.Type == EngraveInstruction {
+				if s.engrave.caps.Pause {
+					s.togglePause()
+				}
+				break
+			}
+			if s.moveStep(ctx) {
+				return true
+			}
+		}
+	}
+
+	op.ColorOp(ops, th.Background)
+	layoutTitle(ctx, ops, dims.X, th.Text, fmt.Sprintf("Engrave Plate"))
+
+	r := layout.Rectangle{Max: dims}
+	_, subt := r.CutTop(leadingSize)
+	subtsz := widget.Label(ops.Begin(), ctx.Styles.body, th.Text, fmt.Sprintf("%.8x", s.Key.MasterFingerprint))
+	op.Position(ops, ops.End(), subt.N(subtsz).Sub(image.Pt(0, 4)))
+
+	const margin = 8
+	_, content := r.CutTop(leadingSize)
+	if ins.Type == EngraveInstruction {
+		progress := fmt.Sprintf("%d%%", int(s.engrave.lastProgress*100))
+		_, content = subt.CutTop(subtsz.Y)
+		middle, _ := content.CutBottom(leadingSize)
+		op.Offset(ops, middle.Center(assets.ProgressCircle.Bounds().Size()))
+		op.MaskOp(ops, ProgressImage{
+			Progress: s.engrave.lastProgress,
+			Src:      assets.ProgressCircle,
+		})
+		op.ColorOp(ops, th.Text)
+		sz := widget.Label(ops.Begin(), ctx.Styles.progress, th.Text, progress)
+		op.Position(ops, ops.End(), middle.Center(sz))
+	}
+	content = content.Shrink(0, margin, 0, margin)
+	content, lead := content.CutBottom(leadingSize)
+	body := ins.resolvedBody
+	switch {
+	case ins.Type == ConnectInstruction && s.resume > 0:
+		body = "Engraving was interrupted. Hold button to resume where it stopped."
+	case ins.Type == EngraveInstruction && s.engrave.paused && s.engrave.parked:
+		body = "Engraving paused.\n\nPress button to resume."
+	case ins.Type == EngraveInstruction && s.engrave.paused:
+		body = "Pausing at the end of the current batch..."
+	}
+	bodysz := widget.LabelW(ops.Begin(), ctx.Styles.lead, content.Dx(), th.Text, body)
+	if img := ins.Image; img != nil {
+		sz := img.Bounds().Size()
+		op.Offset(ops, image.Pt((bodysz.X-sz.X)/2, bodysz.Y))
+		op.ImageOp(ops, img)
+		if sz.X > bodysz.X {
+			bodysz.X = sz.X
+		}
+		bodysz.Y += sz.Y
+	}
+	op.Position(ops, ops.End(), content.Center(bodysz))
+	leadsz := widget.LabelW(ops.Begin(), ctx.Styles.lead, dims.X-2*margin, th.Text, ins.Lead)
+	op.Position(ops, ops.End(), lead.Center(leadsz))
+
+	progressw := dims.X * (s.step + 1) / len(s.instructions)
+	op.ClipOp(image.Rectangle{Max: image.Pt(progressw, 2)}).Add(ops)
+	op.ColorOp(ops, th.Text)
+
+	if s.dryRun.enabled {
+		sz := widget.Label(ops.Begin(), ctx.Styles.debug, th.Text, "dry-run")
+		op.Position(ops, ops.End(), r.SE(sz).Sub(image.Pt(4, 0)))
+	}
+
+	if s.cancel == nil && s.engrave.warning == nil {
+		icnBack := assets.IconBack
+		if canPrev {
+			icnBack = assets.IconLeft
+		}
+		layoutNavigation(ctx, ops, th, dims, NavButton{Button: input.Button1, Style: StyleSecondary, Icon: icnBack})
+		switch ins.Type {
+		case EngraveInstruction:
+			if !s.engrave.caps.Pause {
+				break
+			}
+			icn := assets.IconPause
+			if s.engrave.paused {
+				icn = assets.IconHammer
+			}
+			layoutNavigation(ctx, ops, th, dims, NavButton{Button: input.Button3, Style: StyleSecondary, Icon: icn})
+		case ConnectInstruction:
+			icn := assets.IconHammer
+			if s.confirm.Running() {
+				icn = ProgressImage{
+					Progress: progress,
+					Src:      assets.IconProgress,
+				}
+			}
+			layoutNavigation(ctx, ops, th, dims, NavButton{Button: input.Button3, Style: StylePrimary, Icon: icn})
+		default:
+			layoutNavigation(ctx, ops, th, dims, NavButton{Button: input.Button3, Style: StylePrimary, Icon: assets.IconRight})
+		}
+	}
+	return false
+}
+
+// Calibration is the engraver calibration, stored on the SD card.
+type Calibration struct {
+	// Calibrated is set when the needle height has been adjusted.
+	Calibrated bool
+	// Offset moves every engraving, in millimeters.
+	Offset f32.Vec2
+}
+
+// calibrationFile is the path of the calibration on the SD card.
+const calibrationFile = "seedhammer/calibration.json"
+
+const (
+	// calibrationStep is the offset adjustment per joystick press, in
+	// millimeters.
+	calibrationStep = 0.1
+	// maxCalibrationOffset bounds the offset in every direction, in
+	// millimeters.
+	maxCalibrationOffset = 5
+	// calibrationInset is the distance between the calibration marks
+	// and the plate corners, in millimeters.
+	calibrationInset = 5
+)
+
+// LoadCalibration reads the calibration from the SD card.
+func (c *Context) LoadCalibration() error {
+	data, err := c.Platform.LoadFile(calibrationFile)
+	if err != nil {
+		return err
+	}
+	var cal Calibration
+	if err := json.Unmarshal(data, &cal); err != nil {
+		return fmt.Errorf("%s: %w", calibrationFile, err)
+	}
+	c.Calibration = cal
+	return nil
+}
+
+// StoreCalibration replaces the calibration and writes it to the SD
+// card. The calibration is replaced even if it can't be written.
+func (c *Context) StoreCalibration(cal Calibration) error {
+	c.Calibration = cal
+	data, err := json.Marshal(cal)
+	if err != nil {
+		return err
+	}
+	return c.Platform.StoreFile(calibrationFile, data)
+}
+
+// calibrated moves a design by the calibration offset.
+func (c *Context) calibrated(design engrave.Command) engrave.Command {
+	off := c.Calibration.Offset
+	if off == (f32.Vec2{}) {
+		return design
+	}
+	return engrave.Offset(off[0], off[1], design)
+}
+
+// calibrationMarks punches a mark near every corner of the small plate.
+type calibrationMarks struct{}
+
+func (calibrationMarks) Engrave(p engrave.Program) {
+	b := backup.SmallPlate.Bounds()
+	minx, miny := float32(b.Min.X+calibrationInset), float32(b.Min.Y+calibrationInset)
+	maxx, maxy := float32(b.Max.X-calibrationInset), float32(b.Max.Y-calibrationInset)
+	for _, c := range []f32.Vec2{{minx, miny}, {maxx, miny}, {maxx, maxy}, {minx, maxy}} {
+		p.Move(c)
+		p.Line(c)
+	}
+}
+
+// CalibrateScreen guides the user through adjusting the needle height
+// and measuring the offset between the engraver and the plates.
+type CalibrateScreen struct {
+	instructions []Instruction
+	step         int
+	offset       f32.Vec2
+	confirm      ConfirmDelay
+	engrave      struct {
+		progress     <-chan float32
+		errs         <-chan error
+		lastProgress float32
+	}
+	warning *ErrorScreen
+	// done is set when the screen exits after the warning.
+	done bool
+}
+
+var CalibrateMarks = []Instruction{
+	{
+		Body: "Hold button to engrave calibration marks. The process is loud, use hearing protection.",
+		Type: ConnectInstruction,
+	},
+	{
+		Lead: "Engraving marks",
+		Type: EngraveInstruction,
+	},
+	{
+		Body: "Use the joystick to move the marks {{.Inset}} mm from the plate corners, and engrave them again until they match.",
+		Type: AdjustInstruction,
+	},
+}
+
+func NewCalibrateScreen(ctx *Context) *CalibrateScreen {
+	s := &CalibrateScreen{
+		offset: ctx.Calibration.Offset,
+	}
+	// The needle adjustment of the first engraving, without the share
+	// fingerprint and the engraving.
+	prepare := EngraveFirstSideA[1 : len(EngraveFirstSideA)-2]
+	args := struct {
+		Name  string
+		Inset int
+	}{
+		Name:  plateName(backup.SmallPlate),
+		Inset: calibrationInset,
+	}
+	for _, ins := range append(append([]Instruction{}, prepare...), CalibrateMarks...) {
+		ins.resolvedBody = resolveBody(ins.Body, args)
+		s.instructions = append(s.instructions, ins)
+	}
+	return s
+}
+
+// engraveMarks engraves the calibration marks at the current offset.
+func (s *CalibrateScreen) engraveMarks(ctx *Context) {
+	dev, err := ctx.Platform.Engraver()
+	if err != nil {
+		log.Printf("gui: failed to connect to engraver: %v", err)
+		s.warning = &ErrorScreen{
+			Title: "Connection Error",
+			Body:  "Failed to establish a connection to the engraver.",
+		}
+		return
+	}
+	s.step++
+	errs := make(chan error, 1)
+	progress := make(chan float32, 1)
+	job := &engrave.Job{
+		Design: engrave.Offset(s.offset[0], s.offset[1], calibrationMarks{}),
+		Progress: func(p float32) {
+			select {
+			case <-progress:
+			default:
+			}
+			progress <- p
+		},
+	}
+	s.engrave.lastProgress = 0
+	s.engrave.errs = WakeupChan(ctx, errs)
+	s.engrave.progress = WakeupChan(ctx, progress)
+	go func() {
+		defer close(errs)
+		defer close(progress)
+		err := dev.Run(context.Background(), job)
+		dev.Close()
+		errs <- err
+	}()
+}
+
+// nudge moves the offset by (dx, dy) calibration steps.
+func (s *CalibrateScreen) nudge(dx, dy float32) {
+	clamp := func(v float32) float32 {
+		// Round to whole steps.
+		v = float32(math.Round(float64(v/calibrationStep))) * calibrationStep
+		return float32(math.Max(-maxCalibrationOffset, math.Min(maxCalibrationOffset, float64(v))))
+	}
+	s.offset = f32.Vec2{
+		clamp(s.offset[0] + dx*calibrationStep),
+		clamp(s.offset[1] + dy*calibrationStep),
+	}
+}
+
+func (s *CalibrateScreen) Layout(ctx *Context, ops op.Ctx, dims image.Point) bool {
+loop:
+	for {
+		select {
+		case p, ok := <-s.engrave.progress:
+			if !ok {
+				s.engrave.progress = nil
+				break
+			}
+			s.engrave.lastProgress = p
+		case err := <-s.engrave.errs:
+			s.engrave.errs = nil
+			if err != nil {
+				log.Printf("gui: calibration marks failed: %v", err)
+				s.warning = &ErrorScreen{
+					Title: "Engraving Failed",
+					Body:  "The calibration marks were not engraved. Check the engraver and try again.",
+				}
+				s.step--
+				break
+			}
+			s.step++
+		default:
+			break loop
+		}
+	}
+
+	th := &engraveTheme
+	var ins Instruction
+	var progress float32
+	for {
+		ins = s.instructions[s.step]
+		progress = s.confirm.Progress(ctx)
+		if progress == 1 {
+			s.confirm = ConfirmDelay{}
+			s.engraveMarks(ctx)
+			continue
+		}
+		if s.warning != nil {
+			dismissed := s.warning.Layout(ctx, ops.Begin(), th, dims)
+			dialog := ops.End()
+			if dismissed {
+				s.warning = nil
+				if s.done {
+					return true
+				}
+				continue
+			}
+			defer dialog.Add(ops)
+		}
+		e, ok := ctx.Next()
+		if !ok {
+			break
+		}
+		switch e.Button {
+		case input.Button1:
+			if !e.Click {
+				break
+			}
+			switch ins.Type {
+			case EngraveInstruction:
+			case AdjustInstruction:
+				// Back to engraving the marks.
+				s.step -= 2
+			default:
+				if s.step == 0 {
+					return true
+				}
+				s.step--
+			}
+		case input.Button3:
+			switch ins.Type {
+			case ConnectInstruction:
+				if e.Pressed {
+					ctx.Buttons[input.Button3] = false
+					s.confirm.Start(ctx, confirmDelay)
+				} else {
+					s.confirm = ConfirmDelay{}
+				}
+			case EngraveInstruction:
+			case AdjustInstruction:
+				if !e.Click {
+					break
+				}
+				cal := Calibration{Calibrated: true, Offset: s.offset}
+				if err := ctx.StoreCalibration(cal); err != nil {
+					log.Printf("gui: failed to store calibration: %v", err)
+					s.warning = &ErrorScreen{
+						Title: "Calibration Not Stored",
+						Body:  "The calibration could not be stored on the SD card, and is lost when the device is turned off.",
+					}
+					s.done = true
+					break
+				}
+				return true
+			default:
+				if e.Click {
+					s.step++
+				}
+			}
+		case input.Up, input.Down, input.Left, input.Right:
+			if ins.Type != AdjustInstruction || !e.Pressed {
+				break
+			}
+			switch e.Button {
+			case input.Up:
+				s.nudge(0, -1)
+			case input.Down:
+				s.nudge(0, 1)
+			case input.Left:
+				s.nudge(-1, 0)
+			case input.Right:
+				s.nudge(1, 0)
+			}
+		}
+	}
+
+	op.ColorOp(ops, th.Background)
+	layoutTitle(ctx, ops, dims.X, th.Text, "Calibrate")
+
+	r := layout.Rectangle{Max: dims}
+	const margin = 8
+	_, content := r.CutTop(leadingSize)
+	if ins.Type == EngraveInstruction {
+		middle, _ := content.CutBottom(leadingSize)
+		op.Offset(ops, middle.Center(assets.ProgressCircle.Bounds().Size()))
+		op.MaskOp(ops, ProgressImage{
+			Progress: s.engrave.lastProgress,
+			Src:      assets.ProgressCircle,
+		})
+		op.ColorOp(ops, th.Text)
+		sz := widget.Label(ops.Begin(), ctx.Styles.progress, th.Text, fmt.Sprintf("%d%%", int(s.engrave.lastProgress*100)))
+		op.Position(ops, ops.End(), middle.Center(sz))
+	}
+	content = content.Shrink(0, margin, 0, margin)
+	content, lead := content.CutBottom(leadingSize)
+	body := ins.resolvedBody
+	if ins.Type == AdjustInstruction {
+		body = fmt.Sprintf("%s\n\nX: %+.1f mm Y: %+.1f mm", body, s.offset[0], s.offset[1])
+	}
+	bodysz := widget.LabelW(ops.Begin(), ctx.Styles.lead, content.Dx(), th.Text, body)
+	if img := ins.Image; img != nil {
+		sz := img.Bounds().Size()
+		op.Offset(ops, image.Pt((bodysz.X-sz.X)/2, bodysz.Y))
+		op.ImageOp(ops, img)
+		if sz.X > bodysz.X {
+			bodysz.X = sz.X
+		}
+		bodysz.Y += sz.Y
+	}
+	op.Position(ops, ops.End(), content.Center(bodysz))
+	leadsz := widget.LabelW(ops.Begin(), ctx.Styles.lead, dims.X-2*margin, th.Text, ins.Lead)
+	op.Position(ops, ops.End(), lead.Center(leadsz))
+
+	progressw := dims.X * (s.step + 1) / len(s.instructions)
+	op.ClipOp(image.Rectangle{Max: image.Pt(progressw, 2)}).Add(ops)
+	op.ColorOp(ops, th.Text)
+
+	if s.warning == nil && ins.Type != EngraveInstruction {
+		icnBack := assets.IconLeft
+		if s.step == 0 {
+			icnBack = assets.IconBack
+		}
+		layoutNavigation(ctx, ops, th, dims, NavButton{Button: input.Button1, Style: StyleSecondary, Icon: icnBack})
+		switch ins.Type {
+		case ConnectInstruction:
+			icn := assets.IconHammer
+			if s.confirm.Running() {
+				icn = ProgressImage{
+					Progress: progress,
+					Src:      assets.IconProgress,
+				}
+			}
+			layoutNavigation(ctx, ops, th, dims, NavButton{Button: input.Button3, Style: StylePrimary, Icon: icn})
+		case AdjustInstruction:
+			layoutNavigation(ctx, ops, th, dims, NavButton{Button: input.Button3, Style: StylePrimary, Icon: assets.IconCheckmark})
+		default:
+			layoutNavigation(ctx, ops, th, dims, NavButton{Button: input.Button3, Style: StylePrimary, Icon: assets.IconRight})
+		}
+	}
+	return false
+}
+
+const (
+	// jogStep is the distance the needle moves for every joystick press,
+	// in millimeters.
+	jogStep = 1
+	// testPatternSize is the size of the diagnostics test pattern.
+	testPatternSize = 5
+	// maxDiagnosticResults and maxTraceLines bound the results and
+	// protocol lines shown by the diagnostics screen.
+	maxDiagnosticResults = 2
+	maxTraceLines        = 5
+)
+
+// testPattern is a square with its diagonals, engraved at the center of
+// the small plate.
+type testPattern struct{}
+
+func (testPattern) Engrave(p engrave.Program) {
+	b := backup.SmallPlate.Bounds()
+	c := f32.Vec2{float32(b.Min.X+b.Max.X) / 2, float32(b.Min.Y+b.Max.Y) / 2}
+	const h = testPatternSize / 2.
+	corners := []f32.Vec2{
+		{c[0] - h, c[1] - h}, {c[0] + h, c[1] - h}, {c[0] + h, c[1] + h}, {c[0] - h, c[1] + h},
+	}
+	p.Move(corners[0])
+	for _, c := range corners[1:] {
+		p.Line(c)
+	}
+	p.Line(corners[0])
+	p.Line(corners[2])
+	p.Move(corners[1])
+	p.Line(corners[3])
+}
+
+// DiagnosticsScreen tests the engraver one step at a time, and shows
+// the results along with the protocol exchanged with the engraver.
+type DiagnosticsScreen struct {
+	dev     engrave.Diagnoser
+	confirm ConfirmDelay
+	// busy names the running step, if any.
+	busy    string
+	cancel  context.CancelFunc
+	results <-chan diagnosticResult
+	pos     f32.Vec2
+	hasPos  bool
+	log     []string
+	trace   []string
+	warning *ErrorScreen
+	// saveTrace offers to store failedTrace, the trace of the last
+	// failed engraving.
+	saveTrace   *ChoiceScreen
+	failedTrace []byte
+}
+
+// NewDiagnosticsScreen returns a diagnostics screen that first offers
+// to store the protocol trace of the last failed engraving, if any. The
+// trace is only stored here, because no seed is entered while
+// diagnosing.
+func NewDiagnosticsScreen(ctx *Context) *DiagnosticsScreen {
+	s := new(DiagnosticsScreen)
+	if t := ctx.Platform.FailedTrace(); t != nil {
+		s.failedTrace = t
+		s.saveTrace = &ChoiceScreen{
+			Title:   "Failed Engraving",
+			Lead:    "Store trace on SD card?",
+			Choices: []string{"STORE", "DISCARD"},
+		}
+	}
+	return s
+}
+
+// storeTrace stores the trace of the failed engraving on the SD card.
+func (s *DiagnosticsScreen) storeTrace(ctx *Context) {
+	name := fmt.Sprintf("traces/engraver-%s.trace", ctx.Platform.Now().Format("20060102-150405"))
+	if err := ctx.Platform.StoreFile(name, s.failedTrace); err != nil {
+		log.Printf("gui: failed to store trace: %v", err)
+		s.warning = &ErrorScreen{
+			Title: "Trace Not Stored",
+			Body:  "The trace could not be stored on the SD card.",
+		}
+	}
+}
+
+type diagnosticResult struct {
+	step string
+	pos  f32.Vec2
+	dur  time.Duration
+	err  error
+}
+
+// connect connects to the engraver and homes it.
+func (s *DiagnosticsScreen) connect(ctx *Context) {
+	dev, err := ctx.Platform.Engraver()
+	if err != nil {
+		log.Printf("gui: failed to connect to engraver: %v", err)
+		s.warning = &ErrorScreen{
+			Title: "Connection Error",
+			Body:  "Failed to establish a connection to the engraver.",
+		}
+		return
+	}
+	d, ok := dev.(engrave.Diagnoser)
+	if !ok {
+		dev.Close()
+		s.warning = &ErrorScreen{
+			Title: "Unsupported Engraver",
+			Body:  "The engraver doesn't support diagnostics.",
+		}
+		return
+	}
+	s.dev = d
+	d.Record()
+	s.run(ctx, "Home", func(c context.Context) (f32.Vec2, error) {
+		if err := d.Home(c); err != nil {
+			return f32.Vec2{}, err
+		}
+		return d.Position(c)
+	})
+}
+
+// jog moves the needle by (dx, dy) jog steps.
+func (s *DiagnosticsScreen) jog(ctx *Context, dx, dy float32) {
+	to := f32.Vec2{s.pos[0] + dx*jogStep, s.pos[1] + dy*jogStep}
+	dev := s.dev
+	s.run(ctx, "Jog", func(c context.Context) (f32.Vec2, error) {
+		return dev.Jog(c, to)
+	})
+}
+
+// engraveTestPattern engraves the test pattern on the small plate.
+func (s *DiagnosticsScreen) engraveTestPattern(ctx *Context) {
+	dev := s.dev
+	job := &engrave.Job{Design: ctx.calibrated(testPattern{})}
+	s.run(ctx, "Test pattern", func(c context.Context) (f32.Vec2, error) {
+		if err := dev.Run(c, job); err != nil {
+			return f32.Vec2{}, err
+		}
+		return dev.Position(c)
+	})
+}
+
+// run runs a step in the background, and reports its result and
+// duration to the screen.
+func (s *DiagnosticsScreen) run(ctx *Context, step string, f func(c context.Context) (f32.Vec2, error)) {
+	s.busy = step
+	c, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	results := make(chan diagnosticResult, 1)
+	s.results = WakeupChan(ctx, results)
+	go func() {
+		defer close(results)
+		defer cancel()
+		start := time.Now()
+		pos, err := f(c)
+		results <- diagnosticResult{step: step, pos: pos, dur: time.Since(start), err: err}
+	}()
+}
+
+// close cancels the running step and disconnects the engraver.
+func (s *DiagnosticsScreen) close() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	dev, results := s.dev, s.results
+	go func() {
+		// Wait for the step before closing the device.
+		if results != nil {
+			for range results {
+			}
+		}
+		if dev != nil {
+			dev.Close()
+		}
+	}()
+	*s = DiagnosticsScreen{}
+}
+
+// report records the result of a step.
+func (s *DiagnosticsScreen) report(r diagnosticResult) {
+	line := fmt.Sprintf("%s: %.2fs", r.step, r.dur.Seconds())
+	if r.err != nil {
+		log.Printf("gui: diagnostics: %s: %v", r.step, r.err)
+		line = fmt.Sprintf("%s: %s after %.2fs", r.step, diagnosticError(r.err), r.dur.Seconds())
+	} else {
+		s.pos, s.hasPos = r.pos, true
+	}
+	s.log = append(s.log, line)
+	if n := len(s.log); n > maxDiagnosticResults {
+		s.log = s.log[n-maxDiagnosticResults:]
+	}
+	buf := new(bytes.Buffer)
+	if err := s.dev.Trace(buf); err != nil {
+		log.Printf("gui: diagnostics: %v", err)
+		return
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if n := len(lines); n > maxTraceLines {
+		lines = lines[n-maxTraceLines:]
+	}
+	s.trace = s.trace[:0]
+	for _, l := range lines {
+		s.trace = append(s.trace, strings.TrimSpace(l))
+	}
+}
+
+// diagnosticError summarizes an engraver error.
+func diagnosticError(err error) string {
+	var terr *engrave.TimeoutError
+	var rerr *engrave.ReplyError
+	var berr *engrave.BoundsError
+	switch {
+	case errors.As(err, &terr):
+		return fmt.Sprintf("%s timeout", terr.Phase)
+	case errors.As(err, &rerr):
+		return fmt.Sprintf("unexpected %s reply %#x", rerr.Phase, rerr.Got)
+	case errors.As(err, &berr):
+		return "out of reach"
+	default:
+		return "connection failed"
+	}
+}
+
+func (s *DiagnosticsScreen) Layout(ctx *Context, ops op.Ctx, dims image.Point) bool {
+loop:
+	for {
+		select {
+		case r, ok := <-s.results:
+			if !ok {
+				s.results = nil
+				break
+			}
+			s.busy = ""
+			s.report(r)
+		default:
+			break loop
+		}
+	}
+
+	th := &engraveTheme
+	var progress float32
+	for {
+		progress = s.confirm.Progress(ctx)
+		if progress == 1 {
+			s.confirm = ConfirmDelay{}
+			if s.dev == nil {
+				s.connect(ctx)
+			} else {
+				s.engraveTestPattern(ctx)
+			}
+			continue
+		}
+		if s.saveTrace != nil {
+			choice, done := s.saveTrace.Layout(ctx, ops.Begin(), th, dims, true)
+			dialog := ops.End()
+			if !done {
+				dialog.Add(ops)
+				return false
+			}
+			s.saveTrace = nil
+			if choice == 0 {
+				s.storeTrace(ctx)
+			}
+			s.failedTrace = nil
+			continue
+		}
+		if s.warning != nil {
+			dismissed := s.warning.Layout(ctx, ops.Begin(), th, dims)
+			dialog := ops.End()
+			if dismissed {
+				s.warning = nil
+				continue
+			}
+			defer dialog.Add(ops)
+		}
+		e, ok := ctx.Next()
+		if !ok {
+			break
+		}
+		switch e.Button {
+		case input.Button1:
+			if e.Click {
+				s.close()
+				return true
+			}
+		case input.Button3:
+			if s.busy != "" {
+				break
+			}
+			if e.Pressed {
+				ctx.Buttons[input.Button3] = false
+				s.confirm.Start(ctx, confirmDelay)
+			} else {
+				s.confirm = ConfirmDelay{}
+			}
+		case input.Up, input.Down, input.Left, input.Right:
+			if s.dev == nil || !s.hasPos || s.busy != "" || !e.Pressed {
+				break
+			}
+			switch e.Button {
+			case input.Up:
+				s.jog(ctx, 0, -1)
+			case input.Down:
+				s.jog(ctx, 0, 1)
+			case input.Left:
+				s.jog(ctx, -1, 0)
+			case input.Right:
+				s.jog(ctx, 1, 0)
+			}
+		}
+	}
+
+	op.ColorOp(ops, th.Background)
+	layoutTitle(ctx, ops, dims.X, th.Text, "Diagnostics")
+
+	r := layout.Rectangle{Max: dims}
+	const margin = 8
+	_, content := r.CutTop(leadingSize)
+	// Leave room for the navigation buttons.
+	navw := assets.NavBtnPrimary.Bounds().Dx()
+	content = content.Shrink(0, navw, 0, margin)
+	content, lead := content.CutBottom(leadingSize)
+	var body, leadTxt string
+	switch {
+	case s.dev == nil:
+		body = "Hold button to connect to the engraver and move the needle to its origin."
+	case s.hasPos:
+		body = fmt.Sprintf("X: %.2f mm Y: %.2f mm", s.pos[0], s.pos[1])
+		leadTxt = "Joystick jogs needle"
+	default:
+		body = "Position unknown"
+	}
+	if s.busy != "" {
+		leadTxt = s.busy + "..."
+	}
+	for _, l := range s.log {
+		body += "\n" + l
+	}
+	bodyOps := ops.Begin()
+	bodysz := widget.LabelW(bodyOps, ctx.Styles.body, content.Dx(), th.Text, body)
+	if len(s.trace) > 0 {
+		tracesz := widget.LabelW(bodyOps.Begin(), ctx.Styles.debug, content.Dx(), th.Text, strings.Join(s.trace, "\n"))
+		op.Position(bodyOps, bodyOps.End(), image.Pt(0, bodysz.Y+margin))
+		bodysz.Y += margin + tracesz.Y
+		if tracesz.X > bodysz.X {
+			bodysz.X = tracesz.X
+		}
+	}
+	op.Position(ops, ops.End(), content.Center(bodysz))
+	leadsz := widget.LabelW(ops.Begin(), ctx.Styles.lead, dims.X-2*margin, th.Text, leadTxt)
+	op.Position(ops, ops.End(), lead.Center(leadsz))
+
+	if s.warning == nil {
+		layoutNavigation(ctx, ops, th, dims, NavButton{Button: input.Button1, Style: StyleSecondary, Icon: assets.IconBack})
+		if s.busy == "" {
+			var icn image.Image = assets.IconHammer
+			if s.confirm.Running() {
+				icn = ProgressImage{
+					Progress: progress,
+					Src:      assets.IconProgress,
+				}
+			}
+			layoutNavigation(ctx, ops, th, dims, NavButton{Button: input.Button3, Style: StylePrimary, Icon: icn})
+		}
+	}
+	return false
+}
+
+func plateImage(p backup.PlateSize) image.RGBA64Image {
+	switch p {
+	case backup.SmallPlate:
+		return assets.SH01
+	case backup.SquarePlate:
+		return assets.SH02
+	case backup.LargePlate:
+		return assets.SH03
+	default:
+		panic("unsupported plate")
+	}
+}
+
+func plateName(p backup.PlateSize) string {
+	switch p {
+	case backup.SmallPlate:
+		return "SH01"
+	case backup.SquarePlate:
+		return "SH02"
+	case backup.LargePlate:
+		return "SH03"
+	default:
+		panic("unsupported plate")
+	}
+}
+
+type InstructionType int
+
+const (
+	PrepareInstruction InstructionType = iota
+	ConnectInstruction
+	EngraveInstruction
+	// AdjustInstruction is the joystick adjustment of the calibration
+	// offset.
+	AdjustInstruction
+)
+
+type Instruction struct {
+	Body  string
+	Lead  string
 	Type  InstructionType
+	Plate int
 	Side  int
 	Image image.RGBA64Image
 
-	resolvedBody string
+	resolvedBody string
+}
+
+var (
+	EngraveFirstSideA = []Instruction{
+		{
+			Body: "Make sure the fingerprint above represents the intended share.",
+			Lead: "seedhammer.com/tip#1",
+		},
+		{
+			Body: "Turn off the engraver and disconnect it from this device.",
+			Lead: "seedhammer.com/tip#2",
+		},
+		{
+			Body: "Manually move the hammerhead to the far upper left position.",
+			Lead: "seedhammer.com/tip#3",
+		},
+		{
+			Body:  "Place 2 x {{.Name}}\non top of each other.",
+			Image: assets.SH01,
+			Lead:  "seedhammer.com/tip#4",
+		},
+		{
+			Body: "Tighten the nuts firmly.",
+			Lead: "seedhammer.com/tip#4",
+		},
+		{
+			Body: "Loosen the hammerhead finger screw. Adjust needle distance to 2 mm above the plate.",
+			Lead: "seedhammer.com/tip#5",
+		},
+		{
+			Body: "The needle should barely be able to move freely over the nuts.",
+			Lead: "seedhammer.com/tip#5",
+		},
+		{
+			Body: "Tighten the hammerhead finger screw and make sure the depth selector is set to \"Strong\".",
+			Lead: "seedhammer.com/tip#6",
+		},
+		{
+			Body: "Turn on the engraving machine and connect this device via the middle port.",
+			Lead: "seedhammer.com/tip#7",
+		},
+		{
+			Body: "Hold button to start the engraving process. The process is loud, use hearing protection.",
+			Type: ConnectInstruction,
+			Lead: "seedhammer.com/tip#8",
+		},
+		{
+			Lead: "Engraving plate",
+			Type: EngraveInstruction,
+			Side: 0,
+		},
+	}
+
+	EngraveSideA = []Instruction{
+		{
+			Body: "Engraving seed {{.Idx}} of {{.Total}}.",
+		},
+		{
+			Body: "Unscrew the 4 nuts and remove all metal plates.",
+		},
+		{
+			Body:  "Place 2 x {{.Name}}\non top of each other.",
+			Image: assets.SH01,
+			Lead:  "seedhammer.com/tip#4",
+		},
+		{
+			Body: "Tighten the nuts firmly.",
+			Lead: "seedhammer.com/tip#4",
+		},
+		{
+			Body: "Hold button to start the engraving process. The process is loud, use hearing protection.",
+			Type: ConnectInstruction,
+		},
+		{
+			Lead: "Engraving plate",
+			Type: EngraveInstruction,
+			Side: 0,
+		},
+	}
+
+	EngraveNextPlate = []Instruction{
+		{
+			Body: "Engraving seed {{.Idx}} of {{.Total}}, plate {{.Plate}} of {{.Plates}}.",
+		},
+		{
+			Body: "Unscrew the 4 nuts and remove all metal plates.",
+		},
+		{
+			Body:  "Place 2 x {{.Name}}\non top of each other.",
+			Image: assets.SH01,
+			Lead:  "seedhammer.com/tip#4",
+		},
+		{
+			Body: "Tighten the nuts firmly.",
+			Lead: "seedhammer.com/tip#4",
+		},
+		{
+			Body: "Hold button to start the engraving process. The process is loud, use hearing protection.",
+			Type: ConnectInstruction,
+		},
+		{
+			Lead: "Engraving plate",
+			Type: EngraveInstruction,
+			Side: 0,
+		},
+	}
+
+	EngraveSideB = []Instruction{
+		{
+			Body: "Unscrew the 4 nuts and flip the top metal plate horizontally.",
+		},
+		{
+			Body: "Tighten the nuts firmly.",
+		},
+		{
+			Body: "Hold button to start the engraving process. The process is loud, use hearing protection.",
+			Type: ConnectInstruction,
+		},
+		{
+			Lead: "Engraving plate",
+			Type: EngraveInstruction,
+			Side: 1,
+		},
+	}
+
+	// EngraveOnlySideB engraves side B of a plate whose side A is
+	// already engraved.
+	EngraveOnlySideB = []Instruction{
+		{
+			Body: "Engraving seed {{.Idx}} of {{.Total}}, side B of plate {{.Plate}} of {{.Plates}}.",
+		},
+		{
+			Body: "Unscrew the 4 nuts and place the {{.Name}} engraved on side A on top, flipped horizontally.",
+		},
+		{
+			Body: "Tighten the nuts firmly.",
+			Lead: "seedhammer.com/tip#4",
+		},
+		{
+			Body: "Hold button to start the engraving process. The process is loud, use hearing protection.",
+			Type: ConnectInstruction,
+		},
+		{
+			Lead: "Engraving plate",
+			Type: EngraveInstruction,
+			Side: 1,
+		},
+	}
+
+	EngraveSuccess = []Instruction{
+		{
+			Body: "Completed successfully.\nClick continue to return to the seed.",
+		},
+	}
+)
+
+// seedMethod is a way of entering a seed.
+type seedMethod int
+
+const (
+	methodKeyboard seedMethod = iota
+	methodCamera
+	methodDice
+	methodLastWord
+)
+
+func NewEmptySeedScreen(ctx *Context, title string) *SeedScreen {
+	return newEmptySeedScreen(ctx, title, false)
+}
+
+// NewGenerateSeedScreen is like NewEmptySeedScreen, but also offers
+// generating a new seed from dice rolls or by choosing its final word.
+func NewGenerateSeedScreen(ctx *Context, title string) *SeedScreen {
+	return newEmptySeedScreen(ctx, title, true)
+}
+
+func newEmptySeedScreen(ctx *Context, title string, generate bool) *SeedScreen {
+	s := &SeedScreen{}
+	s.methods = append(s.methods, methodKeyboard)
+	if ctx.EnableSeedScan {
+		s.methods = append(s.methods, methodCamera)
+	}
+	if generate {
+		s.methods = append(s.methods, methodDice, methodLastWord)
+	}
+	if len(s.methods) == 1 {
+		s.seedlen = &ChoiceScreen{
+			Title:   title,
+			Lead:    "Choose number of words",
+			Choices: []string{"12 WORDS", "24 WORDS"},
+		}
+		return s
+	}
+	s.method = &ChoiceScreen{
+		Title: title,
+		Lead:  "Choose input method",
+	}
+	for _, m := range s.methods {
+		s.method.Choices = append(s.method.Choices, []string{"KEYBOARD", "CAMERA", "DICE", "LAST WORD"}[m])
+	}
+	return s
+}
+
+func NewSeedScreen(ctx *Context, m bip39.Mnemonic) *SeedScreen {
+	return &SeedScreen{
+		Mnemonic: m,
+	}
+}
+
+type SeedScreen struct {
+	Mnemonic bip39.Mnemonic
+	selected int
+	scroll   int
+	method   *ChoiceScreen
+	methods  []seedMethod
+	seedlen  *ChoiceScreen
+	dice     *DiceScreen
+	lastWord *LastWordScreen
+	input    *WordKeyboardScreen
+	scanner  *ScanScreen
+	cancel   *ConfirmWarningScreen
+	warning  *ErrorScreen
+}
+
+func (s *SeedScreen) empty() bool {
+	for _, w := range s.Mnemonic {
+		if w != -1 {
+			return false
+		}
+	}
+	return true
+}
+
+func emptyMnemonic(nwords int) bip39.Mnemonic {
+	m := make(bip39.Mnemonic, nwords)
+	for i := range m {
+		m[i] = -1
+	}
+	return m
+}
+
+func (s *SeedScreen) Layout(ctx *Context, ops op.Ctx, th *Colors, dims image.Point) (bip39.Mnemonic, bool) {
+	var complete bool
+	for {
+		complete = len(s.Mnemonic) > 0
+		for _, w := range s.Mnemonic {
+			if w == -1 {
+				complete = false
+				break
+			}
+		}
+		if s.warning != nil {
+			dismiss := s.warning.Layout(ctx, ops.Begin(), th, dims)
+			warning := ops.End()
+			if dismiss {
+				s.warning = nil
+				continue
+			}
+			defer warning.Add(ops)
+		}
+		switch {
+		case s.scanner != nil:
+			res, done := s.scanner.Layout(ctx, ops.Begin(), dims)
+			dialog := ops.End()
+			if !done {
+				dialog.Add(ops)
+				return nil, false
+			}
+			s.scanner = nil
+			if res == nil {
+				continue
+			}
+			if b, ok := res.([]byte); ok {
+				if sqr, ok := seedqr.Parse(b); ok {
+					res = sqr
+				} else if sqr, err := bip39.ParseMnemonic(strings.ToLower(string(b))); err == nil {
+					res = sqr
+				}
+			}
+			seed, ok := res.(bip39.Mnemonic)
+			if !ok {
+				s.warning = &ErrorScreen{
+					Title: "Error",
+					Body:  "The scanned data does not represent a seed.",
+				}
+				continue
+			}
+			s.method = nil
+			s.Mnemonic = seed
+			continue
+		case s.seedlen != nil:
+			choice, done := s.seedlen.Layout(ctx, ops.Begin(), th, dims, true)
+			dialog := ops.End()
+			if !done {
+				dialog.Add(ops)
+				return nil, false
+			}
+			s.seedlen = nil
+			if choice == -1 {
+				continue
+			}
+			s.method = nil
+			nwords := []int{12, 24}[choice]
+			s.Mnemonic = emptyMnemonic(nwords)
+			s.input = &WordKeyboardScreen{
+				Mnemonic: s.Mnemonic,
+			}
+			continue
+		case s.dice != nil:
+			m, done := s.dice.Layout(ctx, ops.Begin(), th, dims)
+			dialog := ops.End()
+			if !done {
+				dialog.Add(ops)
+				return nil, false
+			}
+			s.dice = nil
+			if m == nil {
+				continue
+			}
+			s.method = nil
+			s.Mnemonic = m
+			continue
+		case s.lastWord != nil:
+			m, done := s.lastWord.Layout(ctx, ops.Begin(), th, dims)
+			dialog := ops.End()
+			if !done {
+				dialog.Add(ops)
+				return nil, false
+			}
+			s.lastWord = nil
+			if m == nil {
+				continue
+			}
+			s.method = nil
+			s.Mnemonic = m
+			continue
+		case s.method != nil:
+			choice, done := s.method.Layout(ctx, ops.Begin(), th, dims, s.warning == nil)
+			dialog := ops.End()
+			if !done {
+				dialog.Add(ops)
+				return nil, false
+			}
+			if choice == -1 {
+				return nil, true
+			}
+			switch s.methods[choice] {
+			case methodKeyboard:
+				s.seedlen = &ChoiceScreen{
+					Title:   "Input Seed",
+					Lead:    "Choose number of words",
+					Choices: []string{"12 WORDS", "24 WORDS"},
+				}
+			case methodCamera:
+				s.scanner = &ScanScreen{
+					Title: "Scan",
+					Lead:  "SeedQR or Mnemonic",
+				}
+			case methodDice:
+				s.dice = NewDiceScreen()
+			case methodLastWord:
+				s.lastWord = NewLastWordScreen()
+			}
+			continue
+		case s.input != nil:
+			done := s.input.Layout(ctx, ops.Begin(), th, dims)
+			dialog := ops.End()
+			if !done {
+				dialog.Add(ops)
+				return nil, false
+			}
+			s.input = nil
+			if s.empty() {
+				return nil, true
+			}
+			continue
+		case s.cancel != nil:
+			result := s.cancel.Layout(ctx, ops.Begin(), th, dims)
+			warning := ops.End()
+			switch result {
+			case ConfirmYes:
+				return nil, true
+			case ConfirmNo:
+				s.cancel = nil
+				continue
+			}
+			defer warning.Add(ops)
+		}
+		e, ok := ctx.Next()
+		if !ok {
+			break
+		}
+		switch e.Button {
+		case input.Button1:
+			if !e.Click {
+				break
+			}
+			if s.empty() {
+				return nil, true
+			}
+			s.cancel = &ConfirmWarningScreen{
+				Title: "Discard Seed?",
+				Body:  "Going back will discard the seed.\n\nHold button to confirm.",
+				Icon:  assets.IconDiscard,
+			}
+		case input.Button2, input.Center:
+			if !e.Click {
+				break
+			}
+			s.input = &WordKeyboardScreen{
+				Mnemonic: s.Mnemonic,
+				selected: s.selected,
+			}
+			continue
+		case input.Button3:
+			if !e.Click || !complete {
+				break
+			}
+			if !s.Mnemonic.Valid() {
+				s.warning = &ErrorScreen{
+					Title: "Invalid Seed",
+					Body:  "The seed phrase is invalid.\nCheck the words and try again.",
+				}
+				break
+			}
+			return s.Mnemonic, true
+		case input.Down:
+			if e.Pressed && s.selected < len(s.Mnemonic)-1 {
+				s.selected++
+			}
+		case input.Up:
+			if e.Pressed && s.selected > 0 {
+				s.selected--
+			}
+		}
+	}
+
+	op.ColorOp(ops, th.Background)
+	layoutTitle(ctx, ops, dims.X, th.Text, "Confirm Seed")
+
+	style := ctx.Styles.word
+	_, longestPrefix := style.Layout(math.MaxInt, "24: ")
+	layoutWord := func(ops op.Ctx, col color.NRGBA, n int, word string) image.Point {
+		prefix := widget.Label(ops.Begin(), style, col, fmt.Sprintf("%d: ", n))
+		op.Position(ops, ops.End(), image.Pt(longestPrefix.X-prefix.X, 0))
+		txt := widget.Label(ops.Begin(), style, col, word)
+		op.Position(ops, ops.End(), image.Pt(longestPrefix.X, 0))
+		return image.Pt(longestPrefix.X+txt.X, txt.Y)
+	}
+
+	y := 0
+	longest := layoutWord(op.Ctx{}, color.NRGBA{}, 24, longestWord)
+	r := layout.Rectangle{Max: dims}
+	navw := assets.NavBtnPrimary.Bounds().Dx()
+	list := r.Shrink(leadingSize, 0, 0, 0)
+	content := list.Shrink(scrollFadeDist, navw, scrollFadeDist, navw)
+	lineHeight := longest.Y + 2
+	linesPerPage := content.Dy() / lineHeight
+	scroll := s.selected - linesPerPage/2
+	maxScroll := len(s.Mnemonic) - linesPerPage
+	if scroll > maxScroll {
+		scroll = maxScroll
+	}
+	if scroll < 0 {
+		scroll = 0
+	}
+	off := content.Min.Add(image.Pt(0, -scroll*lineHeight))
+	{
+		ops := ops.Begin()
+		for i, w := range s.Mnemonic {
+			ops.Begin()
+			col := th.Text
+			if i == s.selected {
+				col = th.Background
+				r := image.Rectangle{Max: longest}
+				r.Min.Y -= 3
+				op.MaskOp(ops, assets.ButtonFocused.For(r))
+				op.ColorOp(ops, th.Text)
+			}
+			word := strings.ToUpper(bip39.LabelFor(w))
+			layoutWord(ops, col, i+1, word)
+			pos := image.Pt(0, y).Add(off)
+			op.Position(ops, ops.End(), pos)
+			y += lineHeight
+		}
+	}
+	clipScroll(ops, ops.End(), image.Rectangle(list))
+
+	if s.cancel == nil && s.warning == nil {
+		layoutNavigation(ctx, ops, th, dims,
+			NavButton{Button: input.Button1, Style: StyleSecondary, Icon: assets.IconBack},
+			NavButton{Button: input.Button2, Style: StyleSecondary, Icon: assets.IconEdit},
+		)
+		if complete {
+			layoutNavigation(ctx, ops, th, dims, NavButton{Button: input.Button3, Style: StylePrimary, Icon: assets.IconCheckmark})
+		}
+	}
+	return nil, false
 }
 
-var (
-	EngraveFirstSideA = []Instruction{
-		{
-			Body: "Make sure the fingerprint above represents the intended share.",
-			Lead: "seedhammer.com/tip#1",
-		},
-		{
-			Body: "Turn off the engraver and disconnect it from this device.",
-			Lead: "seedhammer.com/tip#2",
-		},
-		{
-			Body: "Manually move the hammerhead to the far upper left position.",
-			Lead: "seedhammer.com/tip#3",
-		},
-		{
-			Body:  "Place 2 x {{.Name}}\non top of each other.",
-			Image: assets.SH01,
-			Lead:  "seedhammer.com/tip#4",
-		},
-		{
-			Body: "Tighten the nuts firmly.",
-			Lead: "seedhammer.com/tip#4",
-		},
-		{
-			Body: "Loosen the hammerhead finger screw. Adjust needle distance to 2 mm above the plate.",
-			Lead: "seedhammer.com/tip#5",
-		},
-		{
-			Body: "The needle should barely be able to move freely over the nuts.",
-			Lead: "seedhammer.com/tip#5",
-		},
-		{
-			Body: "Tighten the hammerhead finger screw and make sure the depth selector is set to \"Strong\".",
-			Lead: "seedhammer.com/tip#6",
-		},
-		{
-			Body: "Turn on the engraving machine and connect this device via the middle port.",
-			Lead: "seedhammer.com/tip#7",
-		},
-		{
-			Body: "Hold button to start the engraving process. The process is loud, use hearing protection.",
-			Type: ConnectInstruction,
-			Lead: "seedhammer.com/tip#8",
-		},
-		{
-			Lead: "Engraving plate",
-			Type: EngraveInstruction,
-			Side: 0,
-		},
+const scrollFadeDist = 16
+
+func clipScroll(ops op.Ctx, w op.CallOp, r image.Rectangle) {
+	op.MaskOp(ops, scrollMask(r))
+	op.Position(ops, w, image.Pt(0, 0))
+}
+
+type scrollMask image.Rectangle
+
+func (n scrollMask) At(x, y int) color.Color {
+	return n.RGBA64At(x, y)
+}
+
+func (n scrollMask) RGBA64At(x, y int) color.RGBA64 {
+	alpha := 0xffff
+	b := n.Bounds()
+	if d := y - b.Min.Y; d < scrollFadeDist {
+		alpha = 0xffff * d / scrollFadeDist
+	} else if d := b.Max.Y - y; d < scrollFadeDist {
+		alpha = 0xffff * d / scrollFadeDist
 	}
+	a16 := uint16(alpha)
+	return color.RGBA64{A: a16}
+}
 
-	EngraveSideA = []Instruction{
-		{
-			Body: "Engraving seed {{.Idx}} of {{.Total}}.",
-		},
-		{
-			Body: "Unscrew the 4 nuts and remove all metal plates.",
-		},
-		{
-			Body:  "Place 2 x {{.Name}}\non top of each other.",
-			Image: assets.SH01,
-			Lead:  "seedhammer.com/tip#4",
-		},
-		{
-			Body: "Tighten the nuts firmly.",
-			Lead: "seedhammer.com/tip#4",
-		},
-		{
-			Body: "Hold button to start the engraving process. The process is loud, use hearing protection.",
-			Type: ConnectInstruction,
-		},
-		{
-			Lead: "Engraving plate",
-			Type: EngraveInstruction,
-			Side: 0,
-		},
+func (n scrollMask) Bounds() image.Rectangle {
+	return image.Rectangle(n)
+}
+
+func (_ scrollMask) ColorModel() color.Model {
+	return color.AlphaModel
+}
+
+func layoutWarning(ctx *Context, ops op.Ctx, th *Colors, width int, title, txt string) image.Point {
+	op.ColorOp(ops, color.NRGBA{A: theme.overlayMask})
+
+	const margin = 4
+	wbbg := assets.WarningBoxBg
+	wbout := assets.WarningBoxBorder
+	ptop, pend, _, pstart := wbbg.Padding()
+	width -= 2*(assets.NavBtnPrimary.Bounds().Dx()+margin) + pstart + pend
+	titlesz := widget.LabelW(ops.Begin(), ctx.Styles.warning, width, th.Text, strings.ToUpper(title)+"\n")
+	titlew := ops.End()
+	bodysz := widget.LabelW(ops.Begin(), ctx.Styles.body, width, th.Text, txt)
+	body := ops.End()
+	maxw := bodysz.X
+	if titlesz.X > maxw {
+		maxw = titlesz.X
 	}
+	r := image.Rectangle{Max: image.Pt(maxw, titlesz.Y+bodysz.Y)}
+	box := wbbg.For(r)
+	op.MaskOp(ops.Begin(), wbbg.For(r))
+	op.ColorOp(ops, th.Background)
+	op.MaskOp(ops, wbout.For(r))
+	op.ColorOp(ops, th.Text)
+	off := image.Pt(pstart, ptop)
+	op.Position(ops, ops.End(), off)
+	op.Position(ops, titlew, off.Add(image.Pt((maxw-titlesz.X)/2, 0)))
+	op.Position(ops, body, off.Add(image.Pt(0, titlesz.Y)))
+	return box.Bounds().Size()
+}
 
-	EngraveSideB = []Instruction{
-		{
-			Body: "Unscrew the 4 nuts and flip the top metal plate horizontally.",
-		},
-		{
-			Body: "Tighten the nuts firmly.",
-		},
-		{
-			Body: "Hold button to start the engraving process. The process is loud, use hearing protection.",
-			Type: ConnectInstruction,
-		},
-		{
-			Lead: "Engraving plate",
-			Type: EngraveInstruction,
-			Side: 1,
-		},
+type WordKeyboardScreen struct {
+	Mnemonic bip39.Mnemonic
+	selected int
+	kbd      *Keyboard
+}
+
+func (s *WordKeyboardScreen) Layout(ctx *Context, ops op.Ctx, th *Colors, dims image.Point) bool {
+	if s.kbd == nil {
+		s.kbd = NewKeyboard(ctx)
+	}
+	for {
+		e, ok := ctx.Next()
+		if !ok {
+			break
+		}
+		switch e.Button {
+		case input.Button1:
+			if e.Click {
+				return true
+			}
+		case input.Button2:
+			if !e.Click {
+				break
+			}
+			w, complete := s.kbd.Complete()
+			if !complete {
+				break
+			}
+			s.kbd.Clear()
+			s.Mnemonic[s.selected] = w
+			for {
+				s.selected++
+				if s.selected == len(s.Mnemonic) {
+					return true
+				}
+				if s.Mnemonic[s.selected] == -1 {
+					break
+				}
+			}
+		default:
+			s.kbd.Event(e)
+		}
 	}
+	completedWord, complete := s.kbd.Complete()
+	op.ColorOp(ops, th.Background)
+	layoutTitle(ctx, ops, dims.X, th.Text, "Input Words")
+
+	screen := layout.Rectangle{Max: dims}
+	_, content := screen.CutTop(leadingSize)
+	content, _ = content.CutBottom(8)
+
+	kbdsz := s.kbd.Layout(ctx, ops.Begin(), th)
+	op.Position(ops, ops.End(), content.S(kbdsz))
 
-	EngraveSuccess = []Instruction{
-		{
-			Body: "Completed successfully.\nClick continue to return to the seed.",
-		},
+	layoutWord := func(ops op.Ctx, n int, word string) image.Point {
+		style := ctx.Styles.word
+		txt := fmt.Sprintf("%2d: %s", n, word)
+		return widget.Label(ops, style, th.Background, txt)
 	}
-)
 
-func NewEmptySeedScreen(ctx *Context, title string) *SeedScreen {
-	s := &SeedScreen{}
-	if ctx.EnableSeedScan {
-		s.method = &ChoiceScreen{
-			Title:   title,
-			Lead:    "Choose input method",
-			Choices: []string{"KEYBOARD", "CAMERA"},
-		}
-	} else {
-		s.seedlen = &ChoiceScreen{
-			Title:   title,
-			Lead:    "Choose number of words",
-			Choices: []string{"12 WORDS", "24 WORDS"},
-		}
+	longest := layoutWord(op.Ctx{}, 24, longestWord)
+	hint := s.kbd.Word
+	if complete {
+		hint = strings.ToUpper(bip39.LabelFor(completedWord))
 	}
-	return s
-}
+	layoutWord(ops.Begin(), s.selected+1, hint)
+	word := ops.End()
+	r := image.Rectangle{Max: longest}
+	r.Min.Y -= 3
+	op.MaskOp(ops.Begin(), assets.ButtonFocused.For(r))
+	op.ColorOp(ops, th.Text)
+	word.Add(ops)
+	top, _ := content.CutBottom(kbdsz.Y)
+	op.Position(ops, ops.End(), top.Center(longest))
 
-func NewSeedScreen(ctx *Context, m bip39.Mnemonic) *SeedScreen {
-	return &SeedScreen{
-		Mnemonic: m,
+	layoutNavigation(ctx, ops, th, dims,
+		NavButton{Button: input.Button1, Style: StyleSecondary, Icon: assets.IconBack},
+	)
+	if complete {
+		layoutNavigation(ctx, ops, th, dims, NavButton{Button: input.Button2, Style: StylePrimary, Icon: assets.IconCheckmark})
 	}
+	return false
 }
 
-type SeedScreen struct {
+// DiceScreen generates a seed from five dice rolls per word. The
+// final word is adjusted to carry the checksum, after the user
+// confirms the replaced word.
+type DiceScreen struct {
 	Mnemonic bip39.Mnemonic
-	selected int
-	scroll   int
-	method   *ChoiceScreen
 	seedlen  *ChoiceScreen
-	input    *WordKeyboardScreen
-	scanner  *ScanScreen
-	cancel   *ConfirmWarningScreen
 	warning  *ErrorScreen
+	checksum *ConfirmWarningScreen
+	word     int
+	roll     bip39.Roll
+	die      int
 }
 
-func (s *SeedScreen) empty() bool {
-	for _, w := range s.Mnemonic {
-		if w != -1 {
-			return false
-		}
+func NewDiceScreen() *DiceScreen {
+	return &DiceScreen{
+		seedlen: &ChoiceScreen{
+			Title:   "Roll Dice",
+			Lead:    "Choose number of words",
+			Choices: []string{"12 WORDS", "24 WORDS"},
+		},
 	}
-	return true
 }
 
-func emptyMnemonic(nwords int) bip39.Mnemonic {
-	m := make(bip39.Mnemonic, nwords)
-	for i := range m {
-		m[i] = -1
+// wipe forgets the rolled words.
+func (s *DiceScreen) wipe() {
+	for i := range s.Mnemonic {
+		s.Mnemonic[i] = -1
 	}
+	s.roll = bip39.Roll{}
+}
+
+// complete returns the rolled words with the checksum word.
+func (s *DiceScreen) complete() bip39.Mnemonic {
+	m := s.Mnemonic.FixChecksum()
+	s.wipe()
 	return m
 }
 
-func (s *SeedScreen) Layout(ctx *Context, ops op.Ctx, th *Colors, dims image.Point) (bip39.Mnemonic, bool) {
-	var complete bool
+// rolled reports whether every die of the current word is entered.
+func (s *DiceScreen) rolled() bool {
+	for _, d := range s.roll {
+		if d == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Layout returns the generated mnemonic, or nil if the user
+// cancelled.
+func (s *DiceScreen) Layout(ctx *Context, ops op.Ctx, th *Colors, dims image.Point) (bip39.Mnemonic, bool) {
 	for {
-		complete = len(s.Mnemonic) > 0
-		for _, w := range s.Mnemonic {
-			if w == -1 {
-				complete = false
-				break
+		switch {
+		case s.seedlen != nil:
+			choice, done := s.seedlen.Layout(ctx, ops.Begin(), th, dims, true)
+			dialog := ops.End()
+			if !done {
+				dialog.Add(ops)
+				return nil, false
 			}
-		}
-		if s.warning != nil {
-			dismiss := s.warning.Layout(ctx, ops.Begin(), th, dims)
+			s.seedlen = nil
+			if choice == -1 {
+				return nil, true
+			}
+			s.Mnemonic = emptyMnemonic([]int{12, 24}[choice])
+			continue
+		case s.checksum != nil:
+			result := s.checksum.Layout(ctx, ops.Begin(), th, dims)
 			warning := ops.End()
-			if dismiss {
+			switch result {
+			case ConfirmYes:
+				s.checksum = nil
+				return s.complete(), true
+			case ConfirmNo:
+				// Roll the last word again.
+				s.checksum = nil
+				s.word--
+				s.Mnemonic[s.word] = -1
+				continue
+			}
+			op.ColorOp(ops, th.Background)
+			warning.Add(ops)
+			return nil, false
+		case s.warning != nil:
+			dismissed := s.warning.Layout(ctx, ops.Begin(), th, dims)
+			warning := ops.End()
+			if dismissed {
 				s.warning = nil
 				continue
 			}
 			defer warning.Add(ops)
 		}
-		switch {
-		case s.scanner != nil:
-			res, done := s.scanner.Layout(ctx, ops.Begin(), dims)
-			dialog := ops.End()
-			if !done {
-				dialog.Add(ops)
-				return nil, false
+		e, ok := ctx.Next()
+		if !ok {
+			break
+		}
+		switch e.Button {
+		case input.Button1:
+			if !e.Click {
+				break
 			}
-			s.scanner = nil
-			if res == nil {
-				continue
+			if s.roll != (bip39.Roll{}) {
+				s.roll = bip39.Roll{}
+				s.die = 0
+				break
 			}
-			if b, ok := res.([]byte); ok {
-				if sqr, ok := seedqr.Parse(b); ok {
-					res = sqr
-				} else if sqr, err := bip39.ParseMnemonic(strings.ToLower(string(b))); err == nil {
-					res = sqr
-				}
+			if s.word == 0 {
+				s.wipe()
+				return nil, true
 			}
-			seed, ok := res.(bip39.Mnemonic)
+			s.word--
+			s.Mnemonic[s.word] = -1
+		case input.Up:
+			if e.Pressed {
+				s.roll[s.die] = s.roll[s.die]%6 + 1
+			}
+		case input.Down:
+			if e.Pressed {
+				s.roll[s.die] = (s.roll[s.die]+4)%6 + 1
+			}
+		case input.Left:
+			if e.Pressed && s.die > 0 {
+				s.die--
+			}
+		case input.Right:
+			if e.Pressed && s.die < len(s.roll)-1 {
+				s.die++
+			}
+		case input.Button2, input.Center:
+			if e.Click && s.die < len(s.roll)-1 {
+				s.die++
+			}
+		case input.Button3:
+			if !e.Click || !s.rolled() {
+				break
+			}
+			w, ok := bip39.DiceToWord(s.roll)
+			s.roll = bip39.Roll{}
+			s.die = 0
 			if !ok {
 				s.warning = &ErrorScreen{
-					Title: "Error",
-					Body:  "The scanned data does not represent a seed.",
+					Title: "Roll Again",
+					Body:  "The roll doesn't match a word.\nRoll all five dice again.",
 				}
-				continue
+				break
 			}
-			s.method = nil
-			s.Mnemonic = seed
-			continue
+			s.Mnemonic[s.word] = w
+			s.word++
+			if s.word < len(s.Mnemonic) {
+				break
+			}
+			m := s.Mnemonic.FixChecksum()
+			last := m[len(m)-1]
+			if last == w {
+				s.wipe()
+				return m, true
+			}
+			for i := range m {
+				m[i] = -1
+			}
+			s.checksum = &ConfirmWarningScreen{
+				Title: "Checksum Word",
+				Body: fmt.Sprintf("The rolled word %d, %s, doesn't carry the checksum. Write down %s instead.\n\nHold button to confirm.",
+					s.word, strings.ToUpper(bip39.LabelFor(w)), strings.ToUpper(bip39.LabelFor(last))),
+				Icon: assets.IconCheckmark,
+			}
+		}
+	}
+
+	op.ColorOp(ops, th.Background)
+	layoutTitle(ctx, ops, dims.X, th.Text, "Roll Dice")
+
+	r := layout.Rectangle{Max: dims}
+	content, lead := r.Shrink(leadingSize, 0, 0, 0).CutBottom(leadingSize)
+	leadTxt := fmt.Sprintf("Word %d of %d", s.word+1, len(s.Mnemonic))
+	sz := widget.LabelW(ops.Begin(), ctx.Styles.lead, dims.X-2*8, th.Text, leadTxt)
+	op.Position(ops, ops.End(), lead.Center(sz))
+
+	// Lay out the dice above the word they roll.
+	style := ctx.Styles.word
+	_, digit := style.Layout(math.MaxInt, "6")
+	const padding = 8
+	key := image.Rectangle{Max: digit.Add(image.Pt(2*padding, padding))}
+	dice := ops.Begin()
+	dicesz := image.Point{}
+	for i, d := range s.roll {
+		bg := assets.Key
+		col := th.Text
+		if i == s.die {
+			bg = assets.KeyActive
+			col = th.Background
+		}
+		bgimg := bg.For(key)
+		bgsz := bgimg.Bounds().Size()
+		label := "?"
+		if d != 0 {
+			label = fmt.Sprint(d)
+		}
+		die := dice.Begin()
+		op.MaskOp(die, bgimg)
+		op.ColorOp(die, th.Text)
+		sz := widget.Label(die.Begin(), style, col, label)
+		op.Position(die, die.End(), bgimg.Bounds().Min.Add(bgsz.Sub(sz).Div(2)))
+		op.Position(dice, dice.End(), image.Pt(dicesz.X, 0).Sub(bgimg.Bounds().Min))
+		dicesz = image.Pt(dicesz.X+bgsz.X+padding/2, bgsz.Y)
+	}
+	dicesz.X -= padding / 2
+	diceOp := ops.End()
+
+	hint := ""
+	if s.rolled() {
+		hint = "NO WORD"
+		if w, ok := bip39.DiceToWord(s.roll); ok {
+			hint = strings.ToUpper(bip39.LabelFor(w))
+		}
+	}
+	_, longest := style.Layout(math.MaxInt, fmt.Sprintf("24: %s", longestWord))
+	bg := image.Rectangle{Max: longest}
+	bg.Min.Y -= 3
+	op.MaskOp(ops.Begin(), assets.ButtonFocused.For(bg))
+	op.ColorOp(ops, th.Text)
+	widget.Label(ops, style, th.Background, fmt.Sprintf("%2d: %s", s.word+1, hint))
+	wordOp := ops.End()
+
+	boxw := dicesz.X
+	if longest.X > boxw {
+		boxw = longest.X
+	}
+	wordy := dicesz.Y + 2*padding
+	box := content.Center(image.Pt(boxw, wordy+longest.Y))
+	op.Position(ops, diceOp, box.Add(image.Pt((boxw-dicesz.X)/2, 0)))
+	op.Position(ops, wordOp, box.Add(image.Pt((boxw-longest.X)/2, wordy)))
+
+	if s.warning == nil {
+		layoutNavigation(ctx, ops, th, dims,
+			NavButton{Button: input.Button1, Style: StyleSecondary, Icon: assets.IconBack},
+		)
+		if s.rolled() {
+			layoutNavigation(ctx, ops, th, dims, NavButton{Button: input.Button3, Style: StylePrimary, Icon: assets.IconCheckmark})
+		}
+	}
+	return nil, false
+}
+
+// LastWordScreen computes the final word of a seed from the other
+// words, for seeds generated by hand.
+type LastWordScreen struct {
+	Mnemonic bip39.Mnemonic
+	seedlen  *ChoiceScreen
+	input    *WordKeyboardScreen
+	cancel   *ConfirmWarningScreen
+	words    []bip39.Word
+	selected int
+}
+
+func NewLastWordScreen() *LastWordScreen {
+	return &LastWordScreen{
+		seedlen: &ChoiceScreen{
+			Title:   "Last Word",
+			Lead:    "Choose number of words",
+			Choices: []string{"12 WORDS", "24 WORDS"},
+		},
+	}
+}
+
+// missing returns the index of the first word not yet entered, or -1.
+func (s *LastWordScreen) missing() int {
+	for i, w := range s.Mnemonic {
+		if w == -1 {
+			return i
+		}
+	}
+	return -1
+}
+
+// Layout returns the completed mnemonic, or nil if the user
+// cancelled.
+func (s *LastWordScreen) Layout(ctx *Context, ops op.Ctx, th *Colors, dims image.Point) (bip39.Mnemonic, bool) {
+	for {
+		switch {
 		case s.seedlen != nil:
 			choice, done := s.seedlen.Layout(ctx, ops.Begin(), th, dims, true)
 			dialog := ops.End()
@@ -1534,38 +4419,26 @@ func (s *SeedScreen) Layout(ctx *Context, ops op.Ctx, th *Colors, dims image.Poi
 			}
 			s.seedlen = nil
 			if choice == -1 {
-				continue
+				return nil, true
 			}
-			s.method = nil
-			nwords := []int{12, 24}[choice]
-			s.Mnemonic = emptyMnemonic(nwords)
+			s.Mnemonic = emptyMnemonic([]int{12, 24}[choice] - 1)
 			s.input = &WordKeyboardScreen{
 				Mnemonic: s.Mnemonic,
 			}
 			continue
-		case s.method != nil:
-			choice, done := s.method.Layout(ctx, ops.Begin(), th, dims, s.warning == nil)
-			dialog := ops.End()
-			if !done {
-				dialog.Add(ops)
-				return nil, false
-			}
-			switch choice {
-			case -1:
+		case s.cancel != nil:
+			result := s.cancel.Layout(ctx, ops.Begin(), th, dims)
+			warning := ops.End()
+			switch result {
+			case ConfirmYes:
 				return nil, true
-			case 0:
-				s.seedlen = &ChoiceScreen{
-					Title:   "Input Seed",
-					Lead:    "Choose number of words",
-					Choices: []string{"12 WORDS", "24 WORDS"},
-				}
-			case 1:
-				s.scanner = &ScanScreen{
-					Title: "Scan",
-					Lead:  "SeedQR or Mnemonic",
-				}
+			case ConfirmNo:
+				s.cancel = nil
+				continue
 			}
-			continue
+			s.input.Layout(ctx, ops, th, dims)
+			warning.Add(ops)
+			return nil, false
 		case s.input != nil:
 			done := s.input.Layout(ctx, ops.Begin(), th, dims)
 			dialog := ops.End()
@@ -1573,22 +4446,21 @@ func (s *SeedScreen) Layout(ctx *Context, ops op.Ctx, th *Colors, dims image.Poi
 				dialog.Add(ops)
 				return nil, false
 			}
-			s.input = nil
-			if s.empty() {
-				return nil, true
-			}
-			continue
-		case s.cancel != nil:
-			result := s.cancel.Layout(ctx, ops.Begin(), th, dims)
-			warning := ops.End()
-			switch result {
-			case ConfirmYes:
-				return nil, true
-			case ConfirmNo:
-				s.cancel = nil
+			if missing := s.missing(); missing != -1 {
+				if missing == 0 {
+					return nil, true
+				}
+				s.cancel = &ConfirmWarningScreen{
+					Title: "Discard Words?",
+					Body:  "Going back will discard the words.\n\nHold button to confirm.",
+					Icon:  assets.IconDiscard,
+				}
 				continue
 			}
-			defer warning.Add(ops)
+			s.input = nil
+			s.words = bip39.FinalWords(s.Mnemonic)
+			s.selected = 0
+			continue
 		}
 		e, ok := ctx.Next()
 		if !ok {
@@ -1599,37 +4471,18 @@ func (s *SeedScreen) Layout(ctx *Context, ops op.Ctx, th *Colors, dims image.Poi
 			if !e.Click {
 				break
 			}
-			if s.empty() {
-				return nil, true
-			}
-			s.cancel = &ConfirmWarningScreen{
-				Title: "Discard Seed?",
-				Body:  "Going back will discard the seed.\n\nHold button to confirm.",
-				Icon:  assets.IconDiscard,
-			}
-		case input.Button2, input.Center:
-			if !e.Click {
-				break
-			}
+			// Edit the words.
 			s.input = &WordKeyboardScreen{
 				Mnemonic: s.Mnemonic,
-				selected: s.selected,
-			}
-			continue
-		case input.Button3:
-			if !e.Click || !complete {
-				break
+				selected: len(s.Mnemonic) - 1,
 			}
-			if !s.Mnemonic.Valid() {
-				s.warning = &ErrorScreen{
-					Title: "Invalid Seed",
-					Body:  "The seed phrase is invalid.\nCheck the words and try again.",
-				}
+		case input.Button3, input.Center:
+			if !e.Click {
 				break
 			}
-			return s.Mnemonic, true
+			return append(s.Mnemonic, s.words[s.selected]), true
 		case input.Down:
-			if e.Pressed && s.selected < len(s.Mnemonic)-1 {
+			if e.Pressed && s.selected < len(s.words)-1 {
 				s.selected++
 			}
 		case input.Up:
@@ -1640,38 +4493,31 @@ func (s *SeedScreen) Layout(ctx *Context, ops op.Ctx, th *Colors, dims image.Poi
 	}
 
 	op.ColorOp(ops, th.Background)
-	layoutTitle(ctx, ops, dims.X, th.Text, "Confirm Seed")
-
-	style := ctx.Styles.word
-	_, longestPrefix := style.Layout(math.MaxInt, "24: ")
-	layoutWord := func(ops op.Ctx, col color.NRGBA, n int, word string) image.Point {
-		prefix := widget.Label(ops.Begin(), style, col, fmt.Sprintf("%d: ", n))
-		op.Position(ops, ops.End(), image.Pt(longestPrefix.X-prefix.X, 0))
-		txt := widget.Label(ops.Begin(), style, col, word)
-		op.Position(ops, ops.End(), image.Pt(longestPrefix.X, 0))
-		return image.Pt(longestPrefix.X+txt.X, txt.Y)
-	}
+	layoutTitle(ctx, ops, dims.X, th.Text, "Last Word")
 
-	y := 0
-	longest := layoutWord(op.Ctx{}, color.NRGBA{}, 24, longestWord)
 	r := layout.Rectangle{Max: dims}
+	list, lead := r.Shrink(leadingSize, 0, 0, 0).CutBottom(leadingSize)
+	leadTxt := fmt.Sprintf("Choose one of %d words", len(s.words))
+	sz := widget.LabelW(ops.Begin(), ctx.Styles.lead, dims.X-2*8, th.Text, leadTxt)
+	op.Position(ops, ops.End(), lead.Center(sz))
+
+	style := ctx.Styles.word
+	_, longest := style.Layout(math.MaxInt, fmt.Sprintf("%d: %s", len(s.Mnemonic)+1, longestWord))
 	navw := assets.NavBtnPrimary.Bounds().Dx()
-	list := r.Shrink(leadingSize, 0, 0, 0)
 	content := list.Shrink(scrollFadeDist, navw, scrollFadeDist, navw)
 	lineHeight := longest.Y + 2
 	linesPerPage := content.Dy() / lineHeight
 	scroll := s.selected - linesPerPage/2
-	maxScroll := len(s.Mnemonic) - linesPerPage
-	if scroll > maxScroll {
+	if maxScroll := len(s.words) - linesPerPage; scroll > maxScroll {
 		scroll = maxScroll
 	}
 	if scroll < 0 {
 		scroll = 0
 	}
-	off := content.Min.Add(image.Pt(0, -scroll*lineHeight))
+	off := content.Min.Add(image.Pt((content.Dx()-longest.X)/2, -scroll*lineHeight))
 	{
 		ops := ops.Begin()
-		for i, w := range s.Mnemonic {
+		for i, w := range s.words {
 			ops.Begin()
 			col := th.Text
 			if i == s.selected {
@@ -1681,171 +4527,18 @@ func (s *SeedScreen) Layout(ctx *Context, ops op.Ctx, th *Colors, dims image.Poi
 				op.MaskOp(ops, assets.ButtonFocused.For(r))
 				op.ColorOp(ops, th.Text)
 			}
-			word := strings.ToUpper(bip39.LabelFor(w))
-			layoutWord(ops, col, i+1, word)
-			pos := image.Pt(0, y).Add(off)
-			op.Position(ops, ops.End(), pos)
-			y += lineHeight
+			txt := fmt.Sprintf("%d: %s", len(s.Mnemonic)+1, strings.ToUpper(bip39.LabelFor(w)))
+			widget.Label(ops, style, col, txt)
+			op.Position(ops, ops.End(), off.Add(image.Pt(0, i*lineHeight)))
 		}
 	}
 	clipScroll(ops, ops.End(), image.Rectangle(list))
 
-	if s.cancel == nil && s.warning == nil {
-		layoutNavigation(ctx, ops, th, dims,
-			NavButton{Button: input.Button1, Style: StyleSecondary, Icon: assets.IconBack},
-			NavButton{Button: input.Button2, Style: StyleSecondary, Icon: assets.IconEdit},
-		)
-		if complete {
-			layoutNavigation(ctx, ops, th, dims, NavButton{Button: input.Button3, Style: StylePrimary, Icon: assets.IconCheckmark})
-		}
-	}
-	return nil, false
-}
-
-const scrollFadeDist = 16
-
-func clipScroll(ops op.Ctx, w op.CallOp, r image.Rectangle) {
-	op.MaskOp(ops, scrollMask(r))
-	op.Position(ops, w, image.Pt(0, 0))
-}
-
-type scrollMask image.Rectangle
-
-func (n scrollMask) At(x, y int) color.Color {
-	return n.RGBA64At(x, y)
-}
-
-func (n scrollMask) RGBA64At(x, y int) color.RGBA64 {
-	alpha := 0xffff
-	b := n.Bounds()
-	if d := y - b.Min.Y; d < scrollFadeDist {
-		alpha = 0xffff * d / scrollFadeDist
-	} else if d := b.Max.Y - y; d < scrollFadeDist {
-		alpha = 0xffff * d / scrollFadeDist
-	}
-	a16 := uint16(alpha)
-	return color.RGBA64{A: a16}
-}
-
-func (n scrollMask) Bounds() image.Rectangle {
-	return image.Rectangle(n)
-}
-
-func (_ scrollMask) ColorModel() color.Model {
-	return color.AlphaModel
-}
-
-func layoutWarning(ctx *Context, ops op.Ctx, th *Colors, width int, title, txt string) image.Point {
-	op.ColorOp(ops, color.NRGBA{A: theme.overlayMask})
-
-	const margin = 4
-	wbbg := assets.WarningBoxBg
-	wbout := assets.WarningBoxBorder
-	ptop, pend, _, pstart := wbbg.Padding()
-	width -= 2*(assets.NavBtnPrimary.Bounds().Dx()+margin) + pstart + pend
-	titlesz := widget.LabelW(ops.Begin(), ctx.Styles.warning, width, th.Text, strings.ToUpper(title)+"\n")
-	titlew := ops.End()
-	bodysz := widget.LabelW(ops.Begin(), ctx.Styles.body, width, th.Text, txt)
-	body := ops.End()
-	maxw := bodysz.X
-	if titlesz.X > maxw {
-		maxw = titlesz.X
-	}
-	r := image.Rectangle{Max: image.Pt(maxw, titlesz.Y+bodysz.Y)}
-	box := wbbg.For(r)
-	op.MaskOp(ops.Begin(), wbbg.For(r))
-	op.ColorOp(ops, th.Background)
-	op.MaskOp(ops, wbout.For(r))
-	op.ColorOp(ops, th.Text)
-	off := image.Pt(pstart, ptop)
-	op.Position(ops, ops.End(), off)
-	op.Position(ops, titlew, off.Add(image.Pt((maxw-titlesz.X)/2, 0)))
-	op.Position(ops, body, off.Add(image.Pt(0, titlesz.Y)))
-	return box.Bounds().Size()
-}
-
-type WordKeyboardScreen struct {
-	Mnemonic bip39.Mnemonic
-	selected int
-	kbd      *Keyboard
-}
-
-func (s *WordKeyboardScreen) Layout(ctx *Context, ops op.Ctx, th *Colors, dims image.Point) bool {
-	if s.kbd == nil {
-		s.kbd = NewKeyboard(ctx)
-	}
-	for {
-		e, ok := ctx.Next()
-		if !ok {
-			break
-		}
-		switch e.Button {
-		case input.Button1:
-			if e.Click {
-				return true
-			}
-		case input.Button2:
-			if !e.Click {
-				break
-			}
-			w, complete := s.kbd.Complete()
-			if !complete {
-				break
-			}
-			s.kbd.Clear()
-			s.Mnemonic[s.selected] = w
-			for {
-				s.selected++
-				if s.selected == len(s.Mnemonic) {
-					return true
-				}
-				if s.Mnemonic[s.selected] == -1 {
-					break
-				}
-			}
-		default:
-			s.kbd.Event(e)
-		}
-	}
-	completedWord, complete := s.kbd.Complete()
-	op.ColorOp(ops, th.Background)
-	layoutTitle(ctx, ops, dims.X, th.Text, "Input Words")
-
-	screen := layout.Rectangle{Max: dims}
-	_, content := screen.CutTop(leadingSize)
-	content, _ = content.CutBottom(8)
-
-	kbdsz := s.kbd.Layout(ctx, ops.Begin(), th)
-	op.Position(ops, ops.End(), content.S(kbdsz))
-
-	layoutWord := func(ops op.Ctx, n int, word string) image.Point {
-		style := ctx.Styles.word
-		txt := fmt.Sprintf("%2d: %s", n, word)
-		return widget.Label(ops, style, th.Background, txt)
-	}
-
-	longest := layoutWord(op.Ctx{}, 24, longestWord)
-	hint := s.kbd.Word
-	if complete {
-		hint = strings.ToUpper(bip39.LabelFor(completedWord))
-	}
-	layoutWord(ops.Begin(), s.selected+1, hint)
-	word := ops.End()
-	r := image.Rectangle{Max: longest}
-	r.Min.Y -= 3
-	op.MaskOp(ops.Begin(), assets.ButtonFocused.For(r))
-	op.ColorOp(ops, th.Text)
-	word.Add(ops)
-	top, _ := content.CutBottom(kbdsz.Y)
-	op.Position(ops, ops.End(), top.Center(longest))
-
 	layoutNavigation(ctx, ops, th, dims,
-		NavButton{Button: input.Button1, Style: StyleSecondary, Icon: assets.IconBack},
+		NavButton{Button: input.Button1, Style: StyleSecondary, Icon: assets.IconEdit},
+		NavButton{Button: input.Button3, Style: StylePrimary, Icon: assets.IconCheckmark},
 	)
-	if complete {
-		layoutNavigation(ctx, ops, th, dims, NavButton{Button: input.Button2, Style: StylePrimary, Icon: assets.IconCheckmark})
-	}
-	return false
+	return nil, false
 }
 
 var kbdKeys = [...][]rune{
@@ -2246,7 +4939,7 @@ func (s *ChoiceScreen) Layout(ctx *Context, ops op.Ctx, th *Colors, dims image.P
 
 type MainScreen struct {
 	mnemonic bip39.Mnemonic
-	page     walletType
+	page     mainPage
 	scanner  *ScanScreen
 	desc     *DescriptorScreen
 	seed     *SeedScreen
@@ -2255,18 +4948,28 @@ type MainScreen struct {
 		warning *ConfirmWarningScreen
 		shown   bool
 	}
-	engrave *EngraveScreen
+	engrave   *EngraveScreen
+	verify    *VerifyScreen
+	calibrate *CalibrateScreen
+	diagnose  *DiagnosticsScreen
+	build     *BuildScreen
 }
 
 func (s *MainScreen) Select(ctx *Context) {
 	switch s.page {
+	case calibrateEngraver:
+		s.calibrate = NewCalibrateScreen(ctx)
+	case diagnoseEngraver:
+		s.diagnose = NewDiagnosticsScreen(ctx)
 	case singleKey:
-		s.seed = NewEmptySeedScreen(ctx, "Input Seed")
+		s.seed = NewGenerateSeedScreen(ctx, "Input Seed")
 	case multiKey:
 		s.scanner = &ScanScreen{
 			Title: "Scan",
 			Lead:  "Wallet Output Descriptor",
 		}
+	case buildMultisig:
+		s.build = NewBuildScreen()
 	}
 }
 
@@ -2285,6 +4988,15 @@ func (s *MainScreen) Layout(ctx *Context, ops op.Ctx, dims image.Point, err erro
 		case multiKey:
 			title = "Backup Multisig"
 			th = &descriptorTheme
+		case buildMultisig:
+			title = "Build Multisig"
+			th = &descriptorTheme
+		case calibrateEngraver:
+			title = "Calibrate Engraver"
+			th = &engraveTheme
+		case diagnoseEngraver:
+			title = "Diagnose Engraver"
+			th = &engraveTheme
 		}
 		switch {
 		case s.seed != nil:
@@ -2348,9 +5060,56 @@ func (s *MainScreen) Layout(ctx *Context, ops op.Ctx, dims image.Point, err erro
 				dialog.Add(ops)
 				return
 			}
-			s.seed = NewSeedScreen(ctx, s.mnemonic)
+			if s.engrave.completed {
+				s.verify = NewVerifyScreen(s.mnemonic)
+			} else {
+				s.seed = NewSeedScreen(ctx, s.mnemonic)
+			}
 			s.engrave = nil
 			continue
+		case s.verify != nil:
+			_, done := s.verify.Layout(ctx, ops.Begin(), th, dims)
+			dialog := ops.End()
+			if !done {
+				dialog.Add(ops)
+				return
+			}
+			s.verify = nil
+			s.seed = NewSeedScreen(ctx, s.mnemonic)
+			continue
+		case s.calibrate != nil:
+			done := s.calibrate.Layout(ctx, ops.Begin(), dims)
+			dialog := ops.End()
+			if !done {
+				dialog.Add(ops)
+				return
+			}
+			s.calibrate = nil
+			continue
+		case s.diagnose != nil:
+			done := s.diagnose.Layout(ctx, ops.Begin(), dims)
+			dialog := ops.End()
+			if !done {
+				dialog.Add(ops)
+				return
+			}
+			s.diagnose = nil
+			continue
+		case s.build != nil:
+			desc, done := s.build.Layout(ctx, ops.Begin(), dims)
+			dialog := ops.End()
+			if !done {
+				dialog.Add(ops)
+				return
+			}
+			s.build = nil
+			if desc != nil {
+				s.desc = &DescriptorScreen{
+					Descriptor: *desc,
+					validated:  true,
+				}
+			}
+			continue
 		case s.desc != nil:
 			done := s.desc.Layout(ctx, ops.Begin(), dims)
 			dialog := ops.End()
@@ -2391,7 +5150,8 @@ func (s *MainScreen) Layout(ctx *Context, ops op.Ctx, dims image.Point, err erro
 			if !e.Click {
 				break
 			}
-			if ctx.NoSDCard || s.sdcard.shown {
+			// Calibration and engraver traces are stored on the SD card.
+			if ctx.NoSDCard || s.sdcard.shown || s.page == calibrateEngraver || s.page == diagnoseEngraver {
 				s.Select(ctx)
 			} else {
 				s.sdcard.warning = &ConfirmWarningScreen{
@@ -2409,14 +5169,14 @@ func (s *MainScreen) Layout(ctx *Context, ops op.Ctx, dims image.Point, err erro
 			}
 			s.page--
 			if s.page < 0 {
-				s.page = multiKey
+				s.page = diagnoseEngraver
 			}
 		case input.Right:
 			if !e.Pressed {
 				break
 			}
 			s.page++
-			if s.page > multiKey {
+			if s.page > diagnoseEngraver {
 				s.page = 0
 			}
 		}
@@ -2537,7 +5297,7 @@ func (s *MainScreen) layoutPage(ops op.Ctx, th *Colors, width int) image.Point {
 	right := ops.End()
 	rightsz := h.Add(assets.ArrowRight.Bounds().Size())
 
-	contentsz := h.Add(s.layoutMainPlates(ops.Begin()))
+	contentsz := h.Add(s.layoutMainPlates(ops.Begin(), th))
 	content := ops.End()
 
 	const margin = 16
@@ -2549,7 +5309,7 @@ func (s *MainScreen) layoutPage(ops op.Ctx, th *Colors, width int) image.Point {
 	return image.Pt(width, h.Size.Y)
 }
 
-func (s *MainScreen) layoutMainPlates(ops op.Ctx) image.Point {
+func (s *MainScreen) layoutMainPlates(ops op.Ctx, th *Colors) image.Point {
 	switch s.page {
 	case singleKey:
 		img := assets.PlateCreditcardPrimary
@@ -2565,12 +5325,29 @@ func (s *MainScreen) layoutMainPlates(ops op.Ctx) image.Point {
 			cursor = cursor.Add(off)
 		}
 		return img.Bounds().Size().Add(cursor).Sub(off)
+	case buildMultisig:
+		img := assets.PlateSquarePrimary
+		sz := img.Bounds().Size()
+		op.ImageOp(ops, img)
+		op.Offset(ops, sz.Sub(assets.IconPlus.Bounds().Size()).Div(2))
+		op.MaskOp(ops, assets.IconPlus)
+		op.ColorOp(ops, th.Text)
+		return sz
+	case calibrateEngraver:
+		img := assets.SH01
+		op.ImageOp(ops, img)
+		return img.Bounds().Size()
+	case diagnoseEngraver:
+		img := assets.IconHammer
+		op.MaskOp(ops, img)
+		op.ColorOp(ops, th.Text)
+		return img.Bounds().Size()
 	}
 	panic("invalid page")
 }
 
 func (s *MainScreen) layoutPager(ops op.Ctx, th *Colors) image.Point {
-	const npages = int(multiKey) + 1
+	const npages = int(diagnoseEngraver) + 1
 	const space = 4
 	sz := assets.CircleFilled.Bounds().Size()
 	for i := 0; i < npages; i++ {
@@ -2587,11 +5364,18 @@ func (s *MainScreen) layoutPager(ops op.Ctx, th *Colors) image.Point {
 
 type Platform interface {
 	Input(ch chan<- input.Event) error
-	Engraver() (io.ReadWriteCloser, error)
+	Engraver() (engrave.Engraver, error)
 	Camera(size image.Point, frames chan camera.Frame, out <-chan camera.Frame) (func(), error)
 	Dump(path string, r io.Reader) error
 	Now() time.Time
 	SDCard() <-chan bool
+	// LoadFile reads a file from the SD card.
+	LoadFile(path string) ([]byte, error)
+	// StoreFile writes a file to the SD card.
+	StoreFile(path string, data []byte) error
+	// FailedTrace returns and forgets the redacted protocol trace of
+	// the last failed engraving, or nil.
+	FailedTrace() []byte
 }
 
 type LCD interface {
@@ -2638,6 +5422,14 @@ func (a *App) Frame() {
 	select {
 	case inserted := <-a.ctx.Platform.SDCard():
 		a.ctx.NoSDCard = !inserted
+		if inserted {
+			if err := a.ctx.LoadCalibration(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				log.Printf("gui: failed to load calibration: %v", err)
+			}
+			if err := a.ctx.LoadInterruptions(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				log.Printf("gui: failed to load interruptions: %v", err)
+			}
+		}
 	case <-a.ctx.Wakeup:
 	case <-a.idle.timeout:
 		a.saveScreen()
@@ -1,10 +1,13 @@
 package gui
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"image"
 	"io"
+	"io/fs"
 	"reflect"
 	"strings"
 	"testing"
@@ -13,7 +16,9 @@ import (
 	"github.com/btcsuite/btcd/btcutil/hdkeychain"
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/skip2/go-qrcode"
+	"golang.org/x/image/math/f32"
 	"seedhammer.com/backup"
+	"seedhammer.com/bc/ur"
 	"seedhammer.com/bc/urtypes"
 	"seedhammer.com/bip32"
 	"seedhammer.com/bip39"
@@ -35,18 +40,18 @@ func TestDescriptorScreenError(t *testing.T) {
 	}
 	fillDescriptor(t, dupDesc, dupDesc.DerivationPath(), 12, 0)
 	dupDesc.Keys[1] = dupDesc.Keys[0]
-	smallDesc := urtypes.OutputDescriptor{
+	largeDesc := urtypes.OutputDescriptor{
 		Type:      urtypes.P2WSH,
 		Threshold: 2,
-		Keys:      make([]urtypes.KeyDescriptor, 5),
+		Keys:      make([]urtypes.KeyDescriptor, 20),
 	}
-	fillDescriptor(t, smallDesc, smallDesc.DerivationPath(), 12, 0)
+	fillDescriptor(t, largeDesc, largeDesc.DerivationPath(), 12, 0)
 	tests := []struct {
 		name string
 		desc urtypes.OutputDescriptor
 	}{
 		{"duplicate key", dupDesc},
-		{"small threshold", smallDesc},
+		{"too many keys", largeDesc},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
@@ -72,13 +77,13 @@ func TestValidateDescriptor(t *testing.T) {
 	fillDescriptor(t, dup, dup.DerivationPath(), 12, 0)
 	dup.Keys[1] = dup.Keys[0]
 
-	// Threshold too small.
-	smallDesc := urtypes.OutputDescriptor{
+	// Too many keys, even for continuation plates.
+	largeDesc := urtypes.OutputDescriptor{
 		Type:      urtypes.P2WSH,
 		Threshold: 2,
-		Keys:      make([]urtypes.KeyDescriptor, 5),
+		Keys:      make([]urtypes.KeyDescriptor, 20),
 	}
-	fillDescriptor(t, smallDesc, smallDesc.DerivationPath(), 12, 0)
+	fillDescriptor(t, largeDesc, largeDesc.DerivationPath(), 12, 0)
 
 	// Non-standard derivation path.
 	nonStandard := urtypes.OutputDescriptor{
@@ -94,7 +99,7 @@ func TestValidateDescriptor(t *testing.T) {
 		err  error
 	}{
 		{"duplicate key", dup, new(errDuplicateKey)},
-		{"threshold too small", smallDesc, backup.ErrDescriptorTooLarge},
+		{"too many keys", largeDesc, backup.ErrDescriptorTooLarge},
 		{"non-standard path", nonStandard, new(errNonstandardDerivation)},
 	}
 	for _, test := range tests {
@@ -137,14 +142,46 @@ func TestMainScreen(t *testing.T) {
 	}
 }
 
+func TestMainScreenVerify(t *testing.T) {
+	scr := new(MainScreen)
+	ctx := NewContext(newPlatform())
+	frame := func() {
+		scr.Layout(ctx, op.Ctx{}, image.Point{}, nil)
+	}
+	m := twoOfThree.Mnemonic
+	desc, ok := singlesigDescriptor(m, "")
+	if !ok {
+		t.Fatal("failed to build single-sig descriptor")
+	}
+	eng, err := NewEngraveScreen(ctx, desc, m, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	scr.mnemonic = m
+	scr.engrave = eng
+	// Complete the engraving.
+	scr.engrave.step = len(scr.engrave.instructions) - 1
+	ctxButton(ctx, input.Button3)
+	frame()
+	if scr.verify == nil {
+		t.Fatal("single-sig engraving didn't offer verification")
+	}
+	// Skip verification.
+	ctxButton(ctx, input.Down, input.Button3)
+	frame()
+	if scr.verify != nil || scr.seed == nil || !reflect.DeepEqual(scr.seed.Mnemonic, m) {
+		t.Fatal("verification didn't return to the seed")
+	}
+}
+
 func TestDescriptorScreen(t *testing.T) {
 	scr := &DescriptorScreen{
 		Descriptor: twoOfThree.Descriptor,
 	}
 	ctx := NewContext(newPlatform())
 
-	// Accept seed, select 12 words.
-	ctxButton(ctx, input.Button3, input.Button3)
+	// Accept descriptor, input share, select 12 words.
+	ctxButton(ctx, input.Button3, input.Button3, input.Button3)
 	if ctx.EnableSeedScan {
 		// Select keyboard input.
 		ctxButton(ctx, input.Button3)
@@ -163,122 +200,1021 @@ func TestDescriptorScreen(t *testing.T) {
 	// Accept seed.
 	ctxButton(ctx, input.Button3)
 	scr.Layout(ctx, op.Ctx{}, image.Point{})
-	if scr.warning == nil {
-		t.Fatal("a non-participating seed was accepted")
+	if scr.warning == nil {
+		t.Fatal("a non-participating seed was accepted")
+	}
+}
+
+func TestDescriptorScreenShares(t *testing.T) {
+	scr := &DescriptorScreen{
+		Descriptor: twoOfThree.Descriptor,
+	}
+	p := newPlatform()
+	ctx := NewContext(p)
+	enterShare := func() {
+		// Input share, select 12 words.
+		ctxButton(ctx, input.Button3, input.Button3)
+		if ctx.EnableSeedScan {
+			// Select keyboard input.
+			ctxButton(ctx, input.Button3)
+		}
+		for _, w := range twoOfThree.Mnemonic {
+			ctxString(ctx, strings.ToUpper(bip39.LabelFor(w)))
+			ctxButton(ctx, input.Button2)
+		}
+		// Accept seed.
+		ctxButton(ctx, input.Button3)
+		scr.Layout(ctx, op.Ctx{}, image.Point{})
+	}
+
+	// Accept descriptor and enter the first share.
+	ctxButton(ctx, input.Button3)
+	enterShare()
+	if scr.engrave == nil {
+		t.Fatal("share didn't start engraving")
+	}
+	if scr.keyIdx != 0 {
+		t.Errorf("seed of share 1 detected as share %d", scr.keyIdx+1)
+	}
+	plates := scr.engrave.plates
+	m := scr.mnemonic
+	// Leave the engraving before completing it.
+	ctxButton(ctx, input.Button1)
+	scr.Layout(ctx, op.Ctx{}, image.Point{})
+	ctxPress(ctx, input.Button3)
+	scr.Layout(ctx, op.Ctx{}, image.Point{})
+	p.timeOffset += confirmDelay
+	scr.Layout(ctx, op.Ctx{}, image.Point{})
+	if scr.engrave != nil {
+		t.Fatal("engraving didn't exit")
+	}
+	// Select the entered share again.
+	ctxButton(ctx, input.Button3, input.Down, input.Down, input.Button3)
+	scr.Layout(ctx, op.Ctx{}, image.Point{})
+	if scr.seed != nil {
+		t.Fatal("entered share asked for its seed")
+	}
+	if scr.engrave == nil || scr.engrave.choose == nil {
+		t.Fatal("entered share didn't offer a choice of sides")
+	}
+	if !reflect.DeepEqual(scr.engrave.plates, plates) {
+		t.Error("entered share engraves different plates")
+	}
+	// Complete the engraving.
+	scr.engrave.choose = nil
+	scr.engrave.step = len(scr.engrave.instructions) - 1
+	ctxButton(ctx, input.Button3)
+	scr.Layout(ctx, op.Ctx{}, image.Point{})
+	if scr.verify == nil {
+		t.Fatal("completed share didn't offer verification")
+	}
+	// Skip verification.
+	ctxButton(ctx, input.Down, input.Button3)
+	scr.Layout(ctx, op.Ctx{}, image.Point{})
+	if scr.engrave != nil || scr.verify != nil || scr.mnemonic != nil {
+		t.Fatal("completed share wasn't forgotten")
+	}
+	for _, w := range m {
+		if w != -1 {
+			t.Fatal("completed share wasn't wiped")
+		}
+	}
+	// Engrave the share again from its validated plates.
+	ctxButton(ctx, input.Button3, input.Down, input.Down, input.Button3)
+	scr.Layout(ctx, op.Ctx{}, image.Point{})
+	if scr.seed != nil || scr.engrave == nil {
+		t.Fatal("engraved share wasn't offered again")
+	}
+	if !reflect.DeepEqual(scr.engrave.plates, plates) {
+		t.Error("engraved share engraves different plates")
+	}
+	// Complete the engraving without verifying the forgotten share.
+	scr.engrave.choose = nil
+	scr.engrave.step = len(scr.engrave.instructions) - 1
+	ctxButton(ctx, input.Button3)
+	scr.Layout(ctx, op.Ctx{}, image.Point{})
+	if scr.engrave != nil || scr.verify != nil {
+		t.Fatal("engraving again didn't complete")
+	}
+}
+
+func TestDescriptorScreenSession(t *testing.T) {
+	scr := &DescriptorScreen{
+		Descriptor: twoOfThree.Descriptor,
+	}
+	ctx := NewContext(newPlatform())
+	enterShare := func() {
+		// Continue, select 12 words.
+		ctxButton(ctx, input.Button3, input.Button3)
+		if ctx.EnableSeedScan {
+			// Select keyboard input.
+			ctxButton(ctx, input.Button3)
+		}
+		for _, w := range twoOfThree.Mnemonic {
+			ctxString(ctx, strings.ToUpper(bip39.LabelFor(w)))
+			ctxButton(ctx, input.Button2)
+		}
+		// Accept seed.
+		ctxButton(ctx, input.Button3)
+		scr.Layout(ctx, op.Ctx{}, image.Point{})
+	}
+
+	// Accept descriptor, select all shares.
+	ctxButton(ctx, input.Button3, input.Down, input.Button3)
+	scr.Layout(ctx, op.Ctx{}, image.Point{})
+	if scr.session == nil {
+		t.Fatal("no session started")
+	}
+	enterShare()
+	if scr.engrave == nil {
+		t.Fatal("share didn't start engraving")
+	}
+	m := scr.mnemonic
+	// Complete the engraving.
+	scr.engrave.step = len(scr.engrave.instructions) - 1
+	ctxButton(ctx, input.Button3)
+	scr.Layout(ctx, op.Ctx{}, image.Point{})
+	if scr.verify == nil {
+		t.Fatal("session didn't offer verification")
+	}
+	// Verify the plate.
+	ctxButton(ctx, input.Button3)
+	for _, p := range scr.verify.positions {
+		ctxString(ctx, strings.ToUpper(bip39.LabelFor(m[p])))
+		ctxButton(ctx, input.Button2)
+	}
+	scr.Layout(ctx, op.Ctx{}, image.Point{})
+	if scr.engrave != nil || scr.seed != nil || scr.verify != nil {
+		t.Fatal("session didn't return to its summary")
+	}
+	if !scr.session.done[0] || scr.session.remaining() != len(twoOfThree.Descriptor.Keys)-1 {
+		t.Errorf("session engraved shares %v, expected only share 1", scr.session.done)
+	}
+	if got := scr.session.verified[0]; got != verifyPassed {
+		t.Errorf("share 1 verification recorded %v, expected a pass", got)
+	}
+	for _, w := range m {
+		if w != -1 {
+			t.Fatal("engraved share wasn't wiped")
+		}
+	}
+	// Enter the engraved share again.
+	enterShare()
+	if scr.warning == nil || scr.warning.Title != "Already Engraved" {
+		t.Fatalf("share engraved twice reported %+v", scr.warning)
+	}
+	// Dismiss warning and end the session.
+	ctxButton(ctx, input.Button3, input.Button1)
+	scr.Layout(ctx, op.Ctx{}, image.Point{})
+	if scr.session != nil {
+		t.Error("session didn't end")
+	}
+}
+
+func TestBuildScreen(t *testing.T) {
+	p := newPlatform()
+	ctx := NewContext(p)
+	scr := NewBuildScreen()
+	frame := func() {
+		scr.Layout(ctx, op.Ctx{}, image.Point{})
+	}
+	scanKey := func(k urtypes.KeyDescriptor) {
+		// Add key, scan.
+		p.camera.init = make(chan struct{})
+		ctxButton(ctx, input.Button2, input.Button3)
+		frame()
+		ctxQR(t, p, frame, strings.ToUpper(ur.Encode("crypto-hdkey", k.Encode(), 1, 1)))
+	}
+	keys := twoOfThree.Descriptor.Keys
+
+	// Select SEGWIT.
+	ctxButton(ctx, input.Button3)
+	frame()
+	// Add key from seed.
+	ctxButton(ctx, input.Button2, input.Down, input.Button3)
+	// Select 12 words.
+	ctxButton(ctx, input.Button3)
+	if ctx.EnableSeedScan {
+		// Select keyboard input.
+		ctxButton(ctx, input.Button3)
+	}
+	for _, w := range twoOfThree.Mnemonic {
+		ctxString(ctx, strings.ToUpper(bip39.LabelFor(w)))
+		ctxButton(ctx, input.Button2)
+	}
+	frame()
+	if scr.seed == nil {
+		t.Fatal("seed input didn't open")
+	}
+	m := scr.seed.Mnemonic
+	// Accept seed.
+	ctxButton(ctx, input.Button3)
+	frame()
+	if n := len(scr.desc.Keys); n != 1 {
+		t.Fatalf("adding a seed left %d keys, expected 1", n)
+	}
+	if scr.seed != nil {
+		t.Error("build screen kept the seed input")
+	}
+	for _, w := range m {
+		if w != -1 {
+			t.Fatal("added seed wasn't wiped")
+		}
+	}
+	// Build with too few keys.
+	ctxButton(ctx, input.Button3)
+	frame()
+	if scr.warning == nil || scr.warning.Title != "Too Few Keys" {
+		t.Fatalf("building with 1 key reported %+v", scr.warning)
+	}
+	ctxButton(ctx, input.Button3)
+	frame()
+
+	scanKey(keys[1])
+	scanKey(keys[1])
+	if scr.warning == nil || scr.warning.Title != "Duplicated Share" {
+		t.Fatalf("duplicate key reported %+v", scr.warning)
+	}
+	ctxButton(ctx, input.Button3)
+	frame()
+	nonstd := keys[2]
+	nonstd.DerivationPath = urtypes.Path{hdkeychain.HardenedKeyStart + 1}
+	scanKey(nonstd)
+	if scr.warning == nil || scr.warning.Title != "Non-standard Derivation" {
+		t.Fatalf("non-standard key reported %+v", scr.warning)
+	}
+	ctxButton(ctx, input.Button3)
+	frame()
+	scanKey(keys[2])
+	// Remove the third key and add it again.
+	ctxButton(ctx, input.Button2, input.Down, input.Down, input.Button3)
+	frame()
+	ctxButton(ctx, input.Down, input.Down, input.Button3)
+	frame()
+	if n := len(scr.desc.Keys); n != 2 {
+		t.Fatalf("removing a key left %d keys, expected 2", n)
+	}
+	scanKey(keys[2])
+	if scr.warning != nil {
+		t.Fatalf("adding a removed key reported %+v", scr.warning)
+	}
+
+	// Build, accept default threshold.
+	ctxButton(ctx, input.Button3, input.Button3)
+	frame()
+	if scr.export == nil {
+		t.Fatal("build didn't export the descriptor")
+	}
+	// Accept export.
+	ctxButton(ctx, input.Button3)
+	desc, done := scr.Layout(ctx, op.Ctx{}, image.Point{})
+	if !done || desc == nil {
+		t.Fatal("build screen didn't complete")
+	}
+	want := twoOfThree.Descriptor
+	want.Keys = []urtypes.KeyDescriptor{keys[1], keys[2], keys[0]}
+	for i := 1; i < len(want.Keys); i++ {
+		if bytes.Compare(want.Keys[i-1].KeyData, want.Keys[i].KeyData) >= 0 {
+			t.Fatal("expected keys are not sorted")
+		}
+	}
+	if !reflect.DeepEqual(*desc, want) {
+		t.Errorf("built descriptor\n%+v\nexpected\n%+v", *desc, want)
+	}
+}
+
+func TestVerifyScreen(t *testing.T) {
+	m := twoOfThree.Mnemonic
+	tests := []struct {
+		name  string
+		wrong bool
+		want  verifyResult
+	}{
+		{"correct", false, verifyPassed},
+		{"wrong", true, verifyFailed},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := NewContext(newPlatform())
+			scr := NewVerifyScreen(m)
+			if len(scr.positions) != verifyWords {
+				t.Fatalf("asked for %d words, expected %d", len(scr.positions), verifyWords)
+			}
+			// Verify.
+			ctxButton(ctx, input.Button3)
+			for i, p := range scr.positions {
+				w := m[p]
+				if test.wrong && i == len(scr.positions)-1 {
+					w = (w + 1) % bip39.Word(len(bip39.Wordlist))
+				}
+				ctxString(ctx, strings.ToUpper(bip39.LabelFor(w)))
+				ctxButton(ctx, input.Button2)
+			}
+			if test.wrong {
+				// Dismiss warning.
+				ctxButton(ctx, input.Button3)
+			}
+			res, done := scr.Layout(ctx, op.Ctx{}, &descriptorTheme, image.Point{})
+			if !done || res != test.want {
+				t.Errorf("verification returned %v, expected %v", res, test.want)
+			}
+			for _, w := range scr.answers {
+				if w != -1 {
+					t.Fatal("answers weren't wiped")
+				}
+			}
+		})
+	}
+}
+
+func TestEngraveScreenCancel(t *testing.T) {
+	p := newPlatform()
+	ctx := NewContext(p)
+	scr, err := NewEngraveScreen(ctx, twoOfThree.Descriptor, twoOfThree.Mnemonic, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Back.
+	ctxButton(ctx, input.Button1)
+	// Hold confirm.
+	ctxPress(ctx, input.Button3)
+	done := scr.Layout(ctx, op.Ctx{}, image.Point{})
+	if done {
+		t.Error("exited screen without confirmation")
+	}
+	p.timeOffset += confirmDelay
+	done = scr.Layout(ctx, op.Ctx{}, image.Point{})
+	if !done {
+		t.Error("failed to exit screen")
+	}
+}
+
+func TestEngraveScreenError(t *testing.T) {
+	nonstdPath := []uint32{
+		hdkeychain.HardenedKeyStart + 86,
+		hdkeychain.HardenedKeyStart + 0,
+		hdkeychain.HardenedKeyStart + 0,
+	}
+	tests := []struct {
+		name      string
+		threshold int
+		keys      int
+		path      []uint32
+		err       error
+	}{
+		{"too many keys", 1, 20, nonstdPath, backup.ErrDescriptorTooLarge},
+	}
+	for i, test := range tests {
+		name := fmt.Sprintf("%d-%d-of-%d", i, test.threshold, test.keys)
+		t.Run(name, func(t *testing.T) {
+			ctx := NewContext(newPlatform())
+			desc := urtypes.OutputDescriptor{
+				Type:      urtypes.P2WSH,
+				Threshold: test.threshold,
+				Keys:      make([]urtypes.KeyDescriptor, test.keys),
+			}
+			mnemonic := fillDescriptor(t, desc, test.path, 12, 0)
+			_, err := NewEngraveScreen(ctx, desc, mnemonic, "")
+			if err == nil {
+				t.Fatal("invalid descriptor succeeded")
+			}
+			if !errors.Is(err, test.err) {
+				t.Fatalf("got error %v, expected %v", err, test.err)
+			}
+		})
+	}
+}
+
+func TestEngraveScreenPlates(t *testing.T) {
+	desc := urtypes.OutputDescriptor{
+		Type:      urtypes.P2WSH,
+		Threshold: 1,
+		Keys:      make([]urtypes.KeyDescriptor, 3),
+	}
+	mnemonic := fillDescriptor(t, desc, desc.DerivationPath(), 12, 1)
+	ctx := NewContext(newPlatform())
+	scr, err := NewEngraveScreen(ctx, desc, mnemonic, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scr.plates) < 2 {
+		t.Fatalf("share engraved on %d plates, expected continuation plates", len(scr.plates))
+	}
+	var got, want [][2]int
+	for _, ins := range scr.instructions {
+		if ins.Type == EngraveInstruction {
+			got = append(got, [2]int{ins.Plate, ins.Side})
+		}
+	}
+	for i, p := range scr.plates {
+		for j := range p.Sides {
+			want = append(want, [2]int{i, j})
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("engraving instructions for (plate, side) %v, want %v", got, want)
+	}
+}
+
+func TestEngraveScreenConnectionError(t *testing.T) {
+	p := newPlatform()
+	p.engrave.closed = make(chan []mjolnir.Cmd, 1)
+	p.engrave.connErr = errors.New("failed to connect")
+	ctx := NewContext(p)
+	scr, err := NewEngraveScreen(ctx, twoOfThree.Descriptor, twoOfThree.Mnemonic, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Press next until connect is reached.
+	for scr.instructions[scr.step].Type != ConnectInstruction {
+		ctxButton(ctx, input.Button3)
+		scr.Layout(ctx, op.Ctx{}, image.Point{})
+	}
+	// Hold connect.
+	ctxPress(ctx, input.Button3)
+	if done := scr.Layout(ctx, op.Ctx{}, image.Point{}); done {
+		t.Error("exited screen without confirmation")
+	}
+	p.timeOffset += confirmDelay
+	scr.Layout(ctx, op.Ctx{}, image.Point{})
+	if scr.engrave.warning == nil {
+		t.Fatal("engraver error did not propagate to screen")
+	}
+	// Dismiss error.
+	ctxButton(ctx, input.Button3)
+	// Successfully connect, but fail during engraving.
+	p.engrave.connErr = nil
+	p.engrave.ioErr = errors.New("error during engraving")
+	// Hold connect.
+	ctxPress(ctx, input.Button3)
+	if done := scr.Layout(ctx, op.Ctx{}, image.Point{}); done {
+		t.Error("exited screen without confirmation")
+	}
+	p.timeOffset += confirmDelay
+	scr.Layout(ctx, op.Ctx{}, image.Point{})
+	if err := scr.engrave.warning; err != nil {
+		t.Fatalf("screen reported error for connection success: %v", err)
+	}
+	for scr.engrave.warning == nil {
+		scr.Layout(ctx, op.Ctx{}, image.Point{})
+	}
+	// Dismiss error and verify the screen offers to engrave again.
+	ctxButton(ctx, input.Button3)
+	if scr.Layout(ctx, op.Ctx{}, image.Point{}) || scr.choose == nil {
+		t.Fatal("screen didn't offer to engrave again after fatal engraver error")
+	}
+	// Decline and verify screen exits.
+	ctxButton(ctx, input.Button1)
+	done := scr.Layout(ctx, op.Ctx{}, image.Point{})
+	if !done {
+		t.Fatal("screen didn't exit after fatal engraver error")
+	}
+	// Verify device was closed.
+	<-p.engrave.closed
+}
+
+func TestEngraveScreenSides(t *testing.T) {
+	p := newPlatform()
+	p.engrave.closed = make(chan []mjolnir.Cmd, 1)
+	ctx := NewContext(p)
+	ctx.Calibration.Calibrated = true
+	scr, err := NewEngraveScreen(ctx, twoOfThree.Descriptor, twoOfThree.Mnemonic, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sides := scr.sides()
+	last := sides[len(sides)-1]
+	if last.Side != 1 {
+		t.Fatalf("last side %v is not a side B", last)
+	}
+	// Fail while engraving the last side.
+	scr.step = len(scr.instructions) - len(EngraveSuccess) - 2
+	if ins := scr.instructions[scr.step]; ins.Type != ConnectInstruction {
+		t.Fatalf("instruction %d is not a connect instruction", scr.step)
+	}
+	p.engrave.ioErr = errors.New("error during engraving")
+	ctxPress(ctx, input.Button3)
+	scr.Layout(ctx, op.Ctx{}, image.Point{})
+	p.timeOffset += confirmDelay
+	for scr.engrave.warning == nil {
+		scr.Layout(ctx, op.Ctx{}, image.Point{})
+	}
+	<-p.engrave.closed
+	ctxButton(ctx, input.Button3)
+	scr.Layout(ctx, op.Ctx{}, image.Point{})
+	if scr.choose == nil {
+		t.Fatal("screen didn't offer to engrave again")
+	}
+	if got := scr.choices[scr.choose.choice]; !reflect.DeepEqual(got, []plateSide{last}) {
+		t.Fatalf("engrave again pre-selected %v, want the failed side %v", got, last)
+	}
+	// Engrave the failed side only.
+	ctxButton(ctx, input.Button3)
+	scr.Layout(ctx, op.Ctx{}, image.Point{})
+	var got []plateSide
+	for _, ins := range scr.instructions {
+		if ins.Type == EngraveInstruction {
+			got = append(got, plateSide{Plate: ins.Plate, Side: ins.Side})
+		}
+	}
+	if want := []plateSide{last}; !reflect.DeepEqual(got, want) {
+		t.Errorf("engraving sides %v, want %v", got, want)
+	}
+	if body := scr.instructions[0].resolvedBody; !strings.Contains(body, "side B") {
+		t.Errorf("first instruction %q doesn't mention side B", body)
+	}
+}
+
+func TestEngraveScreenPreflight(t *testing.T) {
+	p := newPlatform()
+	p.engrave.closed = make(chan []mjolnir.Cmd, 1)
+	ctx := NewContext(p)
+	scr, err := NewEngraveScreen(ctx, twoOfThree.Descriptor, twoOfThree.Mnemonic, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Move a side off its plate.
+	plate := &scr.plates[len(scr.plates)-1]
+	side := len(plate.Sides) - 1
+	w := plate.Size.Bounds().Dx()
+	plate.Sides[side] = engrave.Offset(float32(w)/2, 0, plate.Sides[side])
+	for scr.instructions[scr.step].Type != ConnectInstruction {
+		ctxButton(ctx, input.Button3)
+		scr.Layout(ctx, op.Ctx{}, image.Point{})
+	}
+	ctxPress(ctx, input.Button3)
+	scr.Layout(ctx, op.Ctx{}, image.Point{})
+	p.timeOffset += confirmDelay
+	scr.Layout(ctx, op.Ctx{}, image.Point{})
+	warn := scr.engrave.warning
+	if warn == nil || warn.Title != "Outside Plate" || !strings.Contains(warn.Body, fmt.Sprintf("Side %d", side+1)) {
+		t.Fatalf("preflight failure reported as %+v", warn)
+	}
+	if cmds := <-p.engrave.closed; len(cmds) > 0 {
+		t.Errorf("engraver received %d commands for a refused plate", len(cmds))
+	}
+	// Dismiss error and verify screen exits.
+	ctxButton(ctx, input.Button3)
+	if !scr.Layout(ctx, op.Ctx{}, image.Point{}) {
+		t.Error("screen didn't exit after preflight failure")
+	}
+}
+
+func TestEngraveScreenPreflightCalibrated(t *testing.T) {
+	p := newPlatform()
+	p.engrave.closed = make(chan []mjolnir.Cmd, 1)
+	ctx := NewContext(p)
+	// The offset moves the design into the left clamps.
+	ctx.Calibration = Calibration{Calibrated: true, Offset: f32.Vec2{-maxCalibrationOffset, 0}}
+	scr, err := NewEngraveScreen(ctx, twoOfThree.Descriptor, twoOfThree.Mnemonic, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for scr.instructions[scr.step].Type != ConnectInstruction {
+		ctxButton(ctx, input.Button3)
+		scr.Layout(ctx, op.Ctx{}, image.Point{})
+	}
+	ctxPress(ctx, input.Button3)
+	scr.Layout(ctx, op.Ctx{}, image.Point{})
+	p.timeOffset += confirmDelay
+	scr.Layout(ctx, op.Ctx{}, image.Point{})
+	if warn := scr.engrave.warning; warn == nil || warn.Title != "Mounting Hole" {
+		t.Fatalf("preflight failure reported as %+v", warn)
+	}
+	if cmds := <-p.engrave.closed; len(cmds) > 0 {
+		t.Errorf("engraver received %d commands for a refused plate", len(cmds))
+	}
+}
+
+func TestEngraveScreenResume(t *testing.T) {
+	p := newPlatform()
+	p.engrave.closed = make(chan []mjolnir.Cmd, 1)
+	p.files = make(map[string][]byte)
+	ctx := NewContext(p)
+	scr, err := NewEngraveScreen(ctx, twoOfThree.Descriptor, twoOfThree.Mnemonic, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for scr.instructions[scr.step].Type != ConnectInstruction {
+		ctxButton(ctx, input.Button3)
+		scr.Layout(ctx, op.Ctx{}, image.Point{})
+	}
+	connect := scr.step
+	// Disconnect after the initial move and some program steps.
+	p.engrave.faults = []mjolnir.Fault{{Kind: mjolnir.FaultDisconnect, At: 200}}
+	ctxPress(ctx, input.Button3)
+	scr.Layout(ctx, op.Ctx{}, image.Point{})
+	p.timeOffset += confirmDelay
+	for scr.engrave.warning == nil {
+		scr.Layout(ctx, op.Ctx{}, image.Point{})
+	}
+	<-p.engrave.closed
+	if scr.engrave.fatal {
+		t.Fatal("interrupted engraving is not resumable")
+	}
+	if scr.step != connect || scr.resume == 0 {
+		t.Fatalf("screen at step %d resuming %d commands, expected connect step %d", scr.step, scr.resume, connect)
+	}
+	// The interruption survives leaving the screen and restarting.
+	ctx = NewContext(p)
+	if err := ctx.LoadInterruptions(); err != nil {
+		t.Fatal(err)
+	}
+	scr, err = NewEngraveScreen(ctx, twoOfThree.Descriptor, twoOfThree.Mnemonic, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scr.resumeChoice == nil {
+		t.Fatal("new screen didn't offer to resume")
+	}
+	// Choose resume.
+	ctxButton(ctx, input.Button3)
+	scr.Layout(ctx, op.Ctx{}, image.Point{})
+	if scr.step != connect || scr.resume == 0 {
+		t.Fatalf("new screen at step %d resuming %d commands, expected connect step %d", scr.step, scr.resume, connect)
+	}
+	// Resume.
+	resume := scr.resume
+	ins := scr.instructions[connect+1]
+	cmds := new(countProgram)
+	scr.plates[ins.Plate].Sides[ins.Side].Engrave(cmds)
+	p.engrave.faults = nil
+	ctxPress(ctx, input.Button3)
+	scr.Layout(ctx, op.Ctx{}, image.Point{})
+	p.timeOffset += confirmDelay
+	for scr.engrave.lastProgress == 0 {
+		scr.Layout(ctx, op.Ctx{}, image.Point{})
+		if scr.engrave.warning != nil {
+			t.Fatal("resumed engraving failed")
+		}
+	}
+	if got, min := scr.engrave.lastProgress, float32(resume)/float32(cmds.n); got < min {
+		t.Errorf("resumed engraving at progress %v, expected at least %v", got, min)
+	}
+	// Cancelling records the progress.
+	ctxButton(ctx, input.Button1)
+	scr.Layout(ctx, op.Ctx{}, image.Point{})
+	ctxPress(ctx, input.Button3)
+	scr.Layout(ctx, op.Ctx{}, image.Point{})
+	p.timeOffset += confirmDelay
+	if !scr.Layout(ctx, op.Ctx{}, image.Point{}) {
+		t.Fatal("cancelled engraving didn't exit")
+	}
+	<-p.engrave.closed
+	id := newSideID(scr.plates[ins.Plate].Sides[ins.Side])
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if n, _ := ctx.interrupted.Get(id); n > resume {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("cancelled engraving didn't record more than %d completed commands", resume)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	// Start over.
+	scr, err = NewEngraveScreen(ctx, twoOfThree.Descriptor, twoOfThree.Mnemonic, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctxButton(ctx, input.Down, input.Button3)
+	scr.Layout(ctx, op.Ctx{}, image.Point{})
+	if scr.resumeChoice != nil || scr.step != 0 || scr.resume != 0 {
+		t.Fatalf("starting over left screen at step %d resuming %d commands", scr.step, scr.resume)
+	}
+	if _, ok := ctx.interrupted.Get(id); ok {
+		t.Error("interruption not forgotten after starting over")
+	}
+	ctx = NewContext(p)
+	if err := ctx.LoadInterruptions(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ctx.interrupted.Get(id); ok {
+		t.Error("stored interruption not forgotten after starting over")
+	}
+}
+
+func TestEngraveScreenFault(t *testing.T) {
+	p := newPlatform()
+	p.engrave.closed = make(chan []mjolnir.Cmd, 1)
+	// Disconnect during initialization.
+	p.engrave.faults = []mjolnir.Fault{{Kind: mjolnir.FaultDisconnect, At: 1}}
+	ctx := NewContext(p)
+	scr, err := NewEngraveScreen(ctx, twoOfThree.Descriptor, twoOfThree.Mnemonic, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for scr.instructions[scr.step].Type != ConnectInstruction {
+		ctxButton(ctx, input.Button3)
+		scr.Layout(ctx, op.Ctx{}, image.Point{})
+	}
+	ctxPress(ctx, input.Button3)
+	scr.Layout(ctx, op.Ctx{}, image.Point{})
+	p.timeOffset += confirmDelay
+	for scr.engrave.warning == nil {
+		scr.Layout(ctx, op.Ctx{}, image.Point{})
+	}
+	<-p.engrave.closed
+	if !scr.engrave.fatal {
+		t.Error("failed initialization is resumable")
+	}
+	if w := scr.engrave.warning; !strings.Contains(w.Body, "not engraved") {
+		t.Errorf("warning %q doesn't explain that the plate is intact", w.Body)
 	}
 }
 
-func TestEngraveScreenCancel(t *testing.T) {
+func TestEngraveScreenStrokeWidth(t *testing.T) {
 	p := newPlatform()
+	p.engrave.closed = make(chan []mjolnir.Cmd, 1)
+	p.engrave.strokeWidth = defaultStrokeWidth * 1.2
 	ctx := NewContext(p)
 	scr, err := NewEngraveScreen(ctx, twoOfThree.Descriptor, twoOfThree.Mnemonic, "")
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	// Back.
-	ctxButton(ctx, input.Button1)
-	// Hold confirm.
+	plates := scr.plates
+	for scr.instructions[scr.step].Type != ConnectInstruction {
+		ctxButton(ctx, input.Button3)
+		scr.Layout(ctx, op.Ctx{}, image.Point{})
+	}
 	ctxPress(ctx, input.Button3)
-	done := scr.Layout(ctx, op.Ctx{}, image.Point{})
-	if done {
-		t.Error("exited screen without confirmation")
+	scr.Layout(ctx, op.Ctx{}, image.Point{})
+	p.timeOffset += confirmDelay
+	scr.Layout(ctx, op.Ctx{}, image.Point{})
+	if scr.strokeWidth != p.engrave.strokeWidth || reflect.DeepEqual(scr.plates, plates) {
+		t.Errorf("plates not laid out for the stroke width %v of the engraver", p.engrave.strokeWidth)
+	}
+	<-p.engrave.closed
+	// The wider stroke needs a larger plate, so the instructions start
+	// over.
+	if w := scr.engrave.warning; w == nil || w.Title != "Plates Changed" || scr.step != 0 {
+		t.Fatalf("resized plates didn't restart the instructions (step %d, warning %v)", scr.step, w)
+	}
+	ctxButton(ctx, input.Button3)
+	scr.Layout(ctx, op.Ctx{}, image.Point{})
+	for scr.instructions[scr.step].Type != ConnectInstruction {
+		ctxButton(ctx, input.Button3)
+		scr.Layout(ctx, op.Ctx{}, image.Point{})
 	}
+	ctxPress(ctx, input.Button3)
+	scr.Layout(ctx, op.Ctx{}, image.Point{})
 	p.timeOffset += confirmDelay
-	done = scr.Layout(ctx, op.Ctx{}, image.Point{})
-	if !done {
-		t.Error("failed to exit screen")
+	scr.Layout(ctx, op.Ctx{}, image.Point{})
+	if w := scr.engrave.warning; w != nil {
+		t.Fatalf("engraver with stroke width %v rejected: %s: %s", p.engrave.strokeWidth, w.Title, w.Body)
+	}
+	scr.close()
+	<-p.engrave.closed
+	// Later shares are laid out for the connected engraver.
+	next, err := NewEngraveScreen(ctx, twoOfThree.Descriptor, twoOfThree.Mnemonic, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.strokeWidth != p.engrave.strokeWidth {
+		t.Errorf("next share laid out for stroke width %v, expected %v", next.strokeWidth, p.engrave.strokeWidth)
 	}
 }
 
-func TestEngraveScreenError(t *testing.T) {
-	nonstdPath := []uint32{
-		hdkeychain.HardenedKeyStart + 86,
-		hdkeychain.HardenedKeyStart + 0,
-		hdkeychain.HardenedKeyStart + 0,
-	}
+func TestEngraveErrorScreen(t *testing.T) {
+	job := new(engrave.Job)
+	timeout := &engrave.TimeoutError{Phase: engrave.PhaseStep, Timeout: time.Second}
 	tests := []struct {
-		name      string
-		threshold int
-		keys      int
-		path      []uint32
 		err       error
+		resumable bool
+		title     string
+		body      string
 	}{
-		{"threshold too small", 1, 5, nonstdPath, backup.ErrDescriptorTooLarge},
+		{timeout, true, "Engraver Not Responding", "resume engraving"},
+		{timeout, false, "Engraver Not Responding", "not engraved"},
+		{&engrave.ReplyError{Phase: engrave.PhaseOrigin}, false, "Engraver Error", "not engraved"},
+		{errors.New("disconnected"), true, "Connection Error", "resume engraving"},
+		{&engrave.BoundsError{Point: f32.Vec2{200, 0}}, false, "Out of Reach", "not engraved"},
 	}
-	for i, test := range tests {
-		name := fmt.Sprintf("%d-%d-of-%d", i, test.threshold, test.keys)
-		t.Run(name, func(t *testing.T) {
-			ctx := NewContext(newPlatform())
-			desc := urtypes.OutputDescriptor{
-				Type:      urtypes.P2WSH,
-				Threshold: test.threshold,
-				Keys:      make([]urtypes.KeyDescriptor, test.keys),
-			}
-			mnemonic := fillDescriptor(t, desc, test.path, 12, 0)
-			_, err := NewEngraveScreen(ctx, desc, mnemonic, "")
-			if err == nil {
-				t.Fatal("invalid descriptor succeeded")
-			}
-			if !errors.Is(err, test.err) {
-				t.Fatalf("got error %v, expected %v", err, test.err)
-			}
-		})
+	for _, test := range tests {
+		scr := engraveErrorScreen(test.err, job, test.resumable)
+		if scr.Title != test.title || !strings.Contains(scr.Body, test.body) {
+			t.Errorf("%v (resumable %v): got %q: %q, expected %q containing %q",
+				test.err, test.resumable, scr.Title, scr.Body, test.title, test.body)
+		}
 	}
 }
 
-func TestEngraveScreenConnectionError(t *testing.T) {
+func TestEngraveScreenPause(t *testing.T) {
 	p := newPlatform()
 	p.engrave.closed = make(chan []mjolnir.Cmd, 1)
-	p.engrave.connErr = errors.New("failed to connect")
 	ctx := NewContext(p)
 	scr, err := NewEngraveScreen(ctx, twoOfThree.Descriptor, twoOfThree.Mnemonic, "")
 	if err != nil {
 		t.Fatal(err)
 	}
-	// Press next until connect is reached.
 	for scr.instructions[scr.step].Type != ConnectInstruction {
 		ctxButton(ctx, input.Button3)
 		scr.Layout(ctx, op.Ctx{}, image.Point{})
 	}
-	// Hold connect.
 	ctxPress(ctx, input.Button3)
-	if done := scr.Layout(ctx, op.Ctx{}, image.Point{}); done {
-		t.Error("exited screen without confirmation")
-	}
+	scr.Layout(ctx, op.Ctx{}, image.Point{})
 	p.timeOffset += confirmDelay
 	scr.Layout(ctx, op.Ctx{}, image.Point{})
-	if scr.engrave.warning == nil {
-		t.Fatal("engraver error did not propagate to screen")
+	if scr.instructions[scr.step].Type != EngraveInstruction {
+		t.Fatal("engraving didn't start")
 	}
-	// Dismiss error.
 	ctxButton(ctx, input.Button3)
-	// Successfully connect, but fail during engraving.
-	p.engrave.connErr = nil
-	p.engrave.ioErr = errors.New("error during engraving")
-	// Hold connect.
-	ctxPress(ctx, input.Button3)
-	if done := scr.Layout(ctx, op.Ctx{}, image.Point{}); done {
-		t.Error("exited screen without confirmation")
-	}
-	p.timeOffset += confirmDelay
 	scr.Layout(ctx, op.Ctx{}, image.Point{})
-	if err := scr.engrave.warning; err != nil {
-		t.Fatalf("screen reported error for connection success: %v", err)
+	if !scr.engrave.paused {
+		t.Fatal("engraving didn't pause")
 	}
-	for scr.engrave.warning == nil {
+	deadline := time.Now().Add(5 * time.Second)
+	for !scr.engrave.parked {
+		if time.Now().After(deadline) {
+			t.Fatal("engraver didn't park for the pause")
+		}
+		time.Sleep(10 * time.Millisecond)
 		scr.Layout(ctx, op.Ctx{}, image.Point{})
 	}
-	// Dismiss error and verify screen exits.
 	ctxButton(ctx, input.Button3)
-	done := scr.Layout(ctx, op.Ctx{}, image.Point{})
-	if !done {
-		t.Fatal("screen didn't exit after fatal engraver error")
+	scr.Layout(ctx, op.Ctx{}, image.Point{})
+	if scr.engrave.paused {
+		t.Fatal("engraving didn't resume")
 	}
-	// Verify device was closed.
+	for scr.engrave.parked {
+		if time.Now().After(deadline) {
+			t.Fatal("engraver didn't continue after the pause")
+		}
+		time.Sleep(10 * time.Millisecond)
+		scr.Layout(ctx, op.Ctx{}, image.Point{})
+	}
+	scr.close()
 	<-p.engrave.closed
 }
 
+type countProgram struct {
+	n int
+}
+
+func (c *countProgram) Move(to f32.Vec2) { c.n++ }
+func (c *countProgram) Line(to f32.Vec2) { c.n++ }
+
+func TestCalibrateScreen(t *testing.T) {
+	p := newPlatform()
+	p.files = make(map[string][]byte)
+	p.engrave.closed = make(chan []mjolnir.Cmd, 1)
+	ctx := NewContext(p)
+	scr := NewCalibrateScreen(ctx)
+	frame := func() bool {
+		return scr.Layout(ctx, op.Ctx{}, image.Point{})
+	}
+	for scr.instructions[scr.step].Type != ConnectInstruction {
+		ctxButton(ctx, input.Button3)
+		frame()
+	}
+	ctxPress(ctx, input.Button3)
+	frame()
+	p.timeOffset += confirmDelay
+	for scr.instructions[scr.step].Type != AdjustInstruction {
+		frame()
+		if scr.warning != nil {
+			t.Fatal("engraving calibration marks failed")
+		}
+	}
+	if got, want := <-p.engrave.closed, simEngrave(t, calibrationMarks{}); !reflect.DeepEqual(got, want) {
+		t.Error("engraver commands mismatch for calibration marks")
+	}
+	ctxButton(ctx, input.Right, input.Right, input.Up, input.Button3)
+	if !frame() {
+		t.Fatal("calibration not confirmed")
+	}
+	want := Calibration{Calibrated: true, Offset: f32.Vec2{.2, -.1}}
+	if ctx.Calibration != want {
+		t.Errorf("calibrated to %+v, expected %+v", ctx.Calibration, want)
+	}
+	// The calibration survives a restart.
+	ctx = NewContext(p)
+	if err := ctx.LoadCalibration(); err != nil {
+		t.Fatal(err)
+	}
+	if ctx.Calibration != want {
+		t.Errorf("loaded calibration %+v, expected %+v", ctx.Calibration, want)
+	}
+	eng, err := NewEngraveScreen(ctx, twoOfThree.Descriptor, twoOfThree.Mnemonic, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if eng.instructions[0].Body == EngraveFirstSideA[0].Body {
+		t.Error("calibrated engraving repeats the needle adjustment")
+	}
+}
+
+func TestCalibrateScreenNoSDCard(t *testing.T) {
+	p := newPlatform()
+	ctx := NewContext(p)
+	scr := NewCalibrateScreen(ctx)
+	scr.step = len(scr.instructions) - 1
+	ctxButton(ctx, input.Left, input.Button3)
+	if scr.Layout(ctx, op.Ctx{}, image.Point{}) {
+		t.Fatal("unstored calibration exited without a warning")
+	}
+	if scr.warning == nil {
+		t.Fatal("no warning for unstored calibration")
+	}
+	want := f32.Vec2{-.1, 0}
+	if !ctx.Calibration.Calibrated || ctx.Calibration.Offset != want {
+		t.Errorf("calibration %+v not kept in memory", ctx.Calibration)
+	}
+	ctxButton(ctx, input.Button3)
+	if !scr.Layout(ctx, op.Ctx{}, image.Point{}) {
+		t.Error("screen didn't exit after warning")
+	}
+}
+
+func TestDiagnosticsScreen(t *testing.T) {
+	p := newPlatform()
+	p.engrave.closed = make(chan []mjolnir.Cmd, 1)
+	ctx := NewContext(p)
+	scr := new(DiagnosticsScreen)
+	frame := func() bool {
+		return scr.Layout(ctx, op.Ctx{}, image.Point{})
+	}
+	// step holds the button for a step and waits for its result.
+	step := func(name string, b input.Button) {
+		t.Helper()
+		if b == input.Button3 {
+			ctxPress(ctx, b)
+			frame()
+			p.timeOffset += confirmDelay
+		} else {
+			ctxButton(ctx, b)
+		}
+		frame()
+		if scr.busy != name {
+			t.Fatalf("step %q didn't start", name)
+		}
+		for scr.busy != "" {
+			frame()
+		}
+		if !strings.HasPrefix(scr.log[len(scr.log)-1], name+": ") || scr.warning != nil {
+			t.Fatalf("step %q failed: %v", name, scr.log)
+		}
+	}
+	step("Home", input.Button3)
+	if !scr.hasPos || scr.pos != (f32.Vec2{}) {
+		t.Errorf("homed to %v, expected origin", scr.pos)
+	}
+	step("Jog", input.Right)
+	step("Jog", input.Down)
+	if fmt.Sprintf("%.2f", scr.pos) != fmt.Sprintf("%.2f", f32.Vec2{jogStep, jogStep}) {
+		t.Errorf("jogged to %v, expected (%v,%v)", scr.pos, jogStep, jogStep)
+	}
+	if !strings.Contains(strings.Join(scr.trace, "\n"), "< position") {
+		t.Errorf("protocol trace doesn't show the position reply:\n%s", strings.Join(scr.trace, "\n"))
+	}
+	step("Test pattern", input.Button3)
+	ctxButton(ctx, input.Button1)
+	if !frame() {
+		t.Fatal("diagnostics screen didn't exit")
+	}
+	cmds := <-p.engrave.closed
+	want := simEngrave(t, testPattern{})
+	if len(cmds) < len(want) || !reflect.DeepEqual(cmds[len(cmds)-len(want):], want) {
+		t.Error("engraver commands mismatch for test pattern")
+	}
+}
+
+func TestDiagnosticsScreenTrace(t *testing.T) {
+	p := newPlatform()
+	p.files = make(map[string][]byte)
+	ctx := NewContext(p)
+	trace := []byte("# seedhammer engraver trace v1\n")
+	// Discard.
+	p.engrave.trace = trace
+	scr := NewDiagnosticsScreen(ctx)
+	ctxButton(ctx, input.Down, input.Button3)
+	scr.Layout(ctx, op.Ctx{}, image.Point{})
+	if scr.saveTrace != nil || len(p.files) > 0 {
+		t.Fatalf("discarded trace stored: %v", p.files)
+	}
+	// Store.
+	p.engrave.trace = trace
+	scr = NewDiagnosticsScreen(ctx)
+	if scr.saveTrace == nil {
+		t.Fatal("diagnostics screen didn't offer to store the failed trace")
+	}
+	ctxButton(ctx, input.Button3)
+	scr.Layout(ctx, op.Ctx{}, image.Point{})
+	if len(p.files) != 1 {
+		t.Fatalf("stored %d files, expected the trace", len(p.files))
+	}
+	for name, data := range p.files {
+		if !strings.HasPrefix(name, "traces/") || !bytes.Equal(data, trace) {
+			t.Errorf("stored %q, expected the trace", name)
+		}
+	}
+	// The trace is offered only once.
+	if scr := NewDiagnosticsScreen(ctx); scr.saveTrace != nil {
+		t.Error("trace offered again")
+	}
+}
+
 func TestScanScreenError(t *testing.T) {
 	p := newPlatform()
 	// Fail on connect.
@@ -378,6 +1314,140 @@ func TestSeedScreenScanInvalid(t *testing.T) {
 	}
 }
 
+func TestSeedScreenDice(t *testing.T) {
+	p := newPlatform()
+	ctx := NewContext(p)
+	for _, m := range NewEmptySeedScreen(ctx, "").methods {
+		if m == methodDice || m == methodLastWord {
+			t.Fatal("seed input offered to generate a seed")
+		}
+	}
+	scr := NewGenerateSeedScreen(ctx, "")
+	frame := func() (bip39.Mnemonic, bool) {
+		return scr.Layout(ctx, op.Ctx{}, &singleTheme, image.Point{})
+	}
+	roll := func(r bip39.Roll) {
+		for _, d := range r {
+			for i := 0; i < d; i++ {
+				ctxButton(ctx, input.Up)
+			}
+			// Next die.
+			ctxButton(ctx, input.Button2)
+		}
+		ctxButton(ctx, input.Button3)
+		frame()
+	}
+	// Select dice, 12 words.
+	ctxButton(ctx, input.Down, input.Down, input.Button3, input.Button3)
+	frame()
+	if scr.dice == nil {
+		t.Fatal("dice screen not selected")
+	}
+	// Roll without a word.
+	roll(bip39.Roll{1, 6, 6, 6, 1})
+	if scr.dice.warning == nil {
+		t.Fatal("dice screen accepted roll without a word")
+	}
+	ctxButton(ctx, input.Button3)
+	frame()
+	// Roll a word and take it back.
+	roll(bip39.Roll{5, 4, 3, 2, 1})
+	ctxButton(ctx, input.Button1)
+	frame()
+	want := make(bip39.Mnemonic, 12)
+	var r bip39.Roll
+	for i := range want {
+		r = bip39.Roll{i%5 + 1, 2, 3, 4, 5}
+		w, ok := bip39.DiceToWord(r)
+		if !ok {
+			t.Fatalf("roll %v doesn't match a word", r)
+		}
+		want[i] = w
+		roll(r)
+	}
+	rolled := want[len(want)-1]
+	want = want.FixChecksum()
+	if want[len(want)-1] == rolled {
+		t.Fatal("the rolled last word carries the checksum")
+	}
+	if scr.dice == nil || scr.dice.checksum == nil {
+		t.Fatal("dice screen didn't show the replaced checksum word")
+	}
+	// Reject the checksum word and roll the last word again.
+	ctxButton(ctx, input.Button1)
+	frame()
+	if d := scr.dice; d.checksum != nil || d.word != len(want)-1 || d.Mnemonic[d.word] != -1 {
+		t.Fatal("rejecting the checksum word didn't roll the last word again")
+	}
+	roll(r)
+	m := scr.dice.Mnemonic
+	ctxPress(ctx, input.Button3)
+	frame()
+	p.timeOffset += confirmDelay
+	frame()
+	if scr.dice != nil {
+		t.Fatal("dice screen didn't complete")
+	}
+	for _, w := range m {
+		if w != -1 {
+			t.Fatal("rolled words weren't wiped")
+		}
+	}
+	// Confirm seed.
+	ctxButton(ctx, input.Button3)
+	got, done := frame()
+	if !done {
+		t.Fatal("seed not confirmed")
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("rolled %v, want %v", got, want)
+	}
+}
+
+func TestSeedScreenLastWord(t *testing.T) {
+	ctx := NewContext(newPlatform())
+	scr := NewGenerateSeedScreen(ctx, "")
+	frame := func() (bip39.Mnemonic, bool) {
+		return scr.Layout(ctx, op.Ctx{}, &singleTheme, image.Point{})
+	}
+	want := twoOfThree.Mnemonic
+	// Select last word, 12 words.
+	ctxButton(ctx, input.Down, input.Down, input.Down, input.Button3, input.Button3)
+	for _, w := range want[:len(want)-1] {
+		ctxString(ctx, strings.ToUpper(bip39.LabelFor(w)))
+		ctxButton(ctx, input.Button2)
+	}
+	frame()
+	if scr.lastWord == nil {
+		t.Fatal("last word screen not selected")
+	}
+	words := scr.lastWord.words
+	if len(words) != 128 {
+		t.Fatalf("got %d final words, want 128", len(words))
+	}
+	idx := -1
+	for i, w := range words {
+		if w == want[len(want)-1] {
+			idx = i
+		}
+	}
+	if idx == -1 {
+		t.Fatal("final word not listed")
+	}
+	for i := 0; i < idx; i++ {
+		ctxButton(ctx, input.Down)
+	}
+	// Pick word, confirm seed.
+	ctxButton(ctx, input.Button3, input.Button3)
+	got, done := frame()
+	if !done {
+		t.Fatal("seed not confirmed")
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("completed %v, want %v", got, want)
+	}
+}
+
 func TestSeedScreenInvalidSeed(t *testing.T) {
 	p := newPlatform()
 	ctx := NewContext(p)
@@ -427,8 +1497,8 @@ func TestMulti(t *testing.T) {
 	for r.app.scr.desc == nil {
 		r.Frame(t)
 	}
-	// Accept descriptor, select 12 words.
-	r.Button(t, input.Button3, input.Button3)
+	// Accept descriptor, input share, select 12 words.
+	r.Button(t, input.Button3, input.Button3, input.Button3)
 	if r.app.ctx.EnableSeedScan {
 		// Select keyboard input.
 		r.Button(t, input.Button3)
@@ -467,10 +1537,8 @@ func TestSingle(t *testing.T) {
 
 	// Single sig, 12 words.
 	r.Button(t, input.Button3, input.Button3)
-	if r.app.ctx.EnableSeedScan {
-		// Select keyboard input.
-		r.Button(t, input.Button3)
-	}
+	// Select keyboard input.
+	r.Button(t, input.Button3)
 	for r.app.scr.seed == nil {
 		r.Frame(t)
 	}
@@ -570,10 +1638,20 @@ type testPlatform struct {
 		closed  chan []mjolnir.Cmd
 		connErr error
 		ioErr   error
+		// faults are injected into the simulated engraver.
+		faults []mjolnir.Fault
+		// strokeWidth, if not zero, overrides the stroke width of the
+		// simulated engraver.
+		strokeWidth float32
+		// trace is returned by FailedTrace.
+		trace []byte
 	}
 
 	timeOffset time.Duration
 	sdcard     chan bool
+	// files is the content of the SD card, or nil if no card is
+	// inserted.
+	files map[string][]byte
 }
 
 func (t *testPlatform) SDCard() <-chan bool {
@@ -592,6 +1670,28 @@ func (t *testPlatform) Dump(path string, r io.Reader) error {
 	return errors.New("not implemented")
 }
 
+func (t *testPlatform) LoadFile(path string) ([]byte, error) {
+	data, ok := t.files[path]
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+	return data, nil
+}
+
+func (t *testPlatform) FailedTrace() []byte {
+	tr := t.engrave.trace
+	t.engrave.trace = nil
+	return tr
+}
+
+func (t *testPlatform) StoreFile(path string, data []byte) error {
+	if t.files == nil {
+		return errors.New("no SD card")
+	}
+	t.files[path] = data
+	return nil
+}
+
 func ctxString(ctx *Context, str string) {
 	for _, r := range str {
 		ctx.Events(
@@ -665,12 +1765,29 @@ func (w *wrappedEngraver) Close() error {
 	return w.dev.Close()
 }
 
-func (p *testPlatform) Engraver() (io.ReadWriteCloser, error) {
+func (p *testPlatform) Engraver() (engrave.Engraver, error) {
 	if err := p.engrave.connErr; err != nil {
 		return nil, err
 	}
 	sim := mjolnir.NewSimulator()
-	return &wrappedEngraver{sim, p.engrave.closed, p.engrave.ioErr}, nil
+	sim.Faults = p.engrave.faults
+	e := mjolnir.NewEngraver(&wrappedEngraver{sim, p.engrave.closed, p.engrave.ioErr})
+	if w := p.engrave.strokeWidth; w != 0 {
+		return &strokeEngraver{e, w}, nil
+	}
+	return e, nil
+}
+
+// strokeEngraver overrides the stroke width of an engraver.
+type strokeEngraver struct {
+	*mjolnir.Engraver
+	width float32
+}
+
+func (s *strokeEngraver) Capabilities() engrave.Capabilities {
+	caps := s.Engraver.Capabilities()
+	caps.StrokeWidth = s.width
+	return caps
 }
 
 func (p *testPlatform) Camera(dims image.Point, frames chan camera.Frame, out <-chan camera.Frame) (func(), error) {
@@ -827,12 +1944,16 @@ func testEngraving(t *testing.T, r *runner, scr *EngraveScreen, desc urtypes.Out
 		KeyIdx:     keyIdx,
 		Font:       &sh.Fontsh,
 	}
-	plate, err := backup.Engrave(mjolnir.StrokeWidth, plateDesc)
+	plates, err := backup.EngraveShare(mjolnir.StrokeWidth, plateDesc)
 	if err != nil {
 		t.Fatal(err)
 	}
-	r.p.engrave.closed = make(chan []mjolnir.Cmd, len(plate.Sides))
-	for _, side := range plate.Sides {
+	var sides []engrave.Command
+	for _, p := range plates {
+		sides = append(sides, p.Sides...)
+	}
+	r.p.engrave.closed = make(chan []mjolnir.Cmd, len(sides))
+	for _, side := range sides {
 	done:
 		for {
 			switch scr.instructions[scr.step].Type {
@@ -864,15 +1985,12 @@ func testEngraving(t *testing.T, r *runner, scr *EngraveScreen, desc urtypes.Out
 
 func simEngrave(t *testing.T, plate engrave.Command) []mjolnir.Cmd {
 	sim := mjolnir.NewSimulator()
-	prog := &mjolnir.Program{}
-	plate.Engrave(prog)
-	prog.Prepare()
-	errs := make(chan error, 1)
-	go func() {
-		errs <- mjolnir.Engrave(sim, prog, nil, nil)
-	}()
-	plate.Engrave(prog)
-	if err := <-errs; err != nil {
+	defer sim.Close()
+	prog, err := mjolnir.NewProgram(plate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mjolnir.Engrave(context.Background(), sim, prog, nil, nil); err != nil {
 		t.Fatal(err)
 	}
 	return sim.Cmds
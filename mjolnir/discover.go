@@ -0,0 +1,197 @@
+package mjolnir
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// USBID identifies a USB device model.
+type USBID struct {
+	Vendor  uint16
+	Product uint16
+}
+
+func (id USBID) String() string {
+	return fmt.Sprintf("%04x:%04x", id.Vendor, id.Product)
+}
+
+// KnownIDs lists the USB serial adapters of supported engravers.
+var KnownIDs = []USBID{
+	// FTDI FT232R. The controller kernel only includes the FTDI serial
+	// driver (USB_SERIAL_FTDI_SIO in flake.nix), so the engraver has
+	// always been connected through an FTDI adapter. Other adapters are
+	// found by Fallback.
+	{0x0403, 0x6001},
+}
+
+// Candidate is a serial device found by Discover.
+type Candidate struct {
+	// Path is the device node, such as /dev/ttyUSB0.
+	Path string
+	// ID is the USB ID of the serial adapter. It is zero for devices
+	// not on USB.
+	ID USBID
+	// Rejected is the reason the device is not an engraver, or empty if
+	// it matched.
+	Rejected string
+}
+
+func (c Candidate) String() string {
+	if c.Rejected != "" {
+		return fmt.Sprintf("%s (%s): %s", c.Path, c.ID, c.Rejected)
+	}
+	return fmt.Sprintf("%s (%s)", c.Path, c.ID)
+}
+
+// NoDeviceError reports that none of the serial devices found is an
+// engraver.
+type NoDeviceError struct {
+	Candidates []Candidate
+}
+
+func (e *NoDeviceError) Error() string {
+	if len(e.Candidates) == 0 {
+		return "mjolnir: no serial devices found"
+	}
+	var found []string
+	for _, c := range e.Candidates {
+		found = append(found, c.String())
+	}
+	return "mjolnir: no engraver among serial devices: " + strings.Join(found, ", ")
+}
+
+// Discover lists the serial devices in the sysfs tree rooted at sysfs,
+// usually "/sys", and matches their USB IDs against known. Matching
+// devices are listed first.
+func Discover(sysfs string, known []USBID) ([]Candidate, error) {
+	dir := filepath.Join(sysfs, "class", "tty")
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("mjolnir: %w", err)
+	}
+	var cands []Candidate
+	for _, e := range entries {
+		dev := filepath.Join(dir, e.Name(), "device")
+		if _, err := os.Stat(dev); err != nil {
+			// Virtual terminal.
+			continue
+		}
+		c := Candidate{Path: filepath.Join("/dev", e.Name())}
+		id, err := usbID(dev)
+		switch {
+		case err != nil:
+			c.Rejected = err.Error()
+		case !matchID(id, known):
+			c.ID = id
+			c.Rejected = "unknown USB device"
+		default:
+			c.ID = id
+		}
+		cands = append(cands, c)
+	}
+	sort.SliceStable(cands, func(i, j int) bool {
+		return cands[i].Rejected == "" && cands[j].Rejected != ""
+	})
+	return cands, nil
+}
+
+// Fallback returns the only unmatched USB serial device among
+// candidates, for engravers with an adapter missing from the known IDs.
+// It reports false if there is a matching device, or if there are no or
+// several USB serial devices to choose from.
+func Fallback(cands []Candidate) (Candidate, bool) {
+	var found Candidate
+	n := 0
+	for _, c := range cands {
+		switch {
+		case c.Rejected == "":
+			return Candidate{}, false
+		case c.ID != USBID{}:
+			found = c
+			n++
+		}
+	}
+	if n != 1 {
+		return Candidate{}, false
+	}
+	return found, true
+}
+
+func matchID(id USBID, known []USBID) bool {
+	for _, k := range known {
+		if k == id {
+			return true
+		}
+	}
+	return false
+}
+
+// usbID finds the USB device of a tty device by searching its ancestors
+// for vendor and product IDs.
+func usbID(dev string) (USBID, error) {
+	dir, err := filepath.EvalSymlinks(dev)
+	if err != nil {
+		return USBID{}, err
+	}
+	// USB serial adapters are a few levels below their USB device.
+	const maxDepth = 4
+	for i := 0; i < maxDepth; i++ {
+		vendor, verr := readHex(filepath.Join(dir, "idVendor"))
+		product, perr := readHex(filepath.Join(dir, "idProduct"))
+		if verr == nil && perr == nil {
+			return USBID{Vendor: vendor, Product: product}, nil
+		}
+		dir = filepath.Dir(dir)
+	}
+	return USBID{}, errors.New("not a USB device")
+}
+
+func readHex(path string) (uint16, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	v, err := strconv.ParseUint(strings.TrimSpace(string(data)), 16, 16)
+	return uint16(v), err
+}
+
+// Watch polls the sysfs tree for serial devices every interval, and
+// sends the candidates every time they change. The channel is closed
+// when ctx is done.
+func Watch(ctx context.Context, sysfs string, known []USBID, interval time.Duration) <-chan []Candidate {
+	ch := make(chan []Candidate)
+	go func() {
+		defer close(ch)
+		var last []Candidate
+		first := true
+		tick := time.NewTicker(interval)
+		defer tick.Stop()
+		for {
+			// Errors are treated as no devices.
+			cands, _ := Discover(sysfs, known)
+			if first || !reflect.DeepEqual(cands, last) {
+				first = false
+				last = cands
+				select {
+				case ch <- cands:
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case <-tick.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
@@ -0,0 +1,115 @@
+package mjolnir
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiscover(t *testing.T) {
+	sysfs := t.TempDir()
+	addUSBSerial(t, sysfs, "ttyUSB0", "1-1", "1a86", "7523")
+	addUSBSerial(t, sysfs, "ttyUSB1", "1-2", "0403", "6001")
+	addTTY(t, sysfs, "ttyS0", filepath.Join("devices", "platform", "serial8250"))
+	// Virtual terminal without a device.
+	mkdir(t, filepath.Join(sysfs, "class", "tty", "tty0"))
+
+	got, err := Discover(sysfs, KnownIDs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Candidate{
+		{Path: "/dev/ttyUSB1", ID: USBID{0x0403, 0x6001}},
+		{Path: "/dev/ttyS0", Rejected: "not a USB device"},
+		{Path: "/dev/ttyUSB0", ID: USBID{0x1a86, 0x7523}, Rejected: "unknown USB device"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("discovered\n%v\nexpected\n%v", got, want)
+	}
+}
+
+func TestFallback(t *testing.T) {
+	serial := Candidate{Path: "/dev/ttyS0", Rejected: "not a USB device"}
+	unknown := Candidate{Path: "/dev/ttyUSB0", ID: USBID{0x1a86, 0x7523}, Rejected: "unknown USB device"}
+	other := Candidate{Path: "/dev/ttyUSB1", ID: USBID{0x067b, 0x2303}, Rejected: "unknown USB device"}
+	known := Candidate{Path: "/dev/ttyUSB2", ID: KnownIDs[0]}
+	tests := []struct {
+		cands []Candidate
+		want  string
+	}{
+		{nil, ""},
+		{[]Candidate{serial}, ""},
+		{[]Candidate{serial, unknown}, unknown.Path},
+		{[]Candidate{unknown, other}, ""},
+		{[]Candidate{known, unknown}, ""},
+	}
+	for _, test := range tests {
+		c, ok := Fallback(test.cands)
+		if got := c.Path; ok != (test.want != "") || got != test.want {
+			t.Errorf("Fallback(%v) = %q, %v, expected %q", test.cands, got, ok, test.want)
+		}
+	}
+}
+
+func TestWatch(t *testing.T) {
+	sysfs := t.TempDir()
+	mkdir(t, filepath.Join(sysfs, "class", "tty"))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := Watch(ctx, sysfs, KnownIDs, time.Millisecond)
+	if cands := <-ch; len(cands) != 0 {
+		t.Fatalf("found %v in empty sysfs", cands)
+	}
+	addUSBSerial(t, sysfs, "ttyUSB0", "1-1", "0403", "6001")
+	cands := <-ch
+	if len(cands) != 1 || cands[0].Path != "/dev/ttyUSB0" || cands[0].Rejected != "" {
+		t.Errorf("hotplugged device not found: %v", cands)
+	}
+	cancel()
+	for range ch {
+	}
+}
+
+// addUSBSerial adds a USB serial adapter to a fake sysfs tree.
+func addUSBSerial(t *testing.T, sysfs, name, port, vendor, product string) {
+	t.Helper()
+	usb := filepath.Join(sysfs, "devices", "pci0000:00", "usb1", port)
+	mkdir(t, usb)
+	writeFile(t, filepath.Join(usb, "idVendor"), vendor+"\n")
+	writeFile(t, filepath.Join(usb, "idProduct"), product+"\n")
+	rel, err := filepath.Rel(sysfs, filepath.Join(usb, port+":1.0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	addTTY(t, sysfs, name, rel)
+}
+
+// addTTY adds a tty device below the device directory dir, relative to
+// sysfs.
+func addTTY(t *testing.T, sysfs, name, dir string) {
+	t.Helper()
+	dev := filepath.Join(sysfs, dir, name)
+	mkdir(t, dev)
+	class := filepath.Join(sysfs, "class", "tty", name)
+	mkdir(t, class)
+	if err := os.Symlink(dev, filepath.Join(class, "device")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func mkdir(t *testing.T, dir string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
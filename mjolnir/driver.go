@@ -5,26 +5,132 @@ package mjolnir
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"math"
 	"runtime"
+	"time"
 
 	"github.com/tarm/serial"
 	"golang.org/x/image/math/f32"
 	"seedhammer.com/affine"
+	"seedhammer.com/engrave"
 )
 
+// Program is a validated design ready for engraving.
 type Program struct {
 	DryRun     bool
 	MoveSpeed  float32
 	PrintSpeed float32
-	End        f32.Vec2
-	cmds       chan [cmdSize]byte
-	pen        f32.Vec2
-	count      int
-	sent       int
+	// AuxSpeed is the third parameter of the engraver speed setting.
+	// Zero selects the default.
+	AuxSpeed int
+	// PenDownDelay and PenUpDelay are the needle delays, in the range
+	// 1-255. Zero selects the defaults.
+	PenDownDelay int
+	PenUpDelay   int
+	// Passes is the number of times each line is engraved. Zero means
+	// once.
+	Passes int
+	// RegionPasses overrides Passes for the lines of a region, for
+	// example to engrave QR modules deeper than text.
+	RegionPasses map[engrave.Region]int
+	End          f32.Vec2
+	// Skip is the number of commands to skip, for resuming an interrupted
+	// engraving. The needle is moved to the end point of the last
+	// skipped command before continuing.
+	Skip int
+	// Timeouts bounds the waits for the engraver.
+	Timeouts Timeouts
+	// Paused, if not nil, is called with true when a paused engraving
+	// has parked the needle, and with false when it continues.
+	Paused func(paused bool)
+
+	design    engrave.Command
+	completed int
+	// pos is the needle position reported by the engraver, in machine
+	// units.
+	pos [2]int
+}
+
+// NewProgram validates a design and returns a program for engraving
+// it. The design is engraved again by Engrave, and must produce the same
+// commands every time. NewProgram returns an *engrave.BoundsError if the
+// design moves outside the range of the engraver.
+func NewProgram(design engrave.Command) (*Program, error) {
+	v := new(validator)
+	design.Engrave(v)
+	if v.err != nil {
+		return nil, v.err
+	}
+	return &Program{design: design}, nil
+}
+
+// Len returns the number of commands in the program, including the
+// commands for repeated passes.
+func (p *Program) Len() int {
+	e := p.encoder()
+	p.design.Engrave(e)
+	return e.count
+}
+
+// Recording returns the commands of the program in machine steps, as
+// sent to the engraver.
+func (p *Program) Recording() (Recording, error) {
+	var rec Recording
+	e := p.encoder()
+	e.rec = &rec
+	p.design.Engrave(e)
+	if e.failed {
+		return nil, errors.New("mjolnir: design changed since validation")
+	}
+	return rec, nil
+}
+
+// encoder returns an encoder for the settings of p.
+func (p *Program) encoder() *encoder {
+	return &encoder{
+		dryRun:       p.DryRun,
+		passes:       p.Passes,
+		regionPasses: p.RegionPasses,
+	}
+}
+
+// Completed returns the number of commands of the program confirmed
+// executed by the engraver, including skipped commands. It is valid after
+// Engrave returns, and may be used as the Skip value for resuming an
+// interrupted engraving.
+func (p *Program) Completed() int {
+	return p.completed
+}
+
+type validator struct {
+	// area, if not nil, limits the points of the design.
+	area *engrave.Rect
+	err  error
+}
+
+func (v *validator) Move(to f32.Vec2) {
+	v.cmd(to)
+}
+
+func (v *validator) Line(to f32.Vec2) {
+	v.cmd(to)
+}
+
+func (v *validator) cmd(to f32.Vec2) {
+	if v.err != nil {
+		return
+	}
+	if _, err := mkcoords(to); err != nil {
+		v.err = err
+		return
+	}
+	if v.area != nil && !(engrave.Rect{Min: to, Max: to}).In(*v.area) {
+		v.err = &engrave.BoundsError{Point: to}
+	}
 }
 
 const StrokeWidth = 0.3
@@ -37,6 +143,8 @@ const (
 
 	defaultMoveSpeed  = .75
 	defaultPrintSpeed = .1
+	defaultAuxSpeed   = 0xe6
+	defaultPenDelay   = 0x14
 )
 
 func Open(dev string) (io.ReadWriteCloser, error) {
@@ -60,7 +168,24 @@ func Open(dev string) (io.ReadWriteCloser, error) {
 		case "windows":
 			devices = append(devices, "COM3")
 		case "linux":
-			devices = append(devices, "/dev/ttyUSB0", "/dev/ttyUSB1")
+			cands, err := Discover("/sys", KnownIDs)
+			if err != nil {
+				// No sysfs; guess.
+				devices = append(devices, "/dev/ttyUSB0", "/dev/ttyUSB1")
+				break
+			}
+			for _, c := range cands {
+				if c.Rejected == "" {
+					devices = append(devices, c.Path)
+				}
+			}
+			if len(devices) == 0 {
+				c, ok := Fallback(cands)
+				if !ok {
+					return nil, &NoDeviceError{Candidates: cands}
+				}
+				devices = append(devices, c.Path)
+			}
 		}
 	}
 	if len(devices) == 0 {
@@ -92,8 +217,13 @@ const (
 	moveCmd                 = 0x80
 	lineCmd                 = 0x00
 	nopCmd                  = 0xff
+	queryPosCmd             = 0x16
 )
 
+// posTolerance is the maximum distance, in machine units, between
+// a queried position and its expected value.
+const posTolerance = 2
+
 const (
 	initializedStatus     = 0x00
 	cancellingStatus      = 0x62
@@ -106,13 +236,155 @@ const (
 // The engraver expects program commands in batches.
 const progBatchSize = 80
 
-func Engrave(dev io.ReadWriter, prog *Program, progress chan float32, quit <-chan struct{}) (eerr error) {
-	bufw := bufio.NewWriterSize(dev, progBatchSize*cmdSize)
+// Timeouts bound the time to wait for the engraver in each phase of an
+// engraving. Zero durations select the defaults.
+type Timeouts struct {
+	Init   time.Duration
+	Origin time.Duration
+	Reply  time.Duration
+	Batch  time.Duration
+	Step   time.Duration
+}
+
+var defaultTimeouts = Timeouts{
+	Init:   5 * time.Second,
+	Origin: time.Minute,
+	Reply:  5 * time.Second,
+	Batch:  10 * time.Second,
+	Step:   30 * time.Second,
+}
+
+func (t Timeouts) timeout(p engrave.Phase) time.Duration {
+	var d, def time.Duration
+	switch p {
+	case engrave.PhaseInit:
+		d, def = t.Init, defaultTimeouts.Init
+	case engrave.PhaseOrigin:
+		d, def = t.Origin, defaultTimeouts.Origin
+	case engrave.PhaseReply:
+		d, def = t.Reply, defaultTimeouts.Reply
+	case engrave.PhaseBatch:
+		d, def = t.Batch, defaultTimeouts.Batch
+	case engrave.PhaseStep:
+		d, def = t.Step, defaultTimeouts.Step
+	}
+	if d == 0 {
+		d = def
+	}
+	return d
+}
+
+// StepError reports a program that completed with a different number
+// of step statuses than commands sent. Lost or duplicated statuses make
+// the count of completed commands unreliable for resuming an engraving
+// interrupted before its completion.
+type StepError struct {
+	Confirmed int
+	Sent      int
+}
+
+func (e *StepError) Error() string {
+	return fmt.Sprintf("mjolnir: engraver confirmed %d of %d program steps", e.Confirmed, e.Sent)
+}
+
+// Control is a request to change the state of a running engraving.
+type Control int
+
+const (
+	// Pause stops the engraving at the next batch boundary and parks
+	// the needle at the program end point.
+	Pause Control = iota + 1
+	// Resume continues a paused engraving.
+	Resume
+)
+
+// Engrave runs a program on the engraver connected to dev, and reports
+// its progress to the progress function if it is not nil. The engraving
+// is paused and resumed through control, and cancelled along with ctx.
+func Engrave(ctx context.Context, dev io.ReadWriter, prog *Program, progress func(float32), control <-chan Control) error {
+	return run(ctx, dev, prog, progress, control, runEngrave)
+}
+
+// Home initializes the engraver connected to dev and moves its needle
+// to the machine origin.
+func Home(ctx context.Context, dev io.ReadWriter, timeouts Timeouts) error {
+	prog := &Program{design: engrave.Commands(nil), Timeouts: timeouts}
+	return run(ctx, dev, prog, nil, nil, runHome)
+}
+
+// QueryPos initializes the engraver connected to dev and returns the
+// position of its needle, in millimeters.
+func QueryPos(ctx context.Context, dev io.ReadWriter, timeouts Timeouts) (f32.Vec2, error) {
+	return queryPos(ctx, dev, timeouts, runQuery)
+}
+
+func queryPos(ctx context.Context, dev io.ReadWriter, timeouts Timeouts, mode runMode) (f32.Vec2, error) {
+	prog := &Program{design: engrave.Commands(nil), Timeouts: timeouts}
+	err := run(ctx, dev, prog, nil, nil, mode)
+	return prog.position(), err
+}
+
+// Jog moves the needle of the engraver connected to dev to p, and
+// returns the position of the needle after the move, in millimeters.
+// The engraver must be initialized and homed by a previous Home or
+// Engrave; Jog sends only the move and a position query.
+func Jog(ctx context.Context, dev io.ReadWriter, p f32.Vec2, timeouts Timeouts) (f32.Vec2, error) {
+	prog := &Program{design: engrave.Commands(nil), End: p, Timeouts: timeouts}
+	err := run(ctx, dev, prog, nil, nil, runJog)
+	return prog.position(), err
+}
+
+func (p *Program) position() f32.Vec2 {
+	return f32.Vec2{float32(p.pos[0]) * stepSize, float32(p.pos[1]) * stepSize}
+}
+
+// runMode selects how much of a program run does.
+type runMode int
+
+const (
+	// runEngrave homes the engraver and engraves the program.
+	runEngrave runMode = iota
+	// runHome stops after homing.
+	runHome
+	// runQuery only queries the needle position.
+	runQuery
+	// runPosition queries the needle position of an engraver
+	// initialized by a previous run.
+	runPosition
+	// runJog moves the needle of an engraver initialized and homed by
+	// a previous run to the program end point, and queries its
+	// position.
+	runJog
+)
+
+// run engraves prog, or the part of it selected by mode.
+func run(ctx context.Context, dev io.ReadWriter, prog *Program, progress func(float32), control <-chan Control, mode runMode) (eerr error) {
+	if _, err := mkcoords(prog.End); err != nil {
+		return err
+	}
+	count := prog.Len()
+	if prog.Skip > count {
+		return fmt.Errorf("mjolnir: skipping %d commands of a %d command program", prog.Skip, count)
+	}
+	penDown, penUp, aux := prog.PenDownDelay, prog.PenUpDelay, prog.AuxSpeed
+	if penDown == 0 {
+		penDown = defaultPenDelay
+	}
+	if penUp == 0 {
+		penUp = defaultPenDelay
+	}
+	if aux == 0 {
+		aux = defaultAuxSpeed
+	}
+	if penDown > 0xff || penUp > 0xff {
+		return fmt.Errorf("mjolnir: needle delays (%d,%d) out of range", penDown, penUp)
+	}
+	cmds := newStream(prog.design, count, prog.encoder())
+	defer cmds.close()
 	defer func() {
-		for i := prog.sent; i < prog.count; i++ {
-			<-prog.cmds
-		}
+		prog.completed = cmds.completed
 	}()
+	bufw := bufio.NewWriterSize(dev, progBatchSize*cmdSize)
 	writeMut := make(chan struct{}, 1)
 	writeMut <- struct{}{}
 	flush := func() {
@@ -136,7 +408,7 @@ func Engrave(dev io.ReadWriter, prog *Program, progress chan float32, quit <-cha
 	defer close(done)
 	go func() {
 		select {
-		case <-quit:
+		case <-ctx.Done():
 			select {
 			case <-writeMut:
 			case <-done:
@@ -149,23 +421,43 @@ func Engrave(dev io.ReadWriter, prog *Program, progress chan float32, quit <-cha
 		}
 	}()
 	bufr := bufio.NewReaderSize(dev, 100)
+	// phase is the phase of the next read.
+	phase := engrave.PhaseInit
+	type readResult struct {
+		data []byte
+		err  error
+	}
+	// Reads run in separate goroutines to bound their duration. A timed
+	// out read is left running until dev is closed.
+	reads := make(chan readResult, 1)
 	r := func(c int) []byte {
 		flush()
 		if eerr != nil {
 			return nil
 		}
-		data := make([]byte, c)
-		n, err := bufr.Read(data)
-		eerr = err
-		data = data[:n]
-		return data
+		go func() {
+			data := make([]byte, c)
+			n, err := bufr.Read(data)
+			reads <- readResult{data[:n], err}
+		}()
+		d := prog.Timeouts.timeout(phase)
+		timeout := time.NewTimer(d)
+		defer timeout.Stop()
+		select {
+		case res := <-reads:
+			eerr = res.err
+			return res.data
+		case <-timeout.C:
+			eerr = &engrave.TimeoutError{Phase: phase, Timeout: d}
+			return nil
+		}
 	}
 	expect := func(exp ...byte) {
 		for len(exp) > 0 && eerr == nil {
 			got := r(len(exp))
 			n := len(got)
 			if !bytes.Equal(exp[:n], got) {
-				eerr = fmt.Errorf("unexpected reply\nexp: %#x\ngot: %#x", exp, got)
+				eerr = &engrave.ReplyError{Phase: phase, Want: exp, Got: got}
 				return
 			}
 			exp = exp[n:]
@@ -173,7 +465,7 @@ func Engrave(dev io.ReadWriter, prog *Program, progress chan float32, quit <-cha
 	}
 	atleast := func(n int) []byte {
 		var res []byte
-		for n > 0 {
+		for n > 0 && eerr == nil {
 			data := r(n)
 			res = append(res, data...)
 			n -= len(data)
@@ -181,6 +473,7 @@ func Engrave(dev io.ReadWriter, prog *Program, progress chan float32, quit <-cha
 		return res
 	}
 	origin := func() {
+		phase = engrave.PhaseOrigin
 		wr(moveToOriginCmd, moveToOriginCmdExtra)
 		expect(moveToOriginCmd, moveToOriginCmdResponse)
 	}
@@ -188,6 +481,7 @@ func Engrave(dev io.ReadWriter, prog *Program, progress chan float32, quit <-cha
 		wr(cancelCmd)
 	}
 	initialize := func() {
+		phase = engrave.PhaseInit
 		cancel()
 		wr(initCmd)
 		for {
@@ -211,84 +505,149 @@ func Engrave(dev io.ReadWriter, prog *Program, progress chan float32, quit <-cha
 		return
 	}
 	queryPos := func() (x int, y int, z int) {
-		wr(0x16)
-		expect(0x16)
-		x, y, z = parseCoords(atleast(9))
+		phase = engrave.PhaseReply
+		wr(queryPosCmd)
+		expect(queryPosCmd)
+		coords := atleast(9)
+		if eerr != nil {
+			return
+		}
+		x, y, z = parseCoords(coords)
 		return
 	}
-	_, _ = atleast, queryPos
-
-	initialize()
 
 	// Speed range: [1000,30].
-	setSpeeds := func(print, move, xxx int) {
-		wr(setSpeedCmd, byte(print), byte(print>>8), byte(move), byte(move>>8), byte(xxx), byte(xxx>>8))
+	setSpeeds := func(print, move int) {
+		phase = engrave.PhaseReply
+		wr(setSpeedCmd, byte(print), byte(print>>8), byte(move), byte(move>>8), byte(aux), byte(aux>>8))
 		expect(setSpeedCmd)
 	}
 
 	// Delay range: 0-255.
 	setDelays := func(penDown, penUp int) {
+		phase = engrave.PhaseReply
 		wr(setDelaysCmd, byte(penDown), byte(penUp))
 		expect(setDelaysCmd)
 	}
-	setDelays(0x14, 0x14)
+	setup := func() {
+		initialize()
+		setDelays(penDown, penUp)
+	}
+	switch mode {
+	case runPosition, runJog:
+		// Initialized by a previous run.
+	default:
+		setup()
+	}
 
 	// Init done.
 
-	runProgram := func(p *Program, progress chan float32) {
-		p.sent = 0
-		nbatches := (p.count + progBatchSize - 1) / progBatchSize
+	// runProgram runs the remaining commands of p and reports whether
+	// it was paused by control.
+	runProgram := func(p *stream, progress func(float32), control <-chan Control) (paused bool) {
+		// Commands already sent, for example skipped by Program.Skip.
+		start := p.sent
+		count := p.count - start
+		sent := 0
+		nbatches := (count + progBatchSize - 1) / progBatchSize
 		if nbatches > 0xffff {
 			eerr = errors.New("engrave: program too large")
 			return
 		}
 		wr(initProgramCmd, byte(nbatches), byte(nbatches>>8))
 		completed := 0
+		// buffered is the number of commands, including padding, sent to
+		// the engraver.
+		buffered := 0
+		pause := false
+		// withheld is set when a batch request is withheld for pausing.
+		withheld := false
+		sendBatch := func() {
+			rem := count - sent
+			ncmd := progBatchSize
+			if ncmd > rem {
+				ncmd = rem
+			}
+			for i := 0; i < ncmd; i++ {
+				cmd, ok := p.next()
+				if !ok {
+					eerr = errors.New("mjolnir: design changed during engraving")
+					return
+				}
+				sent++
+				wr(cmd[:]...)
+			}
+			// Pad with 0xff.
+			pad := [cmdSize]byte{}
+			for i := range pad {
+				pad[i] = nopCmd
+			}
+			for i := ncmd; i < progBatchSize; i++ {
+				wr(pad[:]...)
+			}
+			buffered += progBatchSize
+		}
 	done:
 		for {
+			// Commands in flight are waiting to complete, otherwise the
+			// engraver is about to request a batch.
+			phase = engrave.PhaseBatch
+			if completed < buffered {
+				phase = engrave.PhaseStep
+			}
 			status := r(1)
 			if eerr != nil {
 				return
 			}
-			paddedCount := (p.count + progBatchSize - 1) / progBatchSize * progBatchSize
+			select {
+			case c := <-control:
+				pause = c == Pause
+				if !pause && withheld {
+					withheld = false
+					sendBatch()
+				}
+			default:
+			}
+			paddedCount := (count + progBatchSize - 1) / progBatchSize * progBatchSize
 			switch status[0] {
 			case bufferProgramStatus:
-				rem := p.count - p.sent
-				if rem == 0 {
+				if count == sent {
 					break
 				}
-				ncmd := progBatchSize
-				if ncmd > rem {
-					ncmd = rem
-				}
-				for i := 0; i < ncmd; i++ {
-					cmd := <-p.cmds
-					p.sent++
-					wr(cmd[:]...)
-				}
-				// Pad with 0xff.
-				pad := [cmdSize]byte{}
-				for i := range pad {
-					pad[i] = nopCmd
-				}
-				for i := ncmd; i < progBatchSize; i++ {
-					wr(pad[:]...)
+				if pause && p.sent > 0 {
+					// Stop at the batch boundary, once every buffered
+					// command is confirmed. The engraver may request
+					// the next batch before that.
+					if completed == buffered {
+						return true
+					}
+					withheld = true
+					break
 				}
+				sendBatch()
 			case programStepStatus:
 				completed++
+				if completed <= count {
+					p.completed = start + completed
+				}
+				if withheld && completed == buffered {
+					return true
+				}
 				if progress == nil {
 					break
 				}
-				// Don't spam the progress channel.
+				// Don't spam the progress function.
 				if completed%10 != 0 && completed < paddedCount {
 					break
 				}
-				select {
-				case <-progress:
-				default:
-				}
-				progress <- float32(completed) / float32(paddedCount)
+				progress(float32(start+completed) / float32(start+paddedCount))
 			case programCompleteStatus:
+				// The completion status confirms every command, even if
+				// step statuses were lost or duplicated.
+				p.completed = start + count
+				if completed != buffered && eerr == nil {
+					eerr = &StepError{Confirmed: completed, Sent: buffered}
+				}
 				break done
 			case cancellingStatus:
 			case cancelledStatus:
@@ -297,25 +656,43 @@ func Engrave(dev io.ReadWriter, prog *Program, progress chan float32, quit <-cha
 				}
 			}
 		}
+		return false
 	}
 
 	moveTo := func(x, y float32) {
-		move := new(Program)
-		f := func() {
-			move.Move(f32.Vec2{x, y})
+		move := newStream(moveDesign{x, y}, 1, new(encoder))
+		defer move.close()
+		runProgram(move, nil, nil)
+	}
+	// resumeAt moves the needle to the end point of the last sent
+	// command, and confirms its position.
+	resumeAt := func() {
+		x, y, _ := parseCoords(cmds.last[1:])
+		moveTo(float32(x)*stepSize, float32(y)*stepSize)
+		if qx, qy, _ := queryPos(); eerr == nil && (abs(qx-x) > posTolerance || abs(qy-y) > posTolerance) {
+			eerr = fmt.Errorf("mjolnir: resumed at (%d,%d), expected (%d,%d)", qx, qy, x, y)
 		}
-		f()
-		move.Prepare()
-		go f()
-		runProgram(move, nil)
 	}
 
-	setSpeeds(300, 300, 0xe6)
+	switch mode {
+	case runJog:
+		// Homing left the travel speeds set.
+		moveTo(prog.End[0], prog.End[1])
+		fallthrough
+	case runQuery, runPosition:
+		x, y, _ := queryPos()
+		prog.pos = [2]int{x, y}
+		return eerr
+	}
+	setSpeeds(300, 300)
 	// Move to origin.
 	origin()
 	// Avoid false origin.
 	moveTo(10, 10)
 	origin()
+	if mode == runHome {
+		return eerr
+	}
 	// 0 lowest, 1 highest.
 	moveSpeed := prog.MoveSpeed
 	printSpeed := prog.PrintSpeed
@@ -327,10 +704,53 @@ func Engrave(dev io.ReadWriter, prog *Program, progress chan float32, quit <-cha
 	}
 	mms := int(moveSpeed*float32(30) + (1.-moveSpeed)*float32(1000))
 	mps := int(printSpeed*float32(30) + (1.-printSpeed)*float32(1000))
-	setSpeeds(mps, mms, 0xe6)
-	runProgram(prog, progress)
+	if prog.Skip > 0 {
+		// Skip completed commands and move to where the last one
+		// ended.
+		for cmds.sent < prog.Skip {
+			if _, ok := cmds.next(); !ok {
+				return errors.New("mjolnir: design changed during engraving")
+			}
+		}
+		cmds.completed = prog.Skip
+		resumeAt()
+		if eerr != nil {
+			return eerr
+		}
+	}
+	setSpeeds(mps, mms)
+	for runProgram(cmds, progress, control) {
+		// Cancel the running program and park the needle.
+		setup()
+		setSpeeds(300, 300)
+		moveTo(prog.End[0], prog.End[1])
+		if eerr != nil {
+			return eerr
+		}
+		flush()
+		if prog.Paused != nil {
+			prog.Paused(true)
+		}
+	wait:
+		for {
+			select {
+			case c := <-control:
+				if c == Resume {
+					break wait
+				}
+			case <-ctx.Done():
+				return ErrCancelled
+			}
+		}
+		if prog.Paused != nil {
+			prog.Paused(false)
+		}
+		// Continue without re-homing.
+		resumeAt()
+		setSpeeds(mps, mms)
+	}
 	if eerr == nil || eerr == ErrCancelled {
-		setSpeeds(300, 300, 0xe6)
+		setSpeeds(300, 300)
 		moveTo(prog.End[0], prog.End[1])
 	}
 
@@ -339,53 +759,160 @@ func Engrave(dev io.ReadWriter, prog *Program, progress chan float32, quit <-cha
 
 var ErrCancelled = errors.New("cancelled")
 
-func mkcoords(p f32.Vec2) [9]byte {
-	p = affine.Scale(p, millimeter)
-	x, y := int(math.Round(float64(p[0]))), int(math.Round(float64(p[1])))
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func mkcoords(p f32.Vec2) ([9]byte, error) {
+	m := affine.Scale(p, millimeter)
+	x, y := int(math.Round(float64(m[0]))), int(math.Round(float64(m[1])))
 	if x < 0 || x > 0xffffff || y < 0 || y > 0xffffff {
-		panic(fmt.Errorf("(%d,%d) out of range", x, y))
+		return [9]byte{}, &engrave.BoundsError{Point: p}
 	}
 	return [...]byte{
 		byte(x), byte(x >> 8), byte(x >> 16),
 		byte(y), byte(y >> 8), byte(y >> 16),
 		0x00, 0x00, 0x00, // Z = 0.
+	}, nil
+}
+
+// stream encodes the commands of a design for the engraver.
+type stream struct {
+	cmds      chan [cmdSize]byte
+	done      chan struct{}
+	count     int
+	sent      int
+	completed int
+	// last is the last command sent.
+	last [cmdSize]byte
+}
+
+func newStream(design engrave.Command, count int, enc *encoder) *stream {
+	s := &stream{
+		cmds:  make(chan [cmdSize]byte),
+		done:  make(chan struct{}),
+		count: count,
 	}
+	enc.s = s
+	go func() {
+		defer close(s.cmds)
+		design.Engrave(enc)
+	}()
+	return s
 }
 
-func (p *Program) cmd(c [cmdSize]byte) {
-	if p.cmds != nil {
-		p.cmds <- c
-	} else {
-		p.count++
+// next returns the next command, or false if the design ended
+// prematurely.
+func (s *stream) next() ([cmdSize]byte, bool) {
+	cmd, ok := <-s.cmds
+	if ok {
+		s.sent++
+		s.last = cmd
 	}
+	return cmd, ok
 }
 
-func (p *Program) Prepare() {
-	p.cmds = make(chan [cmdSize]byte)
+func (s *stream) close() {
+	close(s.done)
 }
 
-func (p *Program) Move(to f32.Vec2) {
-	var cmd [cmdSize]byte
-	cmd[0] = moveCmd
-	coords := mkcoords(to)
-	copy(cmd[1:], coords[:])
-	p.cmd(cmd)
-	p.pause()
+// encoder encodes design commands to s, or counts them if s is nil.
+type encoder struct {
+	s *stream
+	// rec, if not nil, records the counted commands.
+	rec          *Recording
+	dryRun       bool
+	passes       int
+	regionPasses map[engrave.Region]int
+	failed       bool
+	count        int
+	region       engrave.Region
+	// pos is the end point of the previous command.
+	pos f32.Vec2
+}
+
+func (e *encoder) SetRegion(r engrave.Region) engrave.Region {
+	prev := e.region
+	e.region = r
+	return prev
+}
+
+func (e *encoder) Move(to f32.Vec2) {
+	e.cmd(moveCmd, to)
+	e.pause()
 }
 
-func (p *Program) pause() {
-	//	p.cmd([...]byte{0x82, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00})
+func (e *encoder) pause() {
+	//	e.cmd([...]byte{0x82, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00})
 }
 
-func (p *Program) Line(to f32.Vec2) {
-	if p.DryRun {
-		p.Move(to)
+func (e *encoder) Line(to f32.Vec2) {
+	if e.dryRun {
+		e.Move(to)
+		return
+	}
+	from := e.pos
+	e.cmd(lineCmd, to)
+	e.pause()
+	// Engrave repeated passes in the same direction.
+	for i := 1; i < e.passCount(); i++ {
+		e.cmd(moveCmd, from)
+		e.cmd(lineCmd, to)
+	}
+}
+
+func (e *encoder) passCount() int {
+	if n, ok := e.regionPasses[e.region]; ok {
+		return n
+	}
+	return e.passes
+}
+
+func (e *encoder) cmd(op byte, to f32.Vec2) {
+	if e.failed {
+		return
+	}
+	e.pos = to
+	if e.s == nil {
+		e.count++
+		if e.rec != nil {
+			coords, err := mkcoords(to)
+			if err != nil {
+				e.failed = true
+				return
+			}
+			x, y := coordsFromCmd(coords[:])
+			typ := MoveTo
+			if op == lineCmd {
+				typ = LineTo
+			}
+			*e.rec = append(*e.rec, Cmd{Type: typ, X: x, Y: y})
+		}
+		return
+	}
+	coords, err := mkcoords(to)
+	if err != nil {
+		// The design no longer matches its validation; end the stream
+		// early.
+		e.failed = true
 		return
 	}
 	var cmd [cmdSize]byte
-	cmd[0] = lineCmd
-	coords := mkcoords(to)
+	cmd[0] = op
 	copy(cmd[1:], coords[:])
-	p.cmd(cmd)
-	p.pause()
+	select {
+	case e.s.cmds <- cmd:
+	case <-e.s.done:
+		e.failed = true
+	}
+}
+
+// moveDesign is a single move to a point.
+type moveDesign f32.Vec2
+
+func (m moveDesign) Engrave(p engrave.Program) {
+	p.Move(f32.Vec2(m))
 }
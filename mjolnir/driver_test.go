@@ -1,31 +1,564 @@
 package mjolnir
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"io"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/skip2/go-qrcode"
 	"golang.org/x/image/math/f32"
+	"seedhammer.com/affine"
+	"seedhammer.com/engrave"
 )
 
 func TestEndToEnd(t *testing.T) {
 	s := NewSimulator()
 	defer s.Close()
 
-	prog := &Program{}
-	design := func() {
+	prog, err := NewProgram(engrave.CommandFunc(func(p engrave.Program) {
 		for i := 0; i < 2000; i++ {
-			prog.Line(f32.Vec2{float32(i), float32(i) * 2})
-			prog.Line(f32.Vec2{float32(i) * 4, float32(i) * 3})
-			prog.Move(f32.Vec2{float32(i), float32(i)})
+			p.Line(f32.Vec2{float32(i), float32(i) * 2})
+			p.Line(f32.Vec2{float32(i) * 4, float32(i) * 3})
+			p.Move(f32.Vec2{float32(i), float32(i)})
 		}
+	}))
+	if err != nil {
+		t.Fatal(err)
 	}
-	design()
-	prog.Prepare()
-	engraveErr := make(chan error)
+	if err := Engrave(context.Background(), s, prog, nil, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBounds(t *testing.T) {
+	_, err := NewProgram(engrave.CommandFunc(func(p engrave.Program) {
+		p.Move(f32.Vec2{10, 10})
+		p.Line(f32.Vec2{-1, 10})
+	}))
+	var berr *engrave.BoundsError
+	if !errors.As(err, &berr) {
+		t.Fatalf("out of bounds design returned %v", err)
+	}
+	if want := (f32.Vec2{-1, 10}); berr.Point != want {
+		t.Errorf("out of bounds point %v, expected %v", berr.Point, want)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	s := NewSimulator()
+	defer s.Close()
+	prog, err := NewProgram(gridDesign)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// Cancel after the first progress report.
+	err = Engrave(ctx, s, prog, func(float32) { cancel() }, nil)
+	if err != ErrCancelled {
+		t.Errorf("cancelled engraving returned %v, expected %v", err, ErrCancelled)
+	}
+}
+
+// gridDesign is a grid of short lines.
+var gridDesign = engrave.CommandFunc(func(p engrave.Program) {
+	for i := 0; i < 300; i++ {
+		p.Move(f32.Vec2{float32(i % 50), float32(i / 50)})
+		p.Line(f32.Vec2{float32(i%50) + .5, float32(i/50) + .5})
+	}
+})
+
+func TestResume(t *testing.T) {
+	run := func(dev io.ReadWriter, prog *Program) error {
+		return Engrave(context.Background(), dev, prog, nil, nil)
+	}
+	newProg := func(skip int) *Program {
+		prog, err := NewProgram(gridDesign)
+		if err != nil {
+			t.Fatal(err)
+		}
+		prog.Skip = skip
+		return prog
+	}
+	full := NewSimulator()
+	defer full.Close()
+	if err := run(full, newProg(0)); err != nil {
+		t.Fatal(err)
+	}
+
+	sim := NewSimulator()
+	defer sim.Close()
+	// Interrupt after the initial move, a padded batch of steps, and
+	// some program commands.
+	const completed = 130
+	dev := &interruptedDevice{dev: sim, steps: progBatchSize + completed}
+	prog := newProg(0)
+	if err := run(dev, prog); err == nil {
+		t.Fatal("interrupted engraving succeeded")
+	}
+	skip := prog.Completed()
+	if skip != completed {
+		t.Fatalf("%d commands completed, expected %d", skip, completed)
+	}
+
+	resumed := NewSimulator()
+	defer resumed.Close()
+	if err := run(resumed, newProg(skip)); err != nil {
+		t.Fatal(err)
+	}
+	// The final move to the program end point.
+	const tail = 1
+	want := full.Cmds[len(full.Cmds)-600-tail:]
+	got := resumed.Cmds[len(resumed.Cmds)-(600-skip)-tail-1:]
+	resumeAt := Cmd{Type: MoveTo, X: want[skip-1].X, Y: want[skip-1].Y}
+	if got[0] != resumeAt {
+		t.Errorf("resumed at %v, expected %v", got[0], resumeAt)
+	}
+	if !reflect.DeepEqual(got[1:], want[skip:]) {
+		t.Error("resumed commands don't match the remaining program")
+	}
+}
+
+func TestPause(t *testing.T) {
+	t.Run("batch", func(t *testing.T) { testPause(t, false) })
+	// An engraver requesting batches before completing the buffered
+	// commands.
+	t.Run("early", func(t *testing.T) { testPause(t, true) })
+}
+
+func testPause(t *testing.T, early bool) {
+	end := f32.Vec2{5, 5}
+	run := func(dev io.ReadWriter, control <-chan Control) error {
+		prog, err := NewProgram(gridDesign)
+		if err != nil {
+			return err
+		}
+		prog.End = end
+		return Engrave(context.Background(), dev, prog, nil, control)
+	}
+	full := NewSimulator()
+	defer full.Close()
+	if err := run(full, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	sim := NewSimulator()
+	sim.EarlyBatch = early
+	defer sim.Close()
+	parked := make(chan struct{})
+	dev := &cancelDevice{dev: sim, cancelled: parked}
+	control := make(chan Control)
+	errs := make(chan error, 1)
 	go func() {
-		engraveErr <- Engrave(s, prog, nil, nil)
+		errs <- run(dev, control)
 	}()
-	design()
-	if err := <-engraveErr; err != nil {
-		t.Error(err)
+	control <- Pause
+	<-parked
+	control <- Resume
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+	// Find the parking move followed by the move back to the
+	// program.
+	park := Cmd{Type: MoveTo, X: uint32(end[0] * millimeter), Y: uint32(end[1] * millimeter)}
+	got := sim.Cmds
+	for i := 0; i < len(got)-2; i++ {
+		if got[i] != park || got[i+1].Type != MoveTo {
+			continue
+		}
+		if got[i+1] != (Cmd{MoveTo, got[i-1].X, got[i-1].Y}) {
+			t.Errorf("resumed at %v, expected %v", got[i+1], got[i-1])
+		}
+		got = append(got[:i:i], got[i+2:]...)
+		if !reflect.DeepEqual(got, full.Cmds) {
+			t.Error("paused engraving doesn't match the uninterrupted engraving")
+		}
+		return
+	}
+	t.Error("needle wasn't parked during pause")
+}
+
+func TestTimeout(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps int
+		phase engrave.Phase
+	}{
+		{"init", 0, engrave.PhaseInit},
+		// Stall after the initial move and some program steps.
+		{"step", progBatchSize + 10, engrave.PhaseStep},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sim := NewSimulator()
+			defer sim.Close()
+			dev := &stalledDevice{dev: sim, steps: test.steps, closed: make(chan struct{})}
+			defer dev.Close()
+			prog, err := NewProgram(gridDesign)
+			if err != nil {
+				t.Fatal(err)
+			}
+			const timeout = 100 * time.Millisecond
+			prog.Timeouts = Timeouts{Init: timeout, Step: timeout}
+			err = Engrave(context.Background(), dev, prog, nil, nil)
+			var terr *engrave.TimeoutError
+			if !errors.As(err, &terr) {
+				t.Fatalf("stalled engraver returned %v", err)
+			}
+			if terr.Phase != test.phase || terr.Timeout != timeout {
+				t.Errorf("timed out in %v after %v, expected %v after %v", terr.Phase, terr.Timeout, test.phase, timeout)
+			}
+		})
+	}
+}
+
+func TestFaults(t *testing.T) {
+	// inProgram is a read during the engraving of the program, after
+	// initialization and homing.
+	const inProgram = 120
+	tests := []struct {
+		fault Fault
+		check func(err error) bool
+	}{
+		{Fault{Kind: FaultDrop, At: inProgram}, isError[*StepError]},
+		{Fault{Kind: FaultDuplicate, At: inProgram}, isError[*StepError]},
+		{Fault{Kind: FaultShortRead, At: 3}, isNil},
+		{Fault{Kind: FaultStatus, At: inProgram, Status: 0x42}, isNil},
+		{Fault{Kind: FaultStatus, At: 1, Status: 0x42}, isError[*engrave.ReplyError]},
+		{Fault{Kind: FaultCancel, At: inProgram}, func(err error) bool { return err == ErrCancelled }},
+		{Fault{Kind: FaultStall, At: inProgram}, isError[*engrave.TimeoutError]},
+		{Fault{Kind: FaultDisconnect, At: inProgram}, func(err error) bool { return errors.Is(err, errDisconnected) }},
+	}
+	for _, test := range tests {
+		t.Run(test.fault.String(), func(t *testing.T) {
+			prog, err := engraveFaulty(t, []Fault{test.fault})
+			if !test.check(err) {
+				t.Errorf("unexpected result: %v", err)
+			}
+			// Completed must be exact, because it determines the
+			// commands skipped when resuming.
+			if (err == nil || isError[*StepError](err)) && prog.Completed() != prog.Len() {
+				t.Errorf("completed %d of %d commands", prog.Completed(), prog.Len())
+			}
+		})
+	}
+}
+
+func TestRandomFaults(t *testing.T) {
+	for seed := int64(0); seed < 10; seed++ {
+		faults := RandomFaults(seed, 3, 400, 20*time.Millisecond)
+		// Any outcome is acceptable, as long as the engraving
+		// terminates.
+		engraveFaulty(t, faults)
+	}
+}
+
+func engraveFaulty(t *testing.T, faults []Fault) (*Program, error) {
+	t.Helper()
+	sim := NewSimulator()
+	defer sim.Close()
+	sim.Faults = faults
+	prog, err := NewProgram(gridDesign)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const timeout = 200 * time.Millisecond
+	prog.Timeouts = Timeouts{timeout, timeout, timeout, timeout, timeout}
+	errs := make(chan error, 1)
+	go func() {
+		errs <- Engrave(context.Background(), sim, prog, nil, nil)
+	}()
+	select {
+	case err := <-errs:
+		return prog, err
+	case <-time.After(10 * time.Second):
+		t.Fatalf("engraving with faults %v didn't terminate", faults)
+		return nil, nil
+	}
+}
+
+func isNil(err error) bool {
+	return err == nil
+}
+
+func isError[E error](err error) bool {
+	var target E
+	return errors.As(err, &target)
+}
+
+// stalledDevice stops replying after a number of program steps.
+type stalledDevice struct {
+	dev    io.ReadWriter
+	steps  int
+	seen   int
+	closed chan struct{}
+}
+
+func (d *stalledDevice) Read(p []byte) (int, error) {
+	if d.seen == d.steps {
+		<-d.closed
+		return 0, io.EOF
+	}
+	n, err := d.dev.Read(p)
+	if n == 1 && p[0] == programStepStatus {
+		d.seen++
+	}
+	return n, err
+}
+
+func (d *stalledDevice) Write(p []byte) (int, error) {
+	return d.dev.Write(p)
+}
+
+func (d *stalledDevice) Close() error {
+	close(d.closed)
+	return nil
+}
+
+// cancelDevice signals the first cancel command after initialization.
+type cancelDevice struct {
+	dev       io.ReadWriter
+	cancelled chan<- struct{}
+	n         int
+}
+
+func (d *cancelDevice) Read(p []byte) (int, error) {
+	return d.dev.Read(p)
+}
+
+func (d *cancelDevice) Write(p []byte) (int, error) {
+	if len(p) > 0 && p[0] == cancelCmd {
+		d.n++
+		if d.n == 2 {
+			close(d.cancelled)
+		}
+	}
+	return d.dev.Write(p)
+}
+
+// interruptedDevice fails after a number of program steps.
+type interruptedDevice struct {
+	dev   io.ReadWriter
+	steps int
+	seen  int
+}
+
+func (d *interruptedDevice) Read(p []byte) (int, error) {
+	if d.seen == d.steps {
+		return 0, errors.New("connection lost")
+	}
+	n, err := d.dev.Read(p)
+	if n == 1 && p[0] == programStepStatus {
+		d.seen++
+	}
+	return n, err
+}
+
+func (d *interruptedDevice) Write(p []byte) (int, error) {
+	return d.dev.Write(p)
+}
+
+func TestPasses(t *testing.T) {
+	qr := engrave.Offset(10, 10, engrave.QR(StrokeWidth, 1, qrcode.Low, []byte("passes")))
+	design := engrave.Commands{
+		engrave.CommandFunc(func(p engrave.Program) {
+			p.Move(f32.Vec2{1, 1})
+			p.Line(f32.Vec2{2, 1})
+		}),
+		qr,
+	}
+	lines := 0
+	qr.Engrave(lineCounter(func() { lines++ }))
+	prog, err := NewProgram(design)
+	if err != nil {
+		t.Fatal(err)
+	}
+	single := prog.Len()
+	prog.RegionPasses = map[engrave.Region]int{engrave.RegionQR: 3}
+	prog.PenDownDelay = 0x20
+	prog.PenUpDelay = 0x30
+	prog.AuxSpeed = 0x100
+	// Every extra pass adds a move and a line.
+	if got, want := prog.Len(), single+2*2*lines; got != want {
+		t.Errorf("program has %d commands, expected %d", got, want)
+	}
+	s := NewSimulator()
+	defer s.Close()
+	if err := Engrave(context.Background(), s, prog, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	engraved := 0
+	for _, c := range s.Cmds {
+		if c.Type == LineTo {
+			engraved++
+		}
+	}
+	if want := 1 + 3*lines; engraved != want {
+		t.Errorf("engraved %d lines, expected %d", engraved, want)
+	}
+	if want := [2]int{0x20, 0x30}; s.Delays != want {
+		t.Errorf("needle delays %#x, expected %#x", s.Delays, want)
+	}
+	if want := 0x100; s.Speeds[2] != want {
+		t.Errorf("third speed %#x, expected %#x", s.Speeds[2], want)
+	}
+}
+
+// lineCounter is a program that calls itself for every line.
+type lineCounter func()
+
+func (l lineCounter) Move(to f32.Vec2) {}
+func (l lineCounter) Line(to f32.Vec2) { l() }
+
+func TestRecordingSVG(t *testing.T) {
+	rec := Recording{
+		{MoveTo, 0, 0},
+		{MoveTo, 1000, 2000},
+		{LineTo, 3000, 2000},
+		{MoveTo, 0, 0},
+	}
+	var buf bytes.Buffer
+	if err := rec.WriteSVG(&buf, image.Rect(0, 0, 50, 50), StrokeWidth); err != nil {
+		t.Fatal(err)
+	}
+	const path = `d="M7.960 15.920L23.880 15.920"`
+	if !strings.Contains(buf.String(), path) {
+		t.Errorf("SVG output\n%s\ndoesn't contain %s", buf.String(), path)
+	}
+}
+
+func TestEngraver(t *testing.T) {
+	sim := NewSimulator()
+	e := NewEngraver(sim)
+	defer e.Close()
+	if err := e.Home(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := sim.Cmds[len(sim.Cmds)-1], (Cmd{MoveTo, 0, 0}); got != want {
+		t.Errorf("homed at %v, expected %v", got, want)
+	}
+	job := &engrave.Job{Design: gridDesign}
+	if err := e.Run(context.Background(), job); err != nil {
+		t.Fatal(err)
+	}
+	if want := 600; job.Completed != want {
+		t.Errorf("%d commands completed, expected %d", job.Completed, want)
+	}
+	out := &engrave.Job{Design: engrave.CommandFunc(func(p engrave.Program) {
+		p.Move(f32.Vec2{-10, 0})
+	})}
+	var berr *engrave.BoundsError
+	if err := e.Run(context.Background(), out); !errors.As(err, &berr) {
+		t.Errorf("out of bounds job returned %v", err)
+	}
+	// Inside the machine range, but beyond the travel of the engraver.
+	far := f32.Vec2{float32(workArea.Max.X) + 1, 10}
+	beyond := &engrave.Job{Design: engrave.CommandFunc(func(p engrave.Program) {
+		p.Move(f32.Vec2{10, 10})
+		p.Line(far)
+	})}
+	if err := e.Run(context.Background(), beyond); !errors.As(err, &berr) || berr.Point != far {
+		t.Errorf("job beyond the work area returned %v", err)
+	}
+	if beyond.Completed != 0 {
+		t.Errorf("%d commands of a job beyond the work area completed", beyond.Completed)
+	}
+}
+
+func TestEngraverStalled(t *testing.T) {
+	sim := NewSimulator()
+	defer sim.Close()
+	dev := &stalledDevice{dev: sim, steps: progBatchSize + 10, closed: make(chan struct{})}
+	e := NewEngraver(dev)
+	const timeout = 100 * time.Millisecond
+	e.Timeouts = Timeouts{Init: timeout, Step: timeout}
+	var terr *engrave.TimeoutError
+	if err := e.Run(context.Background(), &engrave.Job{Design: gridDesign}); !errors.As(err, &terr) {
+		t.Fatalf("stalled engraver returned %v", err)
+	}
+	select {
+	case <-dev.closed:
+	default:
+		t.Error("stalled engraver wasn't closed")
+	}
+	if err := e.Home(context.Background()); !errors.As(err, &terr) {
+		t.Errorf("homing a stalled engraver returned %v", err)
+	}
+	if _, err := e.Position(context.Background()); !errors.As(err, &terr) {
+		t.Errorf("querying a stalled engraver returned %v", err)
+	}
+	if _, err := e.Jog(context.Background(), f32.Vec2{10, 10}); !errors.As(err, &terr) {
+		t.Errorf("jogging a stalled engraver returned %v", err)
+	}
+	if err := e.Run(context.Background(), &engrave.Job{Design: gridDesign}); !errors.As(err, &terr) {
+		t.Errorf("running a stalled engraver returned %v", err)
+	}
+	if err := e.Close(); err != nil {
+		t.Errorf("closing a stalled engraver: %v", err)
+	}
+}
+
+func TestJog(t *testing.T) {
+	sim := NewSimulator()
+	e := NewEngraver(sim)
+	defer e.Close()
+	e.Record()
+	trace := func() string {
+		t.Helper()
+		buf := new(bytes.Buffer)
+		if err := e.Trace(buf); err != nil {
+			t.Fatal(err)
+		}
+		return buf.String()
+	}
+	// The first jog homes the engraver.
+	to := f32.Vec2{20, 30}
+	pos, err := e.Jog(context.Background(), to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := affine.Sub(pos, to); abs32(d[0]) > stepSize || abs32(d[1]) > stepSize {
+		t.Errorf("jogged to %v, expected %v", pos, to)
+	}
+	homed := trace()
+	for _, want := range []string{"> init", "> move to origin", "> query position", "< position (20."} {
+		if !strings.Contains(homed, want) {
+			t.Errorf("trace\n%s\ndoesn't contain %q", homed, want)
+		}
+	}
+	// Later jogs and queries only move and query.
+	to = f32.Vec2{25, 30}
+	pos, err = e.Jog(context.Background(), to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := affine.Sub(pos, to); abs32(d[0]) > stepSize || abs32(d[1]) > stepSize {
+		t.Errorf("jogged to %v, expected %v", pos, to)
+	}
+	if got, err := e.Position(context.Background()); err != nil || got != pos {
+		t.Errorf("queried position %v (%v), expected %v", got, err, pos)
+	}
+	jogged := trace()
+	for _, cmd := range []string{"> cancel", "> init", "> set speeds", "> set delays", "> move to origin"} {
+		if strings.Count(jogged, cmd) != strings.Count(homed, cmd) {
+			t.Errorf("jog sent %q:\n%s", cmd, jogged)
+		}
+	}
+	var berr *engrave.BoundsError
+	if _, err := e.Jog(context.Background(), f32.Vec2{-1, 0}); !errors.As(err, &berr) {
+		t.Errorf("jog outside the work area returned %v", err)
+	}
+}
+
+func abs32(v float32) float32 {
+	if v < 0 {
+		return -v
 	}
+	return v
 }
@@ -0,0 +1,184 @@
+package mjolnir
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+
+	"golang.org/x/image/math/f32"
+	"seedhammer.com/engrave"
+)
+
+// workArea is the reachable area of the engraver, in millimeters.
+var workArea = image.Rect(0, 0, 182, 134)
+
+// Engraver implements engrave.Engraver for a connected engraver.
+type Engraver struct {
+	dev io.ReadWriteCloser
+	// Timeouts bounds the waits for the engraver.
+	Timeouts Timeouts
+	// homed is set if the last operation left the engraver initialized
+	// and homed, so Position and Jog don't repeat that.
+	homed bool
+	// stalled is set when an operation timed out. Its read may still
+	// be pending and would take the replies meant for later operations,
+	// so the device is closed and every later operation fails.
+	stalled  error
+	closeErr error
+}
+
+var _ engrave.Diagnoser = (*Engraver)(nil)
+
+// NewEngraver returns an Engraver for the engraver connected to dev, such
+// as a device returned by Open or a Simulator.
+func NewEngraver(dev io.ReadWriteCloser) *Engraver {
+	return &Engraver{dev: dev}
+}
+
+func (e *Engraver) Capabilities() engrave.Capabilities {
+	return engrave.Capabilities{
+		WorkArea:    workArea,
+		StrokeWidth: StrokeWidth,
+		Pause:       true,
+		Resume:      true,
+	}
+}
+
+// check records a timeout returned by an operation on the device.
+func (e *Engraver) check(err error) error {
+	var terr *engrave.TimeoutError
+	if errors.As(err, &terr) && e.stalled == nil {
+		e.stalled = fmt.Errorf("mjolnir: engraver stalled: %w", err)
+		e.closeErr = e.dev.Close()
+	}
+	return err
+}
+
+func (e *Engraver) Home(ctx context.Context) error {
+	if e.stalled != nil {
+		return e.stalled
+	}
+	err := e.check(Home(ctx, e.dev, e.Timeouts))
+	e.homed = err == nil
+	return err
+}
+
+func (e *Engraver) Run(ctx context.Context, job *engrave.Job) error {
+	if e.stalled != nil {
+		return e.stalled
+	}
+	e.homed = false
+	prog, err := NewProgram(job.Design)
+	if err != nil {
+		return err
+	}
+	area := engrave.RectOf(workArea)
+	v := &validator{area: &area}
+	job.Design.Engrave(v)
+	if v.err != nil {
+		return v.err
+	}
+	prog.DryRun = job.DryRun
+	prog.Skip = job.Skip
+	prog.Timeouts = e.Timeouts
+	prog.Paused = job.Paused
+	var control chan Control
+	if job.Pause != nil {
+		control = make(chan Control)
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			for {
+				select {
+				case p := <-job.Pause:
+					c := Resume
+					if p {
+						c = Pause
+					}
+					select {
+					case control <- c:
+					case <-done:
+						return
+					}
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+	err = e.check(Engrave(ctx, e.dev, prog, job.Progress, control))
+	job.Completed = prog.Completed()
+	// A completed engraving homes the engraver and leaves it
+	// initialized.
+	e.homed = err == nil
+	return err
+}
+
+// Position returns the position of the needle, in millimeters.
+func (e *Engraver) Position(ctx context.Context) (f32.Vec2, error) {
+	if e.stalled != nil {
+		return f32.Vec2{}, e.stalled
+	}
+	mode := runQuery
+	if e.homed {
+		mode = runPosition
+	}
+	pos, err := queryPos(ctx, e.dev, e.Timeouts, mode)
+	err = e.check(err)
+	e.homed = e.homed && err == nil
+	return pos, err
+}
+
+// Jog moves the needle to p without engraving, and returns the position
+// reported by the engraver. The engraver is homed first, unless a
+// previous operation left it homed, so a sequence of jogs sends only
+// moves and position queries. Jog returns an *engrave.BoundsError if p
+// is outside the work area.
+func (e *Engraver) Jog(ctx context.Context, p f32.Vec2) (f32.Vec2, error) {
+	if e.stalled != nil {
+		return f32.Vec2{}, e.stalled
+	}
+	area := engrave.RectOf(workArea)
+	v := &validator{area: &area}
+	v.Move(p)
+	if v.err != nil {
+		return f32.Vec2{}, v.err
+	}
+	if !e.homed {
+		if err := e.Home(ctx); err != nil {
+			return f32.Vec2{}, err
+		}
+	}
+	pos, err := Jog(ctx, e.dev, p, e.Timeouts)
+	err = e.check(err)
+	e.homed = err == nil
+	return pos, err
+}
+
+// Record starts recording the communication with the engraver, for
+// diagnosing it. It must not be called concurrently with other methods.
+func (e *Engraver) Record() {
+	if _, ok := e.dev.(*TraceRecorder); ok {
+		return
+	}
+	e.dev = NewTraceRecorder(e.dev, false)
+}
+
+// Trace writes the communication recorded since Record was called.
+func (e *Engraver) Trace(w io.Writer) error {
+	r, ok := e.dev.(*TraceRecorder)
+	if !ok {
+		return errors.New("mjolnir: engraver is not recording")
+	}
+	return FormatTrace(w, r.Trace())
+}
+
+func (e *Engraver) Close() error {
+	if e.stalled != nil {
+		// The device was closed when the engraver stalled.
+		return e.closeErr
+	}
+	return e.dev.Close()
+}
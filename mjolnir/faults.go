@@ -0,0 +1,144 @@
+package mjolnir
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// Fault is a fault injected by a Simulator.
+type Fault struct {
+	Kind FaultKind
+	// At is the number of reads from the simulator before the fault.
+	At int
+	// Status is the status byte sent by FaultStatus.
+	Status byte
+	// Duration is the length of a FaultStall. A zero duration stalls
+	// until the simulator is closed.
+	Duration time.Duration
+}
+
+type FaultKind int
+
+const (
+	// FaultDrop drops the first byte of a reply.
+	FaultDrop FaultKind = iota
+	// FaultDuplicate repeats the first byte of a reply.
+	FaultDuplicate
+	// FaultStatus sends an unexpected status byte.
+	FaultStatus
+	// FaultCancel cancels the running program and sends the cancelled
+	// status.
+	FaultCancel
+	// FaultStall delays a reply.
+	FaultStall
+	// FaultShortRead returns at most one byte of a reply.
+	FaultShortRead
+	// FaultDisconnect fails every read and write from then on.
+	FaultDisconnect
+)
+
+var faultKinds = []FaultKind{
+	FaultDrop, FaultDuplicate, FaultStatus, FaultCancel, FaultStall, FaultShortRead, FaultDisconnect,
+}
+
+func (k FaultKind) String() string {
+	switch k {
+	case FaultDrop:
+		return "drop"
+	case FaultDuplicate:
+		return "duplicate"
+	case FaultStatus:
+		return "status"
+	case FaultCancel:
+		return "cancel"
+	case FaultStall:
+		return "stall"
+	case FaultShortRead:
+		return "short read"
+	case FaultDisconnect:
+		return "disconnect"
+	}
+	return fmt.Sprintf("FaultKind(%d)", int(k))
+}
+
+func (f Fault) String() string {
+	return fmt.Sprintf("%s at read %d", f.Kind, f.At)
+}
+
+var (
+	errDisconnected = errors.New("mjolnir: simulated disconnect")
+	errClosed       = errors.New("mjolnir: simulator closed")
+)
+
+// RandomFaults returns a script of n faults, deterministically generated
+// from seed, spread over the first reads from a simulator. Stalls last
+// at most maxStall.
+func RandomFaults(seed int64, n, reads int, maxStall time.Duration) []Fault {
+	r := rand.New(rand.NewSource(seed))
+	faults := make([]Fault, n)
+	for i := range faults {
+		f := Fault{
+			Kind: faultKinds[r.Intn(len(faultKinds))],
+			At:   r.Intn(reads),
+		}
+		switch f.Kind {
+		case FaultStatus:
+			f.Status = byte(r.Intn(0x100))
+		case FaultStall:
+			f.Duration = time.Duration(r.Int63n(int64(maxStall))) + 1
+		}
+		faults[i] = f
+	}
+	sort.SliceStable(faults, func(i, j int) bool {
+		return faults[i].At < faults[j].At
+	})
+	return faults
+}
+
+// faultyReply returns the next reply, altered by fault if not nil.
+func (s *Simulator) faultyReply(fault *Fault) ([]byte, error) {
+	if fault == nil {
+		return s.reply()
+	}
+	switch fault.Kind {
+	case FaultDisconnect:
+		s.disconnected = true
+		return nil, errDisconnected
+	case FaultStall:
+		var timeout <-chan time.Time
+		if d := fault.Duration; d > 0 {
+			t := time.NewTimer(d)
+			defer t.Stop()
+			timeout = t.C
+		}
+		select {
+		case <-timeout:
+		case <-s.close:
+			return nil, errClosed
+		}
+	case FaultStatus:
+		return []byte{fault.Status}, nil
+	case FaultCancel:
+		if s.state == stateExecuting {
+			s.state = stateReady
+		}
+		return []byte{cancelledStatus}, nil
+	}
+	resp, err := s.reply()
+	if err != nil {
+		return nil, err
+	}
+	switch fault.Kind {
+	case FaultDrop:
+		resp = resp[1:]
+		if len(resp) == 0 {
+			return s.reply()
+		}
+	case FaultDuplicate:
+		resp = append([]byte{resp[0]}, resp...)
+	}
+	return resp, nil
+}
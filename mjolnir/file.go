@@ -0,0 +1,196 @@
+package mjolnir
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"io"
+
+	"golang.org/x/image/math/f32"
+)
+
+// File is a saved engraver program, for replaying exactly what was sent
+// to an engraver.
+//
+// The encoding is little endian: a fixed size header, the commands as a
+// type byte followed by 24-bit machine step coordinates, and a CRC-32
+// (IEEE) checksum of everything before it.
+type File struct {
+	// Plate is the plate area, in millimeters.
+	Plate       image.Rectangle
+	StrokeWidth float32
+	MoveSpeed   float32
+	PrintSpeed  float32
+	AuxSpeed    int
+	// PenDownDelay and PenUpDelay are the needle delays.
+	PenDownDelay int
+	PenUpDelay   int
+	End          f32.Vec2
+	// Cmds are the program commands, in machine steps.
+	Cmds Recording
+}
+
+// FileVersion is the version of the program files written by File.Encode.
+const FileVersion = 1
+
+var fileMagic = [4]byte{'S', 'H', 'P', 'F'}
+
+type fileHeader struct {
+	Magic        [4]byte
+	Version      uint16
+	Plate        [4]int16
+	StrokeWidth  float32
+	MoveSpeed    float32
+	PrintSpeed   float32
+	AuxSpeed     uint16
+	PenDownDelay uint8
+	PenUpDelay   uint8
+	End          [2]float32
+	Count        uint32
+}
+
+const fileCmdSize = 7
+
+// ErrChecksum is returned by DecodeFile for corrupted files.
+var ErrChecksum = errors.New("mjolnir: program file checksum mismatch")
+
+// NewFile records a program engraving a plate.
+func NewFile(prog *Program, plate image.Rectangle) (*File, error) {
+	rec, err := prog.Recording()
+	if err != nil {
+		return nil, err
+	}
+	return &File{
+		Plate:        plate,
+		StrokeWidth:  StrokeWidth,
+		MoveSpeed:    prog.MoveSpeed,
+		PrintSpeed:   prog.PrintSpeed,
+		AuxSpeed:     prog.AuxSpeed,
+		PenDownDelay: prog.PenDownDelay,
+		PenUpDelay:   prog.PenUpDelay,
+		End:          prog.End,
+		Cmds:         rec,
+	}, nil
+}
+
+// Program returns a program for replaying the file.
+func (f *File) Program() (*Program, error) {
+	prog, err := NewProgram(f.Cmds)
+	if err != nil {
+		return nil, err
+	}
+	prog.MoveSpeed = f.MoveSpeed
+	prog.PrintSpeed = f.PrintSpeed
+	prog.AuxSpeed = f.AuxSpeed
+	prog.PenDownDelay = f.PenDownDelay
+	prog.PenUpDelay = f.PenUpDelay
+	prog.End = f.End
+	return prog, nil
+}
+
+// Encode writes the file to w.
+func (f *File) Encode(w io.Writer) error {
+	if f.AuxSpeed < 0 || f.AuxSpeed > 0xffff || f.PenDownDelay < 0 || f.PenDownDelay > 0xff || f.PenUpDelay < 0 || f.PenUpDelay > 0xff {
+		return errors.New("mjolnir: program settings out of range")
+	}
+	h := fileHeader{
+		Magic:   fileMagic,
+		Version: FileVersion,
+		Plate: [4]int16{
+			int16(f.Plate.Min.X), int16(f.Plate.Min.Y),
+			int16(f.Plate.Max.X), int16(f.Plate.Max.Y),
+		},
+		StrokeWidth:  f.StrokeWidth,
+		MoveSpeed:    f.MoveSpeed,
+		PrintSpeed:   f.PrintSpeed,
+		AuxSpeed:     uint16(f.AuxSpeed),
+		PenDownDelay: uint8(f.PenDownDelay),
+		PenUpDelay:   uint8(f.PenUpDelay),
+		End:          f.End,
+		Count:        uint32(len(f.Cmds)),
+	}
+	crc := crc32.NewIEEE()
+	bw := bufio.NewWriter(io.MultiWriter(w, crc))
+	if err := binary.Write(bw, binary.LittleEndian, &h); err != nil {
+		return err
+	}
+	for _, c := range f.Cmds {
+		if c.X > 0xffffff || c.Y > 0xffffff {
+			return fmt.Errorf("mjolnir: command %v out of range", c)
+		}
+		op := byte(0)
+		if c.Type == LineTo {
+			op = 1
+		}
+		bw.Write([]byte{
+			op,
+			byte(c.X), byte(c.X >> 8), byte(c.X >> 16),
+			byte(c.Y), byte(c.Y >> 8), byte(c.Y >> 16),
+		})
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	return binary.Write(w, binary.LittleEndian, crc.Sum32())
+}
+
+// DecodeFile reads a program file. It returns ErrChecksum if the file is
+// corrupted.
+func DecodeFile(r io.Reader) (*File, error) {
+	crc := crc32.NewIEEE()
+	br := bufio.NewReader(r)
+	tr := io.TeeReader(br, crc)
+	var h fileHeader
+	if err := binary.Read(tr, binary.LittleEndian, &h); err != nil {
+		return nil, fmt.Errorf("mjolnir: program file header: %w", err)
+	}
+	if h.Magic != fileMagic {
+		return nil, errors.New("mjolnir: not a program file")
+	}
+	if h.Version != FileVersion {
+		return nil, fmt.Errorf("mjolnir: unsupported program file version %d", h.Version)
+	}
+	f := &File{
+		Plate:        image.Rect(int(h.Plate[0]), int(h.Plate[1]), int(h.Plate[2]), int(h.Plate[3])),
+		StrokeWidth:  h.StrokeWidth,
+		MoveSpeed:    h.MoveSpeed,
+		PrintSpeed:   h.PrintSpeed,
+		AuxSpeed:     int(h.AuxSpeed),
+		PenDownDelay: int(h.PenDownDelay),
+		PenUpDelay:   int(h.PenUpDelay),
+		End:          h.End,
+	}
+	var buf [fileCmdSize]byte
+	for i := uint32(0); i < h.Count; i++ {
+		if _, err := io.ReadFull(tr, buf[:]); err != nil {
+			return nil, fmt.Errorf("mjolnir: program file commands: %w", err)
+		}
+		var c Cmd
+		switch buf[0] {
+		case 0:
+			c.Type = MoveTo
+		case 1:
+			c.Type = LineTo
+		default:
+			return nil, fmt.Errorf("mjolnir: invalid program file command %#x", buf[0])
+		}
+		c.X = uint32(buf[1]) | uint32(buf[2])<<8 | uint32(buf[3])<<16
+		c.Y = uint32(buf[4]) | uint32(buf[5])<<8 | uint32(buf[6])<<16
+		f.Cmds = append(f.Cmds, c)
+	}
+	sum := crc.Sum32()
+	var want uint32
+	if err := binary.Read(br, binary.LittleEndian, &want); err != nil {
+		return nil, fmt.Errorf("mjolnir: program file checksum: %w", err)
+	}
+	if sum != want {
+		return nil, ErrChecksum
+	}
+	if _, err := br.ReadByte(); err != io.EOF {
+		return nil, errors.New("mjolnir: trailing data after program file")
+	}
+	return f, nil
+}
@@ -0,0 +1,91 @@
+package mjolnir
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"reflect"
+	"testing"
+
+	"golang.org/x/image/math/f32"
+	"seedhammer.com/engrave"
+)
+
+func TestFileReplay(t *testing.T) {
+	prog, err := NewProgram(gridDesign)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prog.Passes = 2
+	prog.PenDownDelay = 0x30
+	prog.End = f32.Vec2{5, 5}
+	plate := image.Rect(97, 0, 182, 55)
+	f, err := NewFile(prog, plate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := new(bytes.Buffer)
+	if err := f.Encode(buf); err != nil {
+		t.Fatal(err)
+	}
+	f2, err := DecodeFile(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(f, f2) {
+		t.Fatal("decoded file doesn't match the encoded file")
+	}
+	replay, err := f2.Program()
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig := NewSimulator()
+	defer orig.Close()
+	if err := Engrave(context.Background(), orig, prog, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	replayed := NewSimulator()
+	defer replayed.Close()
+	if err := Engrave(context.Background(), replayed, replay, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(orig.Cmds, replayed.Cmds) {
+		t.Error("replayed commands don't match the original program")
+	}
+	if orig.Delays != replayed.Delays || orig.Speeds != replayed.Speeds {
+		t.Error("replayed settings don't match the original program")
+	}
+}
+
+func TestFileCorrupt(t *testing.T) {
+	prog, err := NewProgram(engrave.CommandFunc(func(p engrave.Program) {
+		p.Move(f32.Vec2{1, 2})
+		p.Line(f32.Vec2{3, 4})
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := NewFile(prog, image.Rect(0, 0, 10, 10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := new(bytes.Buffer)
+	if err := f.Encode(buf); err != nil {
+		t.Fatal(err)
+	}
+	enc := buf.Bytes()
+	corrupt := append([]byte(nil), enc...)
+	corrupt[len(corrupt)-5] ^= 0x01
+	if _, err := DecodeFile(bytes.NewReader(corrupt)); !errors.Is(err, ErrChecksum) {
+		t.Errorf("corrupted file decoded with error %v, expected %v", err, ErrChecksum)
+	}
+	version := append([]byte(nil), enc...)
+	version[4] = FileVersion + 1
+	if _, err := DecodeFile(bytes.NewReader(version)); err == nil {
+		t.Error("file with unknown version decoded")
+	}
+	if _, err := DecodeFile(bytes.NewReader(enc[:len(enc)-1])); err == nil {
+		t.Error("truncated file decoded")
+	}
+}
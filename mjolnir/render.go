@@ -0,0 +1,68 @@
+package mjolnir
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"io"
+
+	"golang.org/x/image/math/f32"
+	"seedhammer.com/engrave"
+)
+
+// Recording is a sequence of simulator commands, in machine steps.
+type Recording []Cmd
+
+// Engrave replays the recording in millimeters.
+func (r Recording) Engrave(p engrave.Program) {
+	for _, c := range r {
+		pt := f32.Vec2{float32(c.X) * stepSize, float32(c.Y) * stepSize}
+		switch c.Type {
+		case MoveTo:
+			p.Move(pt)
+		case LineTo:
+			p.Line(pt)
+		}
+	}
+}
+
+// Render rasterizes the recording at ppmm pixels per millimeter to a
+// mask covering bounds, in millimeters.
+func (r Recording) Render(bounds image.Rectangle, strokeWidth float32, ppmm int) *image.Alpha {
+	bounds = image.Rectangle{
+		Min: bounds.Min.Mul(ppmm),
+		Max: bounds.Max.Mul(ppmm),
+	}
+	img := image.NewAlpha(bounds)
+	rast := engrave.NewRasterizer(img, bounds, strokeWidth*float32(ppmm))
+	engrave.Scale(float32(ppmm), float32(ppmm), r).Engrave(rast)
+	rast.Rasterize()
+	return img
+}
+
+// WriteSVG writes the recording as an SVG document covering bounds, in
+// millimeters.
+func (r Recording) WriteSVG(w io.Writer, bounds image.Rectangle, strokeWidth float32) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, `<svg xmlns="http://www.w3.org/2000/svg" width="%dmm" height="%dmm" viewBox="%d %d %d %d">`+"\n",
+		bounds.Dx(), bounds.Dy(), bounds.Min.X, bounds.Min.Y, bounds.Dx(), bounds.Dy())
+	fmt.Fprintf(bw, `<path fill="none" stroke="black" stroke-width="%g" stroke-linecap="round" stroke-linejoin="round" d="`, strokeWidth)
+	// Only emit moves that start lines.
+	var pen Cmd
+	moved := true
+	for _, c := range r {
+		switch c.Type {
+		case MoveTo:
+			pen = c
+			moved = true
+		case LineTo:
+			if moved {
+				fmt.Fprintf(bw, "M%.3f %.3f", float32(pen.X)*stepSize, float32(pen.Y)*stepSize)
+				moved = false
+			}
+			fmt.Fprintf(bw, "L%.3f %.3f", float32(c.X)*stepSize, float32(c.Y)*stepSize)
+		}
+	}
+	fmt.Fprint(bw, "\"/>\n</svg>\n")
+	return bw.Flush()
+}
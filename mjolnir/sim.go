@@ -6,9 +6,32 @@ import (
 )
 
 type Simulator struct {
-	state     deviceState
-	ncmds     int
-	nbuffered int
+	state deviceState
+	ncmds int
+	// buffered are the program commands waiting to execute, with nil
+	// for padding.
+	buffered []*Cmd
+	// requested is set when the next batch is requested early.
+	requested bool
+	// x and y is the needle position.
+	x, y uint32
+	// pending is the unread part of the latest reply.
+	pending      []byte
+	reads        int
+	disconnected bool
+
+	// Faults is the script of faults to inject, ordered by Fault.At.
+	// It must be set before the first Read.
+	Faults []Fault
+
+	// EarlyBatch requests the next batch of program commands when the
+	// last buffered command starts executing, instead of after it
+	// completes.
+	EarlyBatch bool
+
+	// Speeds and Delays are the latest speed and needle delay settings.
+	Speeds [3]int
+	Delays [2]int
 
 	Cmds  []Cmd
 	close chan struct{}
@@ -47,6 +70,8 @@ const (
 	stateSetDelays
 	stateMoveToOrigin
 	stateExecuting
+	stateQueryPos
+	stateCancelled
 )
 
 type ioRequest struct {
@@ -84,43 +109,82 @@ func coordsFromCmd(cmd []byte) (uint32, uint32) {
 }
 
 func (s *Simulator) doRead(data []byte) (int, error) {
-	read := func(resp []byte) (int, error) {
-		if len(resp) > len(data) {
-			return 0, errors.New("read overflow")
+	if s.disconnected {
+		return 0, errDisconnected
+	}
+	var fault *Fault
+	if len(s.Faults) > 0 && s.Faults[0].At <= s.reads {
+		fault = &s.Faults[0]
+		s.Faults = s.Faults[1:]
+	}
+	s.reads++
+	if len(s.pending) == 0 {
+		resp, err := s.faultyReply(fault)
+		if err != nil {
+			return 0, err
 		}
-		copy(data, resp)
-		return len(resp), nil
+		s.pending = resp
+	}
+	if fault != nil && fault.Kind == FaultShortRead && len(data) > 1 {
+		data = data[:1]
 	}
+	n := copy(data, s.pending)
+	s.pending = s.pending[n:]
+	return n, nil
+}
+
+func (s *Simulator) reply() ([]byte, error) {
 	switch s.state {
 	case stateInitializing:
 		s.state = stateReady
-		return read([]byte{initializedStatus})
+		return []byte{initializedStatus}, nil
 	case stateSetSpeed:
 		s.state = stateReady
-		return read([]byte{setSpeedCmd})
+		return []byte{setSpeedCmd}, nil
 	case stateSetDelays:
 		s.state = stateReady
-		return read([]byte{setDelaysCmd})
+		return []byte{setDelaysCmd}, nil
 	case stateMoveToOrigin:
 		s.state = stateReady
-		return read([]byte{moveToOriginCmd, moveToOriginCmdResponse})
+		return []byte{moveToOriginCmd, moveToOriginCmdResponse}, nil
+	case stateCancelled:
+		s.state = stateReady
+		return []byte{cancelledStatus}, nil
+	case stateQueryPos:
+		s.state = stateReady
+		return []byte{
+			queryPosCmd,
+			byte(s.x), byte(s.x >> 8), byte(s.x >> 16),
+			byte(s.y), byte(s.y >> 8), byte(s.y >> 16),
+			0, 0, 0,
+		}, nil
 	case stateExecuting:
 		switch {
-		case s.nbuffered == 0 && s.ncmds > 0:
-			return read([]byte{bufferProgramStatus})
-		case s.nbuffered == 0 && s.ncmds == 0:
-			return read([]byte{programCompleteStatus})
+		case s.EarlyBatch && len(s.buffered) == 1 && s.ncmds > 0 && !s.requested:
+			s.requested = true
+			return []byte{bufferProgramStatus}, nil
+		case len(s.buffered) == 0 && s.ncmds > 0:
+			return []byte{bufferProgramStatus}, nil
+		case len(s.buffered) == 0 && s.ncmds == 0:
+			return []byte{programCompleteStatus}, nil
 		default:
 			time.Sleep(400 * time.Microsecond)
-			s.nbuffered--
-			return read([]byte{programStepStatus})
+			if c := s.buffered[0]; c != nil {
+				s.Cmds = append(s.Cmds, *c)
+				s.x, s.y = c.X, c.Y
+			}
+			s.buffered = s.buffered[1:]
+			return []byte{programStepStatus}, nil
 		}
 	default:
-		return 0, errors.New("invalid device state")
+		return nil, errors.New("invalid device state")
 	}
 }
 
 func (s *Simulator) doWrite(data []byte) (n int, err error) {
+	if s.disconnected {
+		return 0, errDisconnected
+	}
 	skip := func(bytes int) {
 		if len(data) < bytes {
 			err = errors.New("buffer underflow")
@@ -135,8 +199,10 @@ func (s *Simulator) doWrite(data []byte) (n int, err error) {
 		skip(bytes)
 		return res
 	}
-	batchCmd := func() {
-		s.nbuffered++
+	// batchCmd buffers a program command, or padding if c is nil.
+	batchCmd := func(c *Cmd) {
+		s.buffered = append(s.buffered, c)
+		s.requested = false
 		s.ncmds--
 		skip(9)
 	}
@@ -146,22 +212,31 @@ func (s *Simulator) doWrite(data []byte) (n int, err error) {
 		data = data[1:]
 		switch cmd {
 		case cancelCmd:
-			s.state = stateReady
+			if s.state == stateExecuting {
+				s.state = stateCancelled
+				// Drop the commands not yet executed.
+				s.buffered = nil
+			} else {
+				s.state = stateReady
+			}
 		case initCmd:
 			if s.state == stateExecuting {
 				// 0x00 is line to in programming mode.
 				x, y := coordsFromCmd(data)
-				s.Cmds = append(s.Cmds, Cmd{LineTo, x, y})
-				batchCmd()
+				batchCmd(&Cmd{LineTo, x, y})
 			} else {
 				s.state = stateInitializing
 			}
 		case setSpeedCmd:
 			s.state = stateSetSpeed
-			skip(6)
+			v := read(6)
+			for i := range s.Speeds {
+				s.Speeds[i] = int(v[i*2]) | int(v[i*2+1])<<8
+			}
 		case setDelaysCmd:
 			s.state = stateSetDelays
-			skip(2)
+			v := read(2)
+			s.Delays = [2]int{int(v[0]), int(v[1])}
 		case moveToOriginCmd:
 			s.state = stateMoveToOrigin
 			subCmd := read(1)
@@ -169,16 +244,18 @@ func (s *Simulator) doWrite(data []byte) (n int, err error) {
 				err = errors.New("invalid origin command")
 			}
 			s.Cmds = append(s.Cmds, Cmd{MoveTo, 0, 0})
+			s.x, s.y = 0, 0
+		case queryPosCmd:
+			s.state = stateQueryPos
 		case initProgramCmd:
 			s.state = stateExecuting
 			ncmds := read(2)
 			s.ncmds = (int(ncmds[0]) | int(ncmds[1])<<8) * progBatchSize
 		case moveCmd:
 			x, y := coordsFromCmd(data)
-			s.Cmds = append(s.Cmds, Cmd{MoveTo, x, y})
-			batchCmd()
+			batchCmd(&Cmd{MoveTo, x, y})
 		case nopCmd:
-			batchCmd()
+			batchCmd(nil)
 		default:
 			return n, errors.New("invalid command")
 		}
@@ -187,13 +264,19 @@ func (s *Simulator) doWrite(data []byte) (n int, err error) {
 }
 
 func (s *Simulator) Read(data []byte) (int, error) {
-	s.in <- ioRequest{false, data}
-	r := <-s.out
-	return r.bytes, r.err
+	return s.do(ioRequest{false, data})
 }
 
 func (s *Simulator) Write(data []byte) (int, error) {
-	s.in <- ioRequest{true, data}
+	return s.do(ioRequest{true, data})
+}
+
+func (s *Simulator) do(req ioRequest) (int, error) {
+	select {
+	case s.in <- req:
+	case <-s.close:
+		return 0, errClosed
+	}
 	r := <-s.out
 	return r.bytes, r.err
 }
@@ -0,0 +1,733 @@
+package mjolnir
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/image/math/f32"
+)
+
+// Trace is a recording of the communication with an engraver.
+type Trace struct {
+	// Redacted is set if the program commands and the timing of
+	// program steps were removed from the trace.
+	Redacted bool
+	// Truncated is set if the earliest events were dropped from the
+	// trace.
+	Truncated bool
+	Events    []TraceEvent
+}
+
+// TraceEvent is a transfer to or from an engraver.
+type TraceEvent struct {
+	// Time is the time since the start of the trace.
+	Time time.Duration
+	Dir  TraceDir
+	// Data is the transferred data, or the error message of a
+	// TraceError event.
+	Data []byte
+}
+
+// TraceDir is the direction of a transfer.
+type TraceDir byte
+
+const (
+	TraceWrite TraceDir = 'w'
+	TraceRead  TraceDir = 'r'
+	// TraceError is a failed read or write.
+	TraceError TraceDir = 'e'
+)
+
+const (
+	traceHeader    = "# seedhammer engraver trace v1"
+	traceRedacted  = "# redacted"
+	traceTruncated = "# truncated"
+)
+
+// Encode writes the trace in its text format: a header followed by a
+// line for every event with its time in microseconds, its direction and
+// its data in hex.
+func (t *Trace) Encode(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, traceHeader)
+	if t.Redacted {
+		fmt.Fprintln(bw, traceRedacted)
+	}
+	if t.Truncated {
+		fmt.Fprintln(bw, traceTruncated)
+	}
+	for _, e := range t.Events {
+		fmt.Fprintf(bw, "%d %c %x\n", e.Time.Microseconds(), e.Dir, e.Data)
+	}
+	return bw.Flush()
+}
+
+// DecodeTrace reads a trace in the format written by Trace.Encode.
+func DecodeTrace(r io.Reader) (*Trace, error) {
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1<<20)
+	if !s.Scan() || s.Text() != traceHeader {
+		if err := s.Err(); err != nil {
+			return nil, err
+		}
+		return nil, errors.New("mjolnir: not a trace")
+	}
+	t := new(Trace)
+	line := 1
+	for s.Scan() {
+		line++
+		l := s.Text()
+		switch l {
+		case traceRedacted:
+			t.Redacted = true
+			continue
+		case traceTruncated:
+			t.Truncated = true
+			continue
+		}
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+		fields := strings.Fields(l)
+		if len(fields) != 3 || len(fields[1]) != 1 {
+			return nil, fmt.Errorf("mjolnir: trace line %d: invalid event", line)
+		}
+		us, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("mjolnir: trace line %d: %w", line, err)
+		}
+		dir := TraceDir(fields[1][0])
+		if dir != TraceWrite && dir != TraceRead && dir != TraceError {
+			return nil, fmt.Errorf("mjolnir: trace line %d: invalid direction %q", line, dir)
+		}
+		data, err := hex.DecodeString(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("mjolnir: trace line %d: %w", line, err)
+		}
+		t.Events = append(t.Events, TraceEvent{
+			Time: time.Duration(us) * time.Microsecond,
+			Dir:  dir,
+			Data: data,
+		})
+	}
+	return t, s.Err()
+}
+
+// TraceRecorder records the communication with an engraver.
+type TraceRecorder struct {
+	// Limit, if positive, is the number of events to keep. Older
+	// events are dropped.
+	Limit int
+
+	dev io.ReadWriteCloser
+
+	mu    sync.Mutex
+	start time.Time
+	trace Trace
+	// next is the index of the oldest event, once the number of
+	// events reached Limit.
+	next   int
+	redact *writeDecoder
+	// program is set while a program runs, and programTime is the
+	// time it started. Only tracked when redacting.
+	program     bool
+	programTime time.Duration
+}
+
+// NewTraceRecorder returns a device that records the communication with
+// dev. If redact is set, program commands are zeroed and program steps
+// are recorded at the time their program started, so traces reveal
+// neither the engraved strokes nor their timing.
+func NewTraceRecorder(dev io.ReadWriteCloser, redact bool) *TraceRecorder {
+	t := &TraceRecorder{
+		dev:   dev,
+		start: time.Now(),
+		trace: Trace{Redacted: redact},
+	}
+	if redact {
+		t.redact = new(writeDecoder)
+	}
+	return t
+}
+
+func (t *TraceRecorder) Read(p []byte) (int, error) {
+	n, err := t.dev.Read(p)
+	if n > 0 {
+		t.record(TraceRead, p[:n])
+	}
+	if err != nil {
+		t.record(TraceError, []byte(err.Error()))
+	}
+	return n, err
+}
+
+func (t *TraceRecorder) Write(p []byte) (int, error) {
+	n, err := t.dev.Write(p)
+	if n > 0 {
+		t.record(TraceWrite, p[:n])
+	}
+	if err != nil {
+		t.record(TraceError, []byte(err.Error()))
+	}
+	return n, err
+}
+
+func (t *TraceRecorder) Close() error {
+	return t.dev.Close()
+}
+
+func (t *TraceRecorder) record(dir TraceDir, data []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	data = append([]byte(nil), data...)
+	now := time.Since(t.start)
+	if t.redact != nil {
+		if dir == TraceWrite {
+			t.redact.redact(data)
+		}
+		now = t.redactTime(dir, data, now)
+	}
+	e := TraceEvent{
+		Time: now,
+		Dir:  dir,
+		Data: data,
+	}
+	if t.Limit <= 0 || len(t.trace.Events) < t.Limit {
+		t.trace.Events = append(t.trace.Events, e)
+		return
+	}
+	old := &t.trace.Events[t.next]
+	wipeBytes(old.Data)
+	*old = e
+	t.next = (t.next + 1) % len(t.trace.Events)
+	t.trace.Truncated = true
+}
+
+// Reset drops the recorded events.
+func (t *TraceRecorder) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, e := range t.trace.Events {
+		wipeBytes(e.Data)
+	}
+	t.trace.Events = nil
+	t.trace.Truncated = false
+	t.next = 0
+}
+
+func wipeBytes(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+// redactTime returns the recorded time of a redacted event. Events
+// during a program are recorded at the start of the program, because
+// the duration of each step reveals the engraved strokes.
+func (t *TraceRecorder) redactTime(dir TraceDir, data []byte, now time.Duration) time.Duration {
+	switch {
+	case !t.program:
+		if t.redact.program > 0 {
+			t.program, t.programTime = true, now
+		}
+	case dir == TraceError,
+		dir == TraceRead && bytes.IndexByte(data, programCompleteStatus) != -1,
+		// Program commands are zeroed, so cancelCmd is a command.
+		dir == TraceWrite && bytes.IndexByte(data, cancelCmd) != -1:
+		t.program = false
+	default:
+		now = t.programTime
+	}
+	return now
+}
+
+// Trace returns the trace recorded so far.
+func (t *TraceRecorder) Trace() *Trace {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	events := make([]TraceEvent, len(t.trace.Events))
+	for i := range events {
+		e := t.trace.Events[(t.next+i)%len(events)]
+		// Copy the data, because Reset wipes it.
+		e.Data = append([]byte(nil), e.Data...)
+		events[i] = e
+	}
+	return &Trace{
+		Redacted:  t.trace.Redacted,
+		Truncated: t.trace.Truncated,
+		Events:    events,
+	}
+}
+
+// TraceDevice is a fake engraver that replays the replies and errors of
+// a trace. Writes must match the writes of the trace; when the trace
+// ends, reads block until the device is closed.
+type TraceDevice struct {
+	// Timing delays replies by their delays in the trace.
+	Timing bool
+
+	mu       sync.Mutex
+	redact   *writeDecoder
+	events   []TraceEvent
+	idx      int
+	write    []byte
+	read     []byte
+	prevTime time.Duration
+	prevWall time.Time
+	close    chan struct{}
+	closed   bool
+}
+
+// TraceMismatchError is returned by a TraceDevice when the driver
+// doesn't behave as in the trace.
+type TraceMismatchError struct {
+	// Event is the index of the mismatching trace event.
+	Event int
+	Want  []byte
+	Got   []byte
+}
+
+func (e *TraceMismatchError) Error() string {
+	return fmt.Sprintf("mjolnir: trace mismatch at event %d\nexp: %#x\ngot: %#x", e.Event, e.Want, e.Got)
+}
+
+// TraceReplayError is a replayed error from a trace.
+type TraceReplayError struct {
+	Msg string
+}
+
+func (e *TraceReplayError) Error() string {
+	return "mjolnir: traced error: " + e.Msg
+}
+
+func NewTraceDevice(t *Trace) *TraceDevice {
+	d := &TraceDevice{
+		events:   t.Events,
+		prevWall: time.Now(),
+		close:    make(chan struct{}),
+	}
+	if t.Redacted {
+		d.redact = new(writeDecoder)
+	}
+	return d
+}
+
+// Remaining returns the number of trace events not yet replayed.
+func (d *TraceDevice) Remaining() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	n := len(d.events) - d.idx
+	if len(d.write) > 0 || len(d.read) > 0 {
+		n++
+	}
+	return n
+}
+
+// next advances to the next event, which must be in direction dir.
+func (d *TraceDevice) next(dir TraceDir) (TraceEvent, bool, error) {
+	if d.idx == len(d.events) {
+		return TraceEvent{}, false, nil
+	}
+	e := d.events[d.idx]
+	if e.Dir == TraceError {
+		d.idx++
+		return e, true, &TraceReplayError{Msg: string(e.Data)}
+	}
+	if e.Dir != dir {
+		var got []byte
+		if dir == TraceRead {
+			got = []byte("read")
+		}
+		return e, true, &TraceMismatchError{Event: d.idx, Want: e.Data, Got: got}
+	}
+	d.idx++
+	return e, true, nil
+}
+
+func (d *TraceDevice) Write(p []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return 0, errClosed
+	}
+	got := append([]byte(nil), p...)
+	if d.redact != nil {
+		d.redact.redact(got)
+	}
+	for i, b := range got {
+		if len(d.write) == 0 {
+			e, ok, err := d.next(TraceWrite)
+			if err != nil {
+				return i, err
+			}
+			if !ok {
+				return i, &TraceMismatchError{Event: d.idx, Got: got[i:]}
+			}
+			d.write = e.Data
+			d.prevTime, d.prevWall = e.Time, time.Now()
+		}
+		if d.write[0] != b {
+			return i, &TraceMismatchError{Event: d.idx - 1, Want: d.write, Got: got[i:]}
+		}
+		d.write = d.write[1:]
+	}
+	return len(p), nil
+}
+
+func (d *TraceDevice) Read(p []byte) (int, error) {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return 0, errClosed
+	}
+	if len(d.read) == 0 {
+		if len(d.write) > 0 {
+			err := &TraceMismatchError{Event: d.idx - 1, Want: d.write, Got: []byte("read")}
+			d.mu.Unlock()
+			return 0, err
+		}
+		e, ok, err := d.next(TraceRead)
+		if err != nil || !ok {
+			d.mu.Unlock()
+			if err != nil {
+				return 0, err
+			}
+			// The engraver stopped replying.
+			<-d.close
+			return 0, errClosed
+		}
+		d.read = e.Data
+		var wait time.Duration
+		if d.Timing {
+			wait = time.Until(d.prevWall.Add(e.Time - d.prevTime))
+		}
+		d.prevTime, d.prevWall = e.Time, time.Now().Add(wait)
+		if wait > 0 {
+			d.mu.Unlock()
+			t := time.NewTimer(wait)
+			defer t.Stop()
+			select {
+			case <-t.C:
+			case <-d.close:
+				return 0, errClosed
+			}
+			d.mu.Lock()
+		}
+	}
+	n := copy(p, d.read)
+	d.read = d.read[n:]
+	d.mu.Unlock()
+	return n, nil
+}
+
+func (d *TraceDevice) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.closed {
+		d.closed = true
+		close(d.close)
+	}
+	return nil
+}
+
+// writeDecoder splits the bytes written to the engraver into commands.
+type writeDecoder struct {
+	// cmd is the command being decoded.
+	cmd []byte
+	// need is the number of bytes missing from cmd.
+	need int
+	// program is the number of remaining commands of the running
+	// program.
+	program int
+}
+
+// next decodes a byte and returns the command it completes, if any, and
+// whether the byte is part of a program command. The returned command is
+// only valid until the next call.
+func (d *writeDecoder) next(b byte) (cmd []byte, inProgram bool, programByte bool) {
+	if len(d.cmd) == 0 {
+		d.cmd = append(d.cmd[:0], b)
+		programByte = d.program > 0 && b != cancelCmd
+		switch {
+		case d.program > 0 && b != cancelCmd:
+			d.need = cmdSize - 1
+		case b == setSpeedCmd:
+			d.need = 6
+		case b == setDelaysCmd:
+			d.need = 2
+		case b == moveToOriginCmd:
+			d.need = 1
+		case b == initProgramCmd:
+			d.need = 2
+		default:
+			d.need = 0
+		}
+	} else {
+		d.cmd = append(d.cmd, b)
+		d.need--
+		programByte = d.program > 0
+	}
+	if d.need > 0 {
+		return nil, false, programByte
+	}
+	cmd = d.cmd
+	d.cmd = d.cmd[:0]
+	switch {
+	case cmd[0] == cancelCmd:
+		d.program = 0
+	case d.program > 0:
+		d.program--
+		inProgram = true
+	case cmd[0] == initProgramCmd:
+		d.program = (int(cmd[1]) | int(cmd[2])<<8) * progBatchSize
+	}
+	return cmd, inProgram, programByte
+}
+
+// redact zeroes the program commands in data. Both the coordinates and
+// the sequence of moves and lines reveal what is engraved.
+func (d *writeDecoder) redact(data []byte) {
+	for i, b := range data {
+		if _, _, p := d.next(b); p {
+			data[i] = 0
+		}
+	}
+}
+
+func formatCmd(cmd []byte, inProgram bool) string {
+	xy := func(coords []byte) string {
+		x, y := coordsFromCmd(coords)
+		return fmt.Sprintf("(%.3f,%.3f)", float32(x)*stepSize, float32(y)*stepSize)
+	}
+	u16 := func(b []byte) int {
+		return int(b[0]) | int(b[1])<<8
+	}
+	if inProgram {
+		switch cmd[0] {
+		case moveCmd:
+			return "move " + xy(cmd[1:])
+		case lineCmd:
+			return "line " + xy(cmd[1:])
+		case nopCmd:
+			return "nop"
+		}
+		return fmt.Sprintf("unknown program command %#x", cmd)
+	}
+	switch cmd[0] {
+	case cancelCmd:
+		return "cancel"
+	case initCmd:
+		return "init"
+	case setSpeedCmd:
+		return fmt.Sprintf("set speeds print=%d move=%d aux=%#x", u16(cmd[1:]), u16(cmd[3:]), u16(cmd[5:]))
+	case setDelaysCmd:
+		return fmt.Sprintf("set delays down=%#x up=%#x", cmd[1], cmd[2])
+	case moveToOriginCmd:
+		return "move to origin"
+	case queryPosCmd:
+		return "query position"
+	case initProgramCmd:
+		return fmt.Sprintf("program of %d batches", u16(cmd[1:]))
+	}
+	return fmt.Sprintf("unknown command %#x", cmd)
+}
+
+func formatStatus(s byte) string {
+	switch s {
+	case initializedStatus:
+		return "initialized"
+	case cancellingStatus:
+		return "cancelling"
+	case cancelledStatus:
+		return "cancelled"
+	case bufferProgramStatus:
+		return "batch request"
+	case programStepStatus:
+		return "step"
+	case programCompleteStatus:
+		return "program complete"
+	}
+	return fmt.Sprintf("unknown status %#x", s)
+}
+
+// FormatTrace writes the commands and replies of a trace in readable
+// form. Repeated lines are collapsed.
+func FormatTrace(w io.Writer, t *Trace) error {
+	bw := bufio.NewWriter(w)
+	var last string
+	var lastTime time.Duration
+	repeats := 0
+	flush := func() {
+		if last == "" {
+			return
+		}
+		fmt.Fprintf(bw, "%12.3fms %s", float64(lastTime.Microseconds())/1000, last)
+		if repeats > 1 {
+			fmt.Fprintf(bw, " (x%d)", repeats)
+		}
+		fmt.Fprintln(bw)
+	}
+	emit := func(at time.Duration, line string) {
+		if line == last {
+			repeats++
+			return
+		}
+		flush()
+		last, lastTime, repeats = line, at, 1
+	}
+	var dec writeDecoder
+	// reply is the pending multi-byte reply of the previous command.
+	var reply []byte
+	replyLen := 0
+	var replyCmd byte
+	for _, e := range t.Events {
+		switch e.Dir {
+		case TraceWrite:
+			for _, b := range e.Data {
+				cmd, inProgram, _ := dec.next(b)
+				if cmd == nil {
+					continue
+				}
+				line := formatCmd(cmd, inProgram)
+				if inProgram && t.Redacted {
+					line = "redacted program command"
+				}
+				emit(e.Time, "> "+line)
+				if inProgram {
+					continue
+				}
+				switch cmd[0] {
+				case queryPosCmd:
+					replyCmd, replyLen = cmd[0], 1+9
+				case moveToOriginCmd:
+					replyCmd, replyLen = cmd[0], 2
+				case setSpeedCmd, setDelaysCmd:
+					replyCmd, replyLen = cmd[0], 1
+				}
+			}
+		case TraceError:
+			emit(e.Time, "! "+string(e.Data))
+		case TraceRead:
+			for _, b := range e.Data {
+				if replyLen == 0 {
+					emit(e.Time, "< "+formatStatus(b))
+					continue
+				}
+				reply = append(reply, b)
+				if len(reply) < replyLen {
+					continue
+				}
+				var line string
+				switch {
+				case reply[0] != replyCmd:
+					line = fmt.Sprintf("unexpected reply %#x", reply)
+				case replyCmd == queryPosCmd:
+					x, y := coordsFromCmd(reply[1:])
+					line = fmt.Sprintf("position (%.3f,%.3f)", float32(x)*stepSize, float32(y)*stepSize)
+				case replyCmd == moveToOriginCmd:
+					line = "at origin"
+				case replyCmd == setSpeedCmd:
+					line = "ack set speeds"
+				default:
+					line = "ack set delays"
+				}
+				emit(e.Time, "< "+line)
+				reply, replyLen = reply[:0], 0
+			}
+		}
+	}
+	flush()
+	return bw.Flush()
+}
+
+// Program reconstructs the program of an engraving from its trace.
+// The design is the largest program in the trace, and the end point is
+// the single move following it, if any. Redacted traces result in
+// programs of lines to the origin, including the padding of the last
+// batch.
+func (t *Trace) Program() (*Program, error) {
+	if t.Truncated {
+		return nil, errors.New("mjolnir: trace is truncated")
+	}
+	type segment struct {
+		cmds []Cmd
+		// speeds is the speed setting of the segment.
+		speeds []byte
+	}
+	var segs []segment
+	var dec writeDecoder
+	var speeds, delays []byte
+	for _, e := range t.Events {
+		if e.Dir != TraceWrite {
+			continue
+		}
+		for _, b := range e.Data {
+			cmd, inProgram, _ := dec.next(b)
+			if cmd == nil {
+				continue
+			}
+			if !inProgram {
+				switch cmd[0] {
+				case initProgramCmd:
+					segs = append(segs, segment{speeds: speeds})
+				case setSpeedCmd:
+					speeds = append([]byte(nil), cmd[1:]...)
+				case setDelaysCmd:
+					delays = append([]byte(nil), cmd[1:]...)
+				}
+				continue
+			}
+			if len(segs) == 0 || (cmd[0] != moveCmd && cmd[0] != lineCmd) {
+				continue
+			}
+			x, y := coordsFromCmd(cmd[1:])
+			c := Cmd{Type: MoveTo, X: x, Y: y}
+			if cmd[0] == lineCmd {
+				c.Type = LineTo
+			}
+			s := &segs[len(segs)-1]
+			s.cmds = append(s.cmds, c)
+		}
+	}
+	main := -1
+	for i, s := range segs {
+		if main == -1 || len(s.cmds) > len(segs[main].cmds) {
+			main = i
+		}
+	}
+	if main == -1 {
+		return nil, errors.New("mjolnir: no program in trace")
+	}
+	prog, err := NewProgram(Recording(segs[main].cmds))
+	if err != nil {
+		return nil, err
+	}
+	if main+1 < len(segs) && len(segs[main+1].cmds) == 1 {
+		c := segs[main+1].cmds[0]
+		prog.End = f32.Vec2{float32(c.X) * stepSize, float32(c.Y) * stepSize}
+	}
+	if len(delays) == 2 {
+		prog.PenDownDelay, prog.PenUpDelay = int(delays[0]), int(delays[1])
+	}
+	if s := segs[main].speeds; len(s) == 6 {
+		print, move := int(s[0])|int(s[1])<<8, int(s[2])|int(s[3])<<8
+		prog.PrintSpeed = speedFraction(print)
+		prog.MoveSpeed = speedFraction(move)
+		prog.AuxSpeed = int(s[4]) | int(s[5])<<8
+	}
+	return prog, nil
+}
+
+// speedFraction inverts the mapping from speed fractions to machine
+// speeds.
+func speedFraction(speed int) float32 {
+	return (1000 - (float32(speed) + .5)) / 970
+}
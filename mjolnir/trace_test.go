@@ -0,0 +1,223 @@
+package mjolnir
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTraceReplay(t *testing.T) {
+	for _, redact := range []bool{false, true} {
+		prog, err := NewProgram(gridDesign)
+		if err != nil {
+			t.Fatal(err)
+		}
+		prog.PenDownDelay = 0x30
+		sim := NewSimulator()
+		rec := NewTraceRecorder(sim, redact)
+		if err := Engrave(context.Background(), rec, prog, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+		rec.Close()
+		buf := new(bytes.Buffer)
+		if err := rec.Trace().Encode(buf); err != nil {
+			t.Fatal(err)
+		}
+		trace, err := DecodeTrace(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if trace.Redacted != redact {
+			t.Errorf("decoded trace redacted: %v, expected %v", trace.Redacted, redact)
+		}
+		replay, err := trace.Program()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, want := replay.Len(), prog.Len()
+		if redact {
+			// Padding is indistinguishable from redacted commands.
+			got, want = batches(got), batches(want)
+		}
+		if got != want {
+			t.Errorf("reconstructed program has %d commands, expected %d", got, want)
+		}
+		dev := NewTraceDevice(trace)
+		if err := Engrave(context.Background(), dev, replay, nil, nil); err != nil {
+			t.Errorf("replay (redacted: %v) failed: %v", redact, err)
+		}
+		dev.Close()
+		if n := dev.Remaining(); n != 0 {
+			t.Errorf("replay (redacted: %v) left %d trace events", redact, n)
+		}
+	}
+}
+
+func batches(cmds int) int {
+	return (cmds + progBatchSize - 1) / progBatchSize
+}
+
+func TestTraceRedact(t *testing.T) {
+	prog, err := NewProgram(gridDesign)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sim := NewSimulator()
+	rec := NewTraceRecorder(sim, true)
+	if err := Engrave(context.Background(), rec, prog, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	rec.Close()
+	trace := rec.Trace()
+	var dec writeDecoder
+	var start time.Duration
+	program := false
+	for _, e := range trace.Events {
+		switch e.Dir {
+		case TraceWrite:
+			for _, b := range e.Data {
+				cmd, inProgram, _ := dec.next(b)
+				if inProgram && !bytes.Equal(cmd, make([]byte, cmdSize)) {
+					t.Fatalf("program command %#x not redacted", cmd)
+				}
+			}
+			if !program && dec.program > 0 {
+				program, start = true, e.Time
+			}
+		case TraceRead:
+			if bytes.IndexByte(e.Data, programCompleteStatus) != -1 {
+				program = false
+				continue
+			}
+			if program && e.Time != start {
+				t.Fatalf("program step recorded at %v, expected program start %v", e.Time, start)
+			}
+		}
+	}
+	buf := new(bytes.Buffer)
+	if err := FormatTrace(buf, trace); err != nil {
+		t.Fatal(err)
+	}
+	if out := buf.String(); strings.Contains(out, "> line") || strings.Contains(out, "> move (") {
+		t.Errorf("formatted redacted trace shows program commands:\n%s", out)
+	}
+}
+
+func TestTraceLimit(t *testing.T) {
+	prog, err := NewProgram(gridDesign)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sim := NewSimulator()
+	full := NewTraceRecorder(sim, true)
+	rec := NewTraceRecorder(full, true)
+	const limit = 100
+	rec.Limit = limit
+	if err := Engrave(context.Background(), rec, prog, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	rec.Close()
+	trace, all := rec.Trace(), full.Trace()
+	if !trace.Truncated || len(trace.Events) != limit {
+		t.Fatalf("trace has %d events (truncated: %v), expected the last %d", len(trace.Events), trace.Truncated, limit)
+	}
+	last := all.Events[len(all.Events)-limit:]
+	for i, e := range trace.Events {
+		if w := last[i]; e.Dir != w.Dir || !bytes.Equal(e.Data, w.Data) {
+			t.Fatalf("event %d is %c %x, expected %c %x", i, e.Dir, e.Data, w.Dir, w.Data)
+		}
+	}
+	buf := new(bytes.Buffer)
+	if err := trace.Encode(buf); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodeTrace(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.Truncated {
+		t.Error("decoded trace isn't truncated")
+	}
+	if _, err := decoded.Program(); err == nil {
+		t.Error("reconstructed a program from a truncated trace")
+	}
+	rec.Reset()
+	if n := len(rec.Trace().Events); n != 0 {
+		t.Errorf("reset trace has %d events", n)
+	}
+	if !bytes.Equal(trace.Events[0].Data, last[0].Data) {
+		t.Error("reset wiped a returned trace")
+	}
+}
+
+func TestTraceReplayError(t *testing.T) {
+	prog, err := NewProgram(gridDesign)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sim := NewSimulator()
+	sim.Faults = []Fault{{Kind: FaultDisconnect, At: 100}}
+	rec := NewTraceRecorder(sim, true)
+	if err := Engrave(context.Background(), rec, prog, nil, nil); err == nil {
+		t.Fatal("disconnected engraving succeeded")
+	}
+	rec.Close()
+	trace := rec.Trace()
+	dev := NewTraceDevice(trace)
+	defer dev.Close()
+	var rerr *TraceReplayError
+	if err := Engrave(context.Background(), dev, prog, nil, nil); !errors.As(err, &rerr) {
+		t.Errorf("replay returned %v, expected the traced error", err)
+	}
+	// A different program doesn't match the trace.
+	other, err := NewProgram(moveDesign{1, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dev = NewTraceDevice(trace)
+	defer dev.Close()
+	var merr *TraceMismatchError
+	if err := Engrave(context.Background(), dev, other, nil, nil); !errors.As(err, &merr) {
+		t.Errorf("mismatched replay returned %v, expected a mismatch", err)
+	}
+}
+
+func TestFormatTrace(t *testing.T) {
+	prog, err := NewProgram(gridDesign)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sim := NewSimulator()
+	rec := NewTraceRecorder(sim, false)
+	if err := Engrave(context.Background(), rec, prog, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	rec.Close()
+	buf := new(bytes.Buffer)
+	if err := FormatTrace(buf, rec.Trace()); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"> init",
+		"< initialized",
+		"> set delays down=0x14 up=0x14",
+		"< ack set delays",
+		"> move to origin",
+		"< at origin",
+		"> program of 8 batches",
+		"> nop (x",
+		"< step (x",
+		"< program complete",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("formatted trace doesn't contain %q", want)
+		}
+	}
+	if t.Failed() {
+		t.Logf("formatted trace:\n%s", out)
+	}
+}
@@ -3,6 +3,7 @@ package zbar
 /*
 #cgo CFLAGS: -DENABLE_QRCODE -DNO_STATS -Wno-shift-op-parentheses -Wno-format -Wno-format-security
 
+#include <stdlib.h>
 #include "zbar.h"
 #include "binarize.h"
 */
@@ -14,6 +15,28 @@ import (
 	"unsafe"
 )
 
+// ScanImage is like Scan, but accepts images backed by Go memory. The image
+// is copied to C memory before scanning.
+func ScanImage(img *image.Gray) ([][]byte, error) {
+	sz := img.Bounds().Size()
+	if sz.X == 0 || sz.Y == 0 {
+		return nil, nil
+	}
+	n := sz.X * sz.Y
+	buf := C.malloc(C.size_t(n))
+	defer C.free(buf)
+	cimg := &image.Gray{
+		Pix:    unsafe.Slice((*byte)(buf), n),
+		Stride: sz.X,
+		Rect:   image.Rectangle{Max: sz},
+	}
+	for y := 0; y < sz.Y; y++ {
+		start := img.PixOffset(img.Rect.Min.X, img.Rect.Min.Y+y)
+		copy(cimg.Pix[y*sz.X:(y+1)*sz.X], img.Pix[start:start+sz.X])
+	}
+	return Scan(cimg)
+}
+
 // Scan is a raw wrapper around zbar's scan API. In particular, the backing store
 // for the img argument must be allocated outside Go because it is retained across
 // Cgo calls (but not after Scan completes).